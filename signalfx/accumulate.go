@@ -0,0 +1,130 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Default seconds datapoints are held when accumulating
+const defaultAccumulateMaxAge = 60
+
+// accumulated - Datapoints held for the targets of a route
+type accumulated struct {
+	route []*target              // Targets of the datapoints
+	dps   []*datapoint.Datapoint // Datapoints held
+}
+
+// setAccumulateCycles will hold datapoints across the number of publishes
+// in the accumulate_cycles setting, sending them together, but for no
+// longer than accumulate_max_age seconds
+func (s *SignalFx) setAccumulateCycles(cfg plugin.Config) {
+	cycles, err := s.getInt(cfg, "accumulate_cycles")
+	if err != nil || cycles <= 1 {
+		// No accumulate_cycles defined, moving on
+		return
+	}
+	s.accumulateCycles = cycles
+
+	maxAge := int64(defaultAccumulateMaxAge)
+	if n, err := s.getInt(cfg, "accumulate_max_age"); err == nil && n > 0 {
+		maxAge = n
+	}
+	s.accumulateMaxAge = time.Duration(maxAge) * time.Second
+
+	s.logf("Sending datapoints every %d publishes or %d seconds", cycles, maxAge)
+}
+
+// accumulate holds the datapoints for the current route until the
+// accumulator is flushed, returning false when not accumulating.
+// Datapoints without a timestamp are stamped now, since they are sent
+// later.
+func (s *SignalFx) accumulate(dps []*datapoint.Datapoint) bool {
+	if s.accumulateCycles <= 1 {
+		return false
+	}
+
+	now := s.now()
+	for _, dp := range dps {
+		if dp.Timestamp.IsZero() {
+			dp.Timestamp = now
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.accumulated) == 0 {
+		s.accumulatedAt = now
+	}
+	for i := range s.accumulated {
+		if sameRoute(s.accumulated[i].route, s.route) {
+			s.accumulated[i].dps = append(s.accumulated[i].dps, dps...)
+			return true
+		}
+	}
+	s.accumulated = append(s.accumulated, accumulated{route: s.route, dps: dps})
+	return true
+}
+
+// endCycle flushes the accumulator once it has held datapoints for
+// accumulate_cycles publishes or accumulate_max_age
+func (s *SignalFx) endCycle() {
+	if s.accumulateCycles <= 1 {
+		return
+	}
+
+	s.accumulatedCycles++
+	if s.accumulatedCycles < s.accumulateCycles && s.now().Sub(s.accumulatedAt) < s.accumulateMaxAge {
+		return
+	}
+	s.flushAccumulated(0)
+}
+
+// flushAccumulated sends the datapoints held, together for each route,
+// with the timeout, if any
+func (s *SignalFx) flushAccumulated(timeout time.Duration) {
+	s.mu.Lock()
+	held := s.accumulated
+	s.accumulated = nil
+	s.accumulatedCycles = 0
+	s.mu.Unlock()
+
+	for _, a := range held {
+		s.debugf("Sending %d accumulated datapoints", len(a.dps))
+		s.deliver(a.route, timeout, a.dps)
+	}
+}
+
+// sameRoute reports whether the routes have the same targets
+func sameRoute(a, b []*target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestAccumulateCycles(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   plugin.Config
+		advance    time.Duration // Clock advance between publishes
+		requests   []int         // Requests made by the end of each publish
+		datapoints []int         // Datapoints received by then
+	}{
+		{"every other publish", plugin.Config{"accumulate_cycles": int64(2)}, time.Second,
+			[]int{0, 1, 1, 2}, []int{0, 2, 2, 4}},
+		{"every fourth publish", plugin.Config{"accumulate_cycles": int64(4)}, time.Second,
+			[]int{0, 0, 0, 1}, []int{0, 0, 0, 4}},
+		{"max age", plugin.Config{"accumulate_cycles": int64(4), "accumulate_max_age": int64(10)}, 6 * time.Second,
+			[]int{0, 0, 1, 1}, []int{0, 0, 3, 3}},
+		{"one cycle", plugin.Config{"accumulate_cycles": int64(1)}, time.Second,
+			[]int{1, 2, 3, 4}, []int{1, 2, 3, 4}},
+		{"absent", nil, time.Second,
+			[]int{1, 2, 3, 4}, []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		now := time.Unix(1500000000, 0)
+		s := newTestPlugin()
+		s.now = func() time.Time { return now }
+
+		var requests, datapoints []int
+		cfg := testConfig(is.URL, tt.settings)
+		for i := 0; i < 4; i++ {
+			if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+			is.mu.Lock()
+			requests = append(requests, is.requests)
+			datapoints = append(datapoints, len(is.datapoints))
+			is.mu.Unlock()
+			now = now.Add(tt.advance)
+		}
+		is.Close()
+
+		if !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: requests %v, want %v", tt.name, requests, tt.requests)
+		}
+		if !reflect.DeepEqual(datapoints, tt.datapoints) {
+			t.Errorf("%s: datapoints %v, want %v", tt.name, datapoints, tt.datapoints)
+		}
+	}
+}
+
+func TestAccumulateTimestamps(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	start := time.Unix(1500000000, 0)
+	now := start
+	s := newTestPlugin()
+	s.now = func() time.Time { return now }
+
+	// Held datapoints keep the time they were published
+	cfg := testConfig(is.URL, plugin.Config{"accumulate_cycles": int64(2)})
+	for i := 0; i < 2; i++ {
+		if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if len(is.datapoints) != 2 {
+		t.Fatalf("%d datapoints received, want 2", len(is.datapoints))
+	}
+	for i, dp := range is.datapoints {
+		want := start.Add(time.Duration(i)*10*time.Second).UnixNano() / int64(time.Millisecond)
+		if dp.Timestamp != want {
+			t.Errorf("Datapoint %d stamped %d, want %d", i, dp.Timestamp, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// Suffix of the metric holding the age of another
+const ageSuffix = ".age_seconds"
+
+// setEmitAge will enable sending the age of each metric if the emit_age
+// config setting is present in the task file
+func (s *SignalFx) setEmitAge(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "emit_age")
+	if err != nil || !enabled {
+		return
+	}
+	s.emitAge = true
+
+	s.logf("Sending metric ages as <name>%s", ageSuffix)
+}
+
+// sendAge sends the seconds since the metric was collected as a gauge
+// alongside the current metric; metrics without a timestamp are skipped
+func (s *SignalFx) sendAge(collected time.Time) {
+	if collected.IsZero() {
+		return
+	}
+
+	age := s.now().Sub(collected).Seconds()
+	s.debugf("Sending [age] %s -> %v", s.namespace+ageSuffix, age)
+
+	s.send(sfxclient.GaugeF(s.namespace+ageSuffix, s.dimensions, age))
+}
@@ -0,0 +1,72 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestEmitAge(t *testing.T) {
+	now := time.Date(2017, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		settings  plugin.Config
+		collected time.Time
+		age       float64
+		sent      bool
+	}{
+		{"collected earlier", plugin.Config{"emit_age": true}, now.Add(-90 * time.Second), 90, true},
+		{"fractional", plugin.Config{"emit_age": true}, now.Add(-1500 * time.Millisecond), 1.5, true},
+		{"no timestamp", plugin.Config{"emit_age": true}, time.Time{}, 0, false},
+		{"disabled", nil, now.Add(-90 * time.Second), 0, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		s.now = func() time.Time { return now }
+
+		m := newMetric(int64(1), "intel", "cpu", "idle")
+		m.Timestamp = tt.collected
+		if err := s.Publish([]plugin.Metric{m}, testConfig(is.URL, tt.settings)); err != nil {
+			t.Fatalf("%s: Publish returned %v", tt.name, err)
+		}
+		dps := is.received()
+		is.Close()
+
+		if _, ok := dps["snap.intel.cpu.idle"]; !ok {
+			t.Errorf("%s: the metric was not sent", tt.name)
+		}
+		dp, ok := dps["snap.intel.cpu.idle"+ageSuffix]
+		if ok != tt.sent {
+			t.Errorf("%s: age sent = %v, want %v", tt.name, ok, tt.sent)
+			continue
+		}
+		if ok && dp.Value != tt.age {
+			t.Errorf("%s: age = %v, want %v", tt.name, dp.Value, tt.age)
+		}
+	}
+}
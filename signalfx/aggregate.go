@@ -0,0 +1,171 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Aggregation functions
+var aggregateFuncs = map[string]bool{
+	"sum": true,
+	"avg": true,
+	"min": true,
+	"max": true,
+}
+
+// aggregateRule - A namespace prefix whose values are aggregated
+type aggregateRule struct {
+	prefix string // Snap namespace prefix
+	fn     string // Aggregation function
+}
+
+// aggregate - The values of a series aggregated over a publish
+type aggregate struct {
+	name     string            // Metric name
+	dims     map[string]string // Metric dimensions
+	fn       string            // Aggregation function
+	value    float64           // Aggregated value
+	ints     bool              // Every value was an integer
+	intValue int64             // Aggregated value of integers
+	count    int               // Values aggregated
+
+	// How the series is sent, as captured from its first metric
+	route      []*target              // Metric targets
+	cumulative bool                   // Send as a cumulative counter
+	properties map[string]interface{} // Metric properties
+	timestamp  time.Time              // Latest metric timestamp, if any
+	timeout    time.Duration          // Metric send timeout
+}
+
+// setAggregateNamespaces will parse the aggregate_namespaces setting;
+// each entry is a namespace prefix and function, e.g.
+// "/intel/procfs/disk:sum,/intel/psutil/load:avg"
+func (s *SignalFx) setAggregateNamespaces(cfg plugin.Config) {
+	value, err := s.getString(cfg, "aggregate_namespaces")
+	if err != nil {
+		// No aggregate_namespaces defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		i := strings.LastIndex(entry, ":")
+		if i < 0 || !aggregateFuncs[entry[i+1:]] {
+			s.logf("Ignoring aggregation %q, expected prefix:sum|avg|min|max", entry)
+			continue
+		}
+
+		rule := aggregateRule{prefix: entry[:i], fn: entry[i+1:]}
+		s.logf("Aggregating %s using %s", rule.prefix, rule.fn)
+		s.aggregations = append(s.aggregations, rule)
+	}
+}
+
+// aggregateRuleFor returns the aggregation rule matching the namespace,
+// if any
+func (s *SignalFx) aggregateRuleFor(namespace string) (aggregateRule, bool) {
+	for _, rule := range s.aggregations {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule, true
+		}
+	}
+	return aggregateRule{}, false
+}
+
+// aggregateValue adds the numeric data to the aggregate of the current
+// series. Integers are also aggregated as integers so that, unless
+// averaged, they are sent as integers.
+func (s *SignalFx) aggregateValue(aggregates map[string]*aggregate, fn string, data interface{}) {
+	key := seriesKey(s.namespace, s.dimensions)
+	value, _ := toFloat64(data)
+	n, isInt := toInt64(data)
+
+	a, ok := aggregates[key]
+	if !ok {
+		aggregates[key] = &aggregate{
+			name:       s.namespace,
+			dims:       s.dimensions,
+			fn:         fn,
+			value:      value,
+			ints:       isInt,
+			intValue:   n,
+			count:      1,
+			route:      s.route,
+			cumulative: s.cumulative,
+			properties: s.properties,
+			timestamp:  s.timestamp,
+			timeout:    s.timeout,
+		}
+		return
+	}
+	if s.timestamp.After(a.timestamp) {
+		a.timestamp = s.timestamp
+	}
+
+	switch fn {
+	case "sum", "avg":
+		a.value += value
+		a.intValue += n
+	case "min":
+		if value < a.value {
+			a.value = value
+			a.intValue = n
+		}
+	case "max":
+		if value > a.value {
+			a.value = value
+			a.intValue = n
+		}
+	}
+	a.ints = a.ints && isInt
+	a.count++
+}
+
+// result returns the aggregated value
+func (a *aggregate) result() float64 {
+	if a.fn == "avg" {
+		return a.value / float64(a.count)
+	}
+	return a.value
+}
+
+// sendAggregates sends the aggregated values as their metrics would have
+// been sent
+func (s *SignalFx) sendAggregates(aggregates map[string]*aggregate) {
+	for _, a := range aggregates {
+		s.namespace = a.name
+		s.dimensions = a.dims
+		s.route = a.route
+		s.cumulative = a.cumulative
+		s.properties = a.properties
+		s.timestamp = a.timestamp
+		s.timeout = a.timeout
+		s.debugf("Aggregated %d values of %s using %s", a.count, a.name, a.fn)
+
+		if a.ints && a.fn != "avg" {
+			s.sendIntValue(a.intValue)
+		} else {
+			s.sendFloatValue(a.result())
+		}
+	}
+}
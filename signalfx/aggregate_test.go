@@ -0,0 +1,109 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestAggregateNamespaces(t *testing.T) {
+	tests := []struct {
+		fn    string
+		data  []interface{}
+		value string
+	}{
+		{"sum", []interface{}{int64(1), int64(2), int64(3)}, "6"},
+		{"avg", []interface{}{int64(1), int64(2), int64(6)}, "3"},
+		{"min", []interface{}{2.5, 1.5, 3.5}, "1.5"},
+		{"max", []interface{}{2.5, 1.5, 3.5}, "3.5"},
+	}
+	for _, tt := range tests {
+		var mts []plugin.Metric
+		for _, data := range tt.data {
+			mts = append(mts, newMetric(data, "intel", "disk", "reads"))
+		}
+		mts = append(mts, newMetric(int64(7), "intel", "cpu", "idle"))
+
+		dps := publish(t, plugin.Config{"aggregate_namespaces": "/intel/disk:" + tt.fn}, mts...)
+		if got := dps["snap.intel.disk.reads"].Value; got == nil || fmt.Sprint(got) != tt.value {
+			t.Errorf("%s = %v, want %s", tt.fn, got, tt.value)
+		}
+		if got := dps["snap.intel.cpu.idle"].Value; got == nil || fmt.Sprint(got) != "7" {
+			t.Errorf("%s: unaggregated metric = %v, want 7", tt.fn, got)
+		}
+	}
+}
+
+func TestAggregateSentLikeItsMetrics(t *testing.T) {
+	at := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	mts := make([]plugin.Metric, 2)
+	for i := range mts {
+		mts[i] = newMetric(int64(i+1), "intel", "disk", "reads")
+		mts[i].Tags = map[string]string{
+			tagMetricType: "counter",
+			tagTimestamp:  epochMillis(at.Add(time.Duration(i) * time.Second)),
+		}
+	}
+
+	dp, ok := publish(t, plugin.Config{"aggregate_namespaces": "/intel/disk:sum"}, mts...)["snap.intel.disk.reads"]
+	if !ok {
+		t.Fatal("The aggregate was not sent")
+	}
+	if dp.Type != "cumulative_counter" {
+		t.Errorf("Sent as %s, want cumulative_counter", dp.Type)
+	}
+	if want := at.Add(time.Second).UnixNano() / int64(time.Millisecond); dp.Timestamp != want {
+		t.Errorf("Timestamp = %d, want the latest %d", dp.Timestamp, want)
+	}
+}
+
+func TestAggregateTimeout(t *testing.T) {
+	slow := slowServer()
+	defer slow.Close()
+
+	mts := make([]plugin.Metric, 2)
+	for i := range mts {
+		mts[i] = newMetric(int64(i+1), "intel", "disk", "reads")
+		mts[i].Tags = map[string]string{tagTimeout: "50"}
+	}
+
+	s := newTestPlugin()
+	start := time.Now()
+	err := s.Publish(mts, testConfig(slow.URL, plugin.Config{
+		"aggregate_namespaces": "/intel/disk:sum",
+	}))
+	if err == nil {
+		t.Error("Publish to a slow server succeeded")
+	}
+
+	if s.lastErr == nil || classifyError(s.lastErr) != errorTimeout {
+		t.Errorf("Last error %v, want a timeout", s.lastErr)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Publish took %v with a 50ms sfx_timeout", elapsed)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// aliasRule - Maps a namespace pattern to a fixed metric name. Pattern
+// elements are literals, * to match any element, or {name} to match any
+// element and use it as the value of the name dimension, its key made
+// valid for SignalFx.
+type aliasRule struct {
+	pattern []string // Namespace pattern elements
+	name    string   // Metric name
+}
+
+// setAliasRules will parse the alias_rules setting, e.g.
+// "/intel/procfs/disk/{disk}/bytes_read=disk.bytes_read"
+func (s *SignalFx) setAliasRules(cfg plugin.Config) {
+	value, err := s.getString(cfg, "alias_rules")
+	if err != nil {
+		// No alias_rules defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		rule, err := parseAliasRule(entry)
+		if err != nil {
+			s.logf("Ignoring alias rule: %v", err)
+			continue
+		}
+
+		s.logf("Aliasing %s to %s", strings.Join(rule.pattern, "/"), rule.name)
+		s.aliases = append(s.aliases, rule)
+	}
+}
+
+// parseAliasRule parses a pattern=name alias rule
+func parseAliasRule(entry string) (aliasRule, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 {
+		return aliasRule{}, fmt.Errorf("%q is not of the form pattern=name", entry)
+	}
+
+	pattern := strings.Trim(strings.TrimSpace(parts[0]), "/")
+	name := strings.TrimSpace(parts[1])
+	if pattern == "" || name == "" {
+		return aliasRule{}, fmt.Errorf("%q has an empty pattern or name", entry)
+	}
+
+	elements := strings.Split(pattern, "/")
+	for i, element := range elements {
+		if !isDimensionElement(element) {
+			continue
+		}
+		key := element[1 : len(element)-1]
+		if key == "" {
+			return aliasRule{}, fmt.Errorf("%q has an empty {} dimension name", entry)
+		}
+		elements[i] = "{" + strings.Map(dimensionKeyRune, key) + "}"
+	}
+
+	return aliasRule{pattern: elements, name: name}, nil
+}
+
+// isDimensionElement reports whether the pattern element is a {name}
+// dimension
+func isDimensionElement(element string) bool {
+	return strings.HasPrefix(element, "{") && strings.HasSuffix(element, "}")
+}
+
+// match returns the dimensions extracted from the namespace when it
+// matches the pattern
+func (r aliasRule) match(ns []string) (map[string]string, bool) {
+	if len(ns) != len(r.pattern) {
+		return nil, false
+	}
+
+	dims := make(map[string]string)
+	for i, element := range r.pattern {
+		switch {
+		case element == "*":
+		case isDimensionElement(element):
+			dims[element[1:len(element)-1]] = ns[i]
+		case element != ns[i]:
+			return nil, false
+		}
+	}
+	return dims, true
+}
+
+// aliasFor returns the metric name and dimensions of the first alias rule
+// matching the namespace
+func (s *SignalFx) aliasFor(ns []string) (string, map[string]string, bool) {
+	for _, rule := range s.aliases {
+		if dims, ok := rule.match(ns); ok {
+			return rule.name, dims, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,126 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParseAliasRule(t *testing.T) {
+	tests := []struct {
+		entry   string
+		pattern int
+		name    string
+		ok      bool
+	}{
+		{"/intel/procfs/disk/{disk}/bytes_read=disk.bytes_read", 5, "disk.bytes_read", true},
+		{" /intel/*/load/ = load ", 3, "load", true},
+		{"/intel/procfs", 0, "", false},
+		{"=name", 0, "", false},
+		{"/intel/procfs/disk/{}/bytes_read=disk.bytes_read", 0, "", false},
+		{"/intel/procfs=", 0, "", false},
+	}
+	for _, tt := range tests {
+		rule, err := parseAliasRule(tt.entry)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseAliasRule(%q) returned %v", tt.entry, err)
+			continue
+		}
+		if len(rule.pattern) != tt.pattern || rule.name != tt.name {
+			t.Errorf("parseAliasRule(%q) = %v, want %d elements named %s", tt.entry, rule, tt.pattern, tt.name)
+		}
+	}
+}
+
+func TestAliasRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		metric   string
+	}{
+		{"default prefix", nil, "snap.disk.bytes_read"},
+		{"custom prefix", plugin.Config{"metric_prefix": "host"}, "host.disk.bytes_read"},
+		{"no prefix", plugin.Config{"metric_prefix": ""}, "disk.bytes_read"},
+		{"ahead of the renames", plugin.Config{
+			"strip_prefix":    "intel/procfs",
+			"name_template":   "{prefix}.custom.{namespace}",
+			"lowercase_names": true,
+		}, "snap.disk.bytes_read"},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"alias_rules": "/intel/procfs/disk/{disk}/bytes_read=disk.bytes_read"}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		dps := publish(t, settings,
+			newMetric(int64(1), "intel", "procfs", "disk", "sda", "bytes_read"),
+			newMetric(int64(2), "intel", "procfs", "disk", "sda", "bytes_written"),
+		)
+
+		dp, ok := dps[tt.metric]
+		if !ok {
+			t.Errorf("%s: %s was not sent, got %v", tt.name, tt.metric, dps)
+			continue
+		}
+		if dp.Dimensions["disk"] != "sda" {
+			t.Errorf("%s: disk = %q, want sda", tt.name, dp.Dimensions["disk"])
+		}
+		if len(dps) != 2 {
+			t.Errorf("%s: sent %d metrics, want the alias and the unmatched metric", tt.name, len(dps))
+		}
+	}
+}
+
+func TestAliasDimensionKeys(t *testing.T) {
+	dps := publish(t, plugin.Config{"alias_rules": "/intel/procfs/disk/{disk.name}/bytes_read=disk.bytes_read"},
+		newMetric(int64(1), "intel", "procfs", "disk", "sda", "bytes_read"),
+	)
+
+	dp, ok := dps["snap.disk.bytes_read"]
+	if !ok {
+		t.Fatalf("the alias was not sent, got %v", dps)
+	}
+	if dp.Dimensions["disk_name"] != "sda" {
+		t.Errorf("dimensions = %v, want disk_name sda", dp.Dimensions)
+	}
+}
+
+func TestAliasMatchesInferredNames(t *testing.T) {
+	dps := publish(t, plugin.Config{
+		"metric_prefix":    "host",
+		"alias_rules":      "/intel/procfs/disk/{disk}/bytes_read=bytes_read",
+		"infer_dimensions": "^/intel/psutil/disk/(?P<device>[^/]+)/(?P<metric>.+)$",
+	},
+		newMetric(int64(1), "intel", "procfs", "disk", "sda", "bytes_read"),
+		newMetric(int64(2), "intel", "psutil", "disk", "sdb", "bytes_written"),
+	)
+
+	for _, name := range []string{"host.bytes_read", "host.bytes_written"} {
+		if _, ok := dps[name]; !ok {
+			t.Errorf("%s was not sent, got %v", name, dps)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Actions taken on dimension values not in the allowlist
+const (
+	actionDrop    = "drop"    // Drop the datapoint
+	actionStrip   = "strip"   // Remove the dimension
+	actionRelabel = "relabel" // Replace the value with the default
+)
+
+// Default value unknown dimension values are relabeled to
+const defaultRelabelValue = "unknown"
+
+// allowlist - The values allowed for a dimension
+type allowlist struct {
+	key    string          // Dimension key
+	values map[string]bool // Allowed values
+	action string          // Action on other values
+}
+
+// setDimensionAllowlist will parse the dimension_value_allowlist setting;
+// each entry is a dimension key, the allowed values, and the action taken
+// on other values, e.g. "environment=prod|staging|dev:relabel"
+func (s *SignalFx) setDimensionAllowlist(cfg plugin.Config) {
+	value, err := s.getString(cfg, "dimension_value_allowlist")
+	if err != nil {
+		// No dimension_value_allowlist defined, moving on
+		return
+	}
+
+	s.relabelValue = defaultRelabelValue
+	if v, err := s.getString(cfg, "dimension_value_default"); err == nil && v != "" {
+		s.relabelValue = v
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring allowlist %q, expected key=value|value:action", entry)
+			continue
+		}
+
+		list := allowlist{key: parts[0], values: make(map[string]bool), action: actionDrop}
+		values := parts[1]
+		if i := strings.LastIndex(values, ":"); i >= 0 {
+			values, list.action = values[:i], values[i+1:]
+		}
+
+		switch list.action {
+		case actionDrop, actionStrip, actionRelabel:
+		default:
+			s.logf("Ignoring allowlist %q, unknown action %q", entry, list.action)
+			continue
+		}
+
+		for _, v := range strings.Split(values, "|") {
+			list.values[strings.TrimSpace(v)] = true
+		}
+
+		s.logf("Allowing %s values %s, otherwise %s", list.key, values, list.action)
+		s.allowlists = append(s.allowlists, list)
+	}
+}
+
+// applyAllowlists enforces the dimension value allowlists on the current
+// dimensions, returning false when the datapoint should be dropped
+func (s *SignalFx) applyAllowlists() bool {
+	for _, list := range s.allowlists {
+		value, ok := s.dimensions[list.key]
+		if !ok || list.values[value] {
+			continue
+		}
+
+		switch list.action {
+		case actionDrop:
+			s.logf("Dropping %s, %s=%s is not allowed", s.namespace, list.key, value)
+			s.countFiltered(filterAllowlist)
+			return false
+		case actionStrip:
+			s.debugf("Removing %s=%s from %s", list.key, value, s.namespace)
+			delete(s.dimensions, list.key)
+		case actionRelabel:
+			s.debugf("Relabeling %s=%s of %s to %s", list.key, value, s.namespace, s.relabelValue)
+			s.dimensions[list.key] = s.relabelValue
+		}
+	}
+	return true
+}
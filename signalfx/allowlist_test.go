@@ -0,0 +1,68 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestDimensionAllowlist(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		env      string
+		sent     bool
+		value    string // environment sent, "" when absent
+	}{
+		{"allowed", plugin.Config{"dimension_value_allowlist": "environment=prod|staging|dev:drop"}, "prod", true, "prod"},
+		{"drop", plugin.Config{"dimension_value_allowlist": "environment=prod|staging|dev:drop"}, "qa", false, ""},
+		{"drop by default", plugin.Config{"dimension_value_allowlist": "environment=prod|staging"}, "qa", false, ""},
+		{"strip", plugin.Config{"dimension_value_allowlist": "environment=prod|staging|dev:strip"}, "qa", true, ""},
+		{"relabel", plugin.Config{"dimension_value_allowlist": "environment=prod|staging|dev:relabel"}, "qa", true, defaultRelabelValue},
+		{"relabel to the default", plugin.Config{
+			"dimension_value_allowlist": "environment=prod|staging|dev:relabel",
+			"dimension_value_default":   "other",
+		}, "qa", true, "other"},
+		{"unknown action ignored", plugin.Config{"dimension_value_allowlist": "environment=prod:rename"}, "qa", true, "qa"},
+		{"other key", plugin.Config{"dimension_value_allowlist": "region=us|eu:drop"}, "qa", true, "qa"},
+	}
+	for _, tt := range tests {
+		// Take the environment dimension from the namespace
+		settings := plugin.Config{"alias_rules": "/intel/cpu/{environment}/idle=intel.cpu.idle"}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		dps := publish(t, settings, newMetric(int64(1), "intel", "cpu", tt.env, "idle"))
+		dp, ok := dps["snap.intel.cpu.idle"]
+		if ok != tt.sent {
+			t.Errorf("%s: sent = %v, want %v", tt.name, ok, tt.sent)
+			continue
+		}
+		if ok && dp.Dimensions["environment"] != tt.value {
+			t.Errorf("%s: environment = %q, want %q", tt.name, dp.Dimensions["environment"], tt.value)
+		}
+	}
+}
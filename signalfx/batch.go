@@ -0,0 +1,105 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// batch - Datapoints of a publish sent together
+type batch struct {
+	route   []*target              // Targets of the datapoints
+	timeout time.Duration          // Timeout of the send (0 is none)
+	dps     []*datapoint.Datapoint // Datapoints to send
+}
+
+// addToBatch adds the datapoints to the publish's batch for the current
+// route and timeout, to be sent by flushBatches
+func (s *SignalFx) addToBatch(dps []*datapoint.Datapoint) {
+	for i := range s.batches {
+		if s.batches[i].timeout == s.timeout && sameRoute(s.batches[i].route, s.route) {
+			s.batches[i].dps = append(s.batches[i].dps, dps...)
+			return
+		}
+	}
+	s.batches = append(s.batches, batch{route: s.route, timeout: s.timeout, dps: dps})
+}
+
+// setWorkerPoolSize will set the number of batches of a publish sent at
+// once from the worker_pool_size setting, defaulting to one at a time
+func (s *SignalFx) setWorkerPoolSize(cfg plugin.Config) {
+	s.workers = 1
+
+	n, err := s.getInt(cfg, "worker_pool_size")
+	if err != nil || n <= 1 {
+		// No worker_pool_size defined, moving on
+		return
+	}
+	s.workers = int(n)
+
+	s.logf("Sending up to %d batches at once", n)
+}
+
+// flushBatches sends the datapoints batched so far, one request per route
+// and timeout rather than one per metric, on a fixed pool of
+// worker_pool_size workers that is gone once they are sent
+func (s *SignalFx) flushBatches() {
+	batches := s.batches
+	s.batches = nil
+
+	workers := s.workers
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+	if workers <= 1 {
+		for _, b := range batches {
+			s.deliverBatch(b)
+		}
+		return
+	}
+
+	jobs := make(chan batch)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				s.deliverBatch(b)
+			}
+		}()
+	}
+
+	for _, b := range batches {
+		jobs <- b
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// deliverBatch sends the batch to the targets of its route
+func (s *SignalFx) deliverBatch(b batch) {
+	s.debugf("Sending a batch of %d datapoints", len(b.dps))
+	s.deliver(b.route, b.timeout, b.dps)
+}
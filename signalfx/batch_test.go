@@ -0,0 +1,90 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestWorkerPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		workers  int
+	}{
+		{"one at a time by default", nil, 1},
+		{"pool of two", plugin.Config{"worker_pool_size": int64(2)}, 2},
+		{"pool of four", plugin.Config{"worker_pool_size": int64(4)}, 4},
+	}
+	for _, tt := range tests {
+		// Hold each request so that concurrent batches overlap
+		var mu sync.Mutex
+		var active, most int
+		is := newIngestServer()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			active++
+			if active > most {
+				most = active
+			}
+			mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+			is.handle(w, r)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}))
+
+		// Each timeout is a batch of its own
+		var mts []plugin.Metric
+		for i := 1; i <= 8; i++ {
+			m := newMetric(int64(i), "intel", "cpu", strconv.Itoa(i))
+			m.Tags = map[string]string{tagTimeout: strconv.Itoa(1000 * i)}
+			mts = append(mts, m)
+		}
+
+		s := newTestPlugin()
+		err := s.Publish(mts, testConfig(ts.URL, tt.settings))
+		ts.Close()
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+
+		if n := len(is.received()); n != len(mts) {
+			t.Errorf("%s: %d metrics sent, want %d", tt.name, n, len(mts))
+		}
+		if most != tt.workers {
+			t.Errorf("%s: %d batches sent at once, want %d", tt.name, most, tt.workers)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Circuit states, also the values of the circuit_state self metric
+const (
+	circuitClosed   = iota // Publishing normally
+	circuitOpen            // Failing fast
+	circuitHalfOpen        // Testing for recovery
+)
+
+// Default seconds the circuit stays open
+const defaultCircuitCooldown = 60
+
+// Returned by Publish while the circuit is open
+var errCircuitOpen = errors.New("circuit open, not publishing to SignalFx")
+
+// circuitBreaker - Stops publishing after repeated failures
+type circuitBreaker struct {
+	threshold int           // Consecutive failures opening the circuit (0 is disabled)
+	cooldown  time.Duration // How long the circuit stays open
+	failures  int           // Consecutive failures
+	state     int           // Circuit state
+	openedAt  time.Time     // When the circuit was opened
+	mu        sync.Mutex    // Guards the above
+
+	logf func(string, ...interface{}) // Logs state changes
+	now  func() time.Time             // Clock
+}
+
+// setCircuitBreaker will enable the circuit breaker if the
+// circuit_failure_threshold config setting is present in the task file
+func (s *SignalFx) setCircuitBreaker(cfg plugin.Config) {
+	threshold, err := s.getInt(cfg, "circuit_failure_threshold")
+	if err != nil || threshold <= 0 {
+		// No circuit_failure_threshold defined, moving on
+		return
+	}
+
+	cooldown := int64(defaultCircuitCooldown)
+	if n, err := s.getInt(cfg, "circuit_cooldown"); err == nil && n > 0 {
+		cooldown = n
+	}
+
+	s.breaker.threshold = int(threshold)
+	s.breaker.cooldown = time.Duration(cooldown) * time.Second
+	s.breaker.logf = s.logf
+	s.breaker.now = s.now
+
+	s.logf("Opening the circuit for %d seconds after %d failures", cooldown, threshold)
+}
+
+// allow reports whether a publish may go ahead. Once the cooldown has
+// passed, an open circuit becomes half-open to let a publish through.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.logf("Circuit half-open, testing SignalFx")
+		b.state = circuitHalfOpen
+	}
+	return true
+}
+
+// record updates the circuit with the outcome of a publish
+func (b *circuitBreaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.state != circuitClosed {
+			b.logf("Circuit closed, SignalFx recovered")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.logf("Circuit open after %d failures: %v", b.failures, err)
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// currentState returns the circuit state
+func (b *circuitBreaker) currentState() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
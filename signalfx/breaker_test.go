@@ -0,0 +1,155 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := errors.New("failed")
+
+	now := time.Now()
+	b := circuitBreaker{
+		threshold: 2,
+		cooldown:  time.Minute,
+		logf:      func(string, ...interface{}) {},
+		now:       func() time.Time { return now },
+	}
+
+	steps := []struct {
+		name    string
+		cool    bool  // Let the cooldown pass first
+		allowed bool  // Whether a publish is allowed
+		err     error // Outcome of the allowed publish
+		state   int   // State afterwards
+	}{
+		{"first failure", false, true, failure, circuitClosed},
+		{"threshold reached", false, true, failure, circuitOpen},
+		{"failing fast", false, false, nil, circuitOpen},
+		{"half-open test fails", true, true, failure, circuitOpen},
+		{"failing fast again", false, false, nil, circuitOpen},
+		{"half-open test succeeds", true, true, nil, circuitClosed},
+		{"failures counted afresh", false, true, failure, circuitClosed},
+	}
+	for _, step := range steps {
+		if step.cool {
+			now = now.Add(b.cooldown)
+		}
+		if got := b.allow(); got != step.allowed {
+			t.Fatalf("%s: allow() = %v, want %v", step.name, got, step.allowed)
+		}
+		if step.allowed {
+			if step.cool && b.currentState() != circuitHalfOpen {
+				t.Errorf("%s: circuit not half-open after the cooldown", step.name)
+			}
+			b.record(step.err)
+		}
+		if got := b.currentState(); got != step.state {
+			t.Errorf("%s: state %d, want %d", step.name, got, step.state)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	var b circuitBreaker
+	for i := 0; i < 10; i++ {
+		b.record(errors.New("failed"))
+	}
+	if !b.allow() || b.currentState() != circuitClosed {
+		t.Error("A disabled circuit opened")
+	}
+}
+
+func TestCircuitBreakerPublish(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+
+	is := newIngestServer()
+	defer is.Close()
+	is.status = func(int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}
+
+	now := time.Now()
+	s := newTestPlugin()
+	s.now = func() time.Time { return now }
+	cfg := testConfig(is.URL, plugin.Config{
+		"self_metrics":              true,
+		"circuit_failure_threshold": int64(2),
+	})
+	mts := []plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}
+
+	steps := []struct {
+		name  string
+		up    bool // Whether SignalFx is up
+		cool  bool // Let the cooldown pass first
+		sent  bool // Whether anything was sent
+		state int  // Circuit state afterwards
+	}{
+		{"first failure", false, false, true, circuitClosed},
+		{"opened", false, false, true, circuitOpen},
+		{"failing fast", true, false, false, circuitOpen},
+		{"recovered", true, true, true, circuitClosed},
+	}
+	for _, step := range steps {
+		mu.Lock()
+		down = !step.up
+		mu.Unlock()
+		if step.cool {
+			now = now.Add(s.breaker.cooldown)
+		}
+
+		requests := is.requestCount()
+		err := s.Publish(mts, cfg)
+		if step.sent && (err != nil) == step.up {
+			t.Errorf("%s: Publish returned %v with SignalFx up = %v", step.name, err, step.up)
+		}
+		if !step.sent && err != errCircuitOpen {
+			t.Errorf("%s: Publish returned %v, want %v", step.name, err, errCircuitOpen)
+		}
+		if sent := is.requestCount() > requests; sent != step.sent {
+			t.Errorf("%s: sent = %v, want %v", step.name, sent, step.sent)
+		}
+		if got := s.breaker.currentState(); got != step.state {
+			t.Errorf("%s: state %d, want %d", step.name, got, step.state)
+		}
+	}
+
+	// The recovered circuit is reported as closed
+	dp, ok := is.received()[selfMetricPrefix+"circuit_state"]
+	if !ok || dp.Value != int64(circuitClosed) {
+		t.Errorf("circuit_state = %v (%v), want %d", dp.Value, ok, circuitClosed)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Default seconds after which the series seen are forgotten
+const defaultSeriesWindow = 3600
+
+// seriesGuard - Limits the distinct series sent within a window
+type seriesGuard struct {
+	max    int                 // Maximum distinct series (0 is unlimited)
+	window time.Duration       // How long series are remembered
+	start  time.Time           // Start of the current window
+	seen   map[string]struct{} // Series seen in the current window
+	drops  int                 // New series dropped in the current window
+}
+
+// setMaxSeries will set the max_series limit and the series_window it
+// applies to
+func (s *SignalFx) setMaxSeries(cfg plugin.Config) {
+	max, err := s.getInt(cfg, "max_series")
+	if err != nil || max <= 0 {
+		// No max_series defined, moving on
+		return
+	}
+
+	window := int64(defaultSeriesWindow)
+	if n, err := s.getInt(cfg, "series_window"); err == nil && n > 0 {
+		window = n
+	}
+
+	s.series = seriesGuard{
+		max:    int(max),
+		window: time.Duration(window) * time.Second,
+		start:  s.now(),
+		seen:   make(map[string]struct{}),
+	}
+
+	s.logf("Limiting to %d series every %d seconds", max, window)
+}
+
+// acceptSeries reports whether the current series may be sent. Series
+// already seen in the window are always accepted; new ones are dropped
+// once max_series is reached, logging the first and, once the window is
+// over, how many were dropped.
+func (s *SignalFx) acceptSeries() bool {
+	if s.series.max <= 0 {
+		return true
+	}
+
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start a new window, reporting the series dropped in the last one
+	if s.now().Sub(s.series.start) >= s.series.window {
+		if s.series.drops > 0 {
+			s.warnf("Dropped %d new series over %d series in the last %v", s.series.drops, s.series.max, s.series.window)
+		}
+		s.series.start = s.now()
+		s.series.seen = make(map[string]struct{})
+		s.series.drops = 0
+	}
+
+	if _, ok := s.series.seen[key]; ok {
+		return true
+	}
+
+	// Log the first series dropped in the window, and count the rest
+	if len(s.series.seen) >= s.series.max {
+		if s.series.drops == 0 {
+			s.warnf("Dropping new series, over %d series, starting with %s %v", s.series.max, s.namespace, s.dimensions)
+		} else {
+			s.debugf("Dropping new series %s %v, over %d series", s.namespace, s.dimensions, s.series.max)
+		}
+		s.series.drops++
+		s.countFiltered(filterMaxSeries)
+		return false
+	}
+
+	s.series.seen[key] = struct{}{}
+	return true
+}
+
+// dimensionLimit - Limits the distinct values of a dimension key within a
+// window
+type dimensionLimit struct {
+	max  int                 // Maximum distinct values
+	seen map[string]struct{} // Values seen in the current window
+}
+
+// setDimensionCardinalityLimit will set the maximum distinct values of
+// dimension keys from the dimension_cardinality_limit setting, which is
+// "key=max,...", applying to the series_window
+func (s *SignalFx) setDimensionCardinalityLimit(cfg plugin.Config) {
+	value, err := s.getString(cfg, "dimension_cardinality_limit")
+	if err != nil {
+		// No dimension_cardinality_limit defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring dimension_cardinality_limit %q, expected key=max", entry)
+			continue
+		}
+
+		max, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || max <= 0 {
+			s.logf("Ignoring dimension_cardinality_limit %q, expected key=max", entry)
+			continue
+		}
+
+		if s.dimLimits == nil {
+			s.dimLimits = make(map[string]*dimensionLimit)
+		}
+		s.dimLimits[parts[0]] = &dimensionLimit{max: max, seen: make(map[string]struct{})}
+		s.logf("Limiting the %s dimension to %d values", parts[0], max)
+	}
+	if len(s.dimLimits) == 0 {
+		return
+	}
+
+	window := int64(defaultSeriesWindow)
+	if n, err := s.getInt(cfg, "series_window"); err == nil && n > 0 {
+		window = n
+	}
+	s.dimLimitWindow = time.Duration(window) * time.Second
+	s.dimLimitStart = s.now()
+}
+
+// limitDimensions strips the current metric's dimensions whose key has
+// reached its dimension_cardinality_limit with a value not seen in the
+// window, counting each one stripped
+func (s *SignalFx) limitDimensions() {
+	if len(s.dimLimits) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start a new window
+	if s.now().Sub(s.dimLimitStart) >= s.dimLimitWindow {
+		s.dimLimitStart = s.now()
+		for _, limit := range s.dimLimits {
+			limit.seen = make(map[string]struct{})
+		}
+	}
+
+	for key, limit := range s.dimLimits {
+		v, ok := s.dimensions[key]
+		if !ok {
+			continue
+		}
+		if _, ok := limit.seen[v]; ok {
+			continue
+		}
+
+		if len(limit.seen) >= limit.max {
+			s.debugf("Stripping %s=%s from %s, over %d values", key, v, s.namespace, limit.max)
+			delete(s.dimensions, key)
+			s.dimStripped++
+			continue
+		}
+		limit.seen[v] = struct{}{}
+	}
+}
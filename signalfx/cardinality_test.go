@@ -0,0 +1,191 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// cpuMetric returns the idle metric of the cpu
+func cpuMetric(cpu string) plugin.Metric {
+	return newMetric(int64(1), "intel", "cpu", cpu, "idle")
+}
+
+func TestMaxSeries(t *testing.T) {
+	cycles := publishEach(t, plugin.Config{"max_series": int64(2)},
+		[]plugin.Metric{cpuMetric("0"), cpuMetric("1"), cpuMetric("2")},
+		[]plugin.Metric{cpuMetric("2"), cpuMetric("0"), cpuMetric("1")},
+	)
+
+	tests := []struct {
+		cycle int
+		cpu   string
+		sent  bool
+	}{
+		{0, "0", true},
+		{0, "1", true},
+		{0, "2", false},
+		{1, "0", true},
+		{1, "1", true},
+		{1, "2", false},
+	}
+	for _, tt := range tests {
+		if _, ok := cycles[tt.cycle]["snap.intel.cpu."+tt.cpu+".idle"]; ok != tt.sent {
+			t.Errorf("Publish %d: cpu %s sent = %v, want %v", tt.cycle, tt.cpu, ok, tt.sent)
+		}
+	}
+}
+
+func TestMaxSeriesWindow(t *testing.T) {
+	now := time.Now()
+	s := newTestPlugin()
+	s.now = func() time.Time { return now }
+	s.setMaxSeries(plugin.Config{"max_series": int64(1), "series_window": int64(60)})
+
+	accept := func(cpu string) bool {
+		s.namespace = "snap.intel.cpu.idle"
+		s.dimensions = map[string]string{"cpu": cpu}
+		return s.acceptSeries()
+	}
+
+	if !accept("0") || accept("1") {
+		t.Fatal("The series cap was not applied")
+	}
+
+	// A new window forgets the series seen
+	now = now.Add(time.Minute)
+	if !accept("1") {
+		t.Error("A new series was dropped in a new window")
+	}
+	if accept("0") {
+		t.Error("A series from the previous window counted as known")
+	}
+}
+
+func TestMaxSeriesLog(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Now()
+	s := newTestPlugin()
+	s.now = func() time.Time { return now }
+	s.SetLogger(log.New(&out, "", 0))
+	s.logLevel = levelInfo
+	s.setMaxSeries(plugin.Config{"max_series": int64(1), "series_window": int64(60)})
+
+	s.namespace = "snap.intel.cpu.idle"
+	for _, cpu := range []string{"0", "1", "2", "3"} {
+		s.dimensions = map[string]string{"cpu": cpu}
+		s.acceptSeries()
+	}
+	if n := strings.Count(out.String(), "Dropping new series"); n != 1 {
+		t.Errorf("Logged %d dropped series, want only the first: %q", n, out.String())
+	}
+
+	// The next window reports how many were dropped
+	now = now.Add(time.Minute)
+	s.acceptSeries()
+	if !strings.Contains(out.String(), "Dropped 3 new series") {
+		t.Errorf("The dropped series were not counted: %q", out.String())
+	}
+}
+
+func TestDimensionCardinalityLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    string
+		cpus     []string // Cpu dimension sent for cpus 0, 1 and 2, "" when stripped
+		stripped string
+	}{
+		{"over the limit", "cpu=2", []string{"0", "1", ""}, "1"},
+		{"within the limit", "cpu=3", []string{"0", "1", "2"}, "0"},
+		{"other key", "disk=1", []string{"0", "1", "2"}, "0"},
+		{"malformed", "cpu=none", []string{"0", "1", "2"}, ""},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{cpuMetric("0"), cpuMetric("1"), cpuMetric("2")}, testConfig(is.URL, plugin.Config{
+			"alias_rules":                 "/intel/cpu/{cpu}/idle=intel.cpu.idle",
+			"dimension_cardinality_limit": tt.limit,
+			"self_metrics":                true,
+		}))
+		is.Close()
+		if err != nil {
+			t.Fatalf("%s: Publish returned %v", tt.name, err)
+		}
+
+		// Every datapoint is sent, with or without its cpu dimension
+		var cpus []string
+		for _, dp := range is.datapoints {
+			if dp.Metric == "snap.intel.cpu.idle" {
+				cpus = append(cpus, dp.Dimensions["cpu"])
+			}
+		}
+		if !reflect.DeepEqual(cpus, tt.cpus) {
+			t.Errorf("%s: sent cpus %q, want %q", tt.name, cpus, tt.cpus)
+		}
+
+		var stripped string
+		if dp, ok := is.received()[selfMetricPrefix+"dimensions_stripped"]; ok {
+			stripped = fmt.Sprint(dp.Value)
+		}
+		if stripped != tt.stripped {
+			t.Errorf("%s: dimensions_stripped %q, want %q", tt.name, stripped, tt.stripped)
+		}
+	}
+}
+
+func TestDimensionCardinalityWindow(t *testing.T) {
+	now := time.Now()
+	s := newTestPlugin()
+	s.now = func() time.Time { return now }
+	s.setDimensionCardinalityLimit(plugin.Config{"dimension_cardinality_limit": "cpu=1", "series_window": int64(60)})
+
+	kept := func(cpu string) bool {
+		s.namespace = "snap.intel.cpu.idle"
+		s.dimensions = map[string]string{"cpu": cpu}
+		s.limitDimensions()
+		_, ok := s.dimensions["cpu"]
+		return ok
+	}
+
+	if !kept("0") || kept("1") || !kept("0") {
+		t.Fatal("The dimension cardinality limit was not applied")
+	}
+
+	// A new window forgets the values seen
+	now = now.Add(time.Minute)
+	if !kept("1") {
+		t.Error("A new value was stripped in a new window")
+	}
+	if kept("0") {
+		t.Error("A value from the previous window counted as known")
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Constants
+const (
+	defaultChangeHeartbeat = 10    // Cycles between forced sends
+	maxTrackedSeries       = 10000 // Series remembered for change detection
+)
+
+// lastSent - The last value sent for a series
+type lastSent struct {
+	value   interface{} // Value sent
+	skipped int64       // Cycles suppressed since
+}
+
+// lastSentUpdate - A value to remember as the last sent for its series,
+// once its datapoint is sent
+type lastSentUpdate struct {
+	deadband bool        // Remembered for the deadband, not send_on_change
+	key      string      // Series
+	value    interface{} // Value sent
+}
+
+// setSendOnChange will enable suppressing unchanged values if the
+// send_on_change config setting is present in the task file
+func (s *SignalFx) setSendOnChange(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "send_on_change")
+	if err != nil || !enabled {
+		return
+	}
+	s.sendOnChange = true
+	s.changes = make(map[string]*lastSent)
+
+	s.changeHeartbeat = defaultChangeHeartbeat
+	if n, err := s.getInt(cfg, "change_heartbeat"); err == nil && n > 0 {
+		s.changeHeartbeat = n
+	}
+
+	s.logf("Sending unchanged values every %d cycles", s.changeHeartbeat)
+}
+
+// unchanged reports whether the value equals the one last sent for the
+// current series and should be suppressed. Every change_heartbeat cycles
+// the value is sent anyway so the series does not appear to stop. A value
+// not suppressed is only remembered once it is sent.
+func (s *SignalFx) unchanged(value interface{}) bool {
+	if !s.sendOnChange {
+		return false
+	}
+
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.changes[key]
+	if ok && last.value == value && last.skipped+1 < s.changeHeartbeat {
+		last.skipped++
+		return true
+	}
+
+	s.pendingValues = append(s.pendingValues, lastSentUpdate{key: key, value: value})
+	return false
+}
+
+// awaitSend will remember the current metric's values as the last sent
+// once the datapoints are
+func (s *SignalFx) awaitSend(dps []*datapoint.Datapoint) {
+	if len(s.pendingValues) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dp := range dps {
+		s.awaiting[dp] = s.pendingValues
+	}
+}
+
+// rememberSent will remember the values of the datapoints as the last sent
+// for their series if they were sent, forgetting them otherwise so they
+// are compared against the value SignalFx last received; s.mu must be held
+func (s *SignalFx) rememberSent(dps []*datapoint.Datapoint, sent bool) {
+	for _, dp := range dps {
+		updates, ok := s.awaiting[dp]
+		if !ok {
+			continue
+		}
+		delete(s.awaiting, dp)
+
+		if sent {
+			s.updateLastSent(updates)
+		}
+	}
+}
+
+// updateLastSent will remember the values as the last sent for their
+// series; s.mu must be held
+func (s *SignalFx) updateLastSent(updates []lastSentUpdate) {
+	for _, u := range updates {
+		last, name := &s.changes, "change detection"
+		if u.deadband {
+			last, name = &s.deadbandLast, "deadbands"
+		}
+
+		// Forget everything rather than grow without bound
+		if _, ok := (*last)[u.key]; !ok && len(*last) >= maxTrackedSeries {
+			s.logf("Tracking over %d series, resetting %s", maxTrackedSeries, name)
+			*last = make(map[string]*lastSent)
+		}
+
+		(*last)[u.key] = &lastSent{value: u.value}
+	}
+}
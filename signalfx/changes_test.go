@@ -0,0 +1,144 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestSendOnChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		values   []int64
+		sent     []bool
+	}{
+		{"disabled", plugin.Config{}, []int64{1, 1, 1}, []bool{true, true, true}},
+		{"unchanged", plugin.Config{"send_on_change": true}, []int64{1, 1, 1}, []bool{true, false, false}},
+		{"changed", plugin.Config{"send_on_change": true}, []int64{1, 2, 2, 3}, []bool{true, true, false, true}},
+		{"forced send", plugin.Config{"send_on_change": true, "change_heartbeat": int64(3)},
+			[]int64{1, 1, 1, 1, 1, 1, 1}, []bool{true, false, false, true, false, false, true}},
+		{"changed resets the heartbeat", plugin.Config{"send_on_change": true, "change_heartbeat": int64(3)},
+			[]int64{1, 1, 2, 2, 2, 2}, []bool{true, false, true, false, false, true}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for _, v := range tt.values {
+			cycles = append(cycles, []plugin.Metric{newMetric(v, "intel", "cpu", "idle")})
+		}
+
+		var sent []bool
+		for _, dps := range publishEach(t, tt.settings, cycles...) {
+			_, ok := dps["snap.intel.cpu.idle"]
+			sent = append(sent, ok)
+		}
+		if !reflect.DeepEqual(sent, tt.sent) {
+			t.Errorf("%s: sent %v, want %v", tt.name, sent, tt.sent)
+		}
+	}
+}
+
+func TestSendOnChangeBySeries(t *testing.T) {
+	metric := func(v int64, cpu string) plugin.Metric {
+		return newMetric(v, "intel", "cpu", cpu, "idle")
+	}
+	cycles := publishEach(t, plugin.Config{
+		"send_on_change": true,
+		"alias_rules":    "/intel/cpu/{cpu}/idle=intel.cpu.idle",
+	},
+		[]plugin.Metric{metric(1, "0"), metric(1, "1")},
+		[]plugin.Metric{metric(1, "0"), metric(2, "1")},
+	)
+
+	dp, ok := cycles[1]["snap.intel.cpu.idle"]
+	if !ok {
+		t.Fatal("The changed series was not sent")
+	}
+	if cpu := dp.Dimensions["cpu"]; cpu != "1" {
+		t.Errorf("Sent the series of cpu %s, want only the changed one", cpu)
+	}
+	if n := len(cycles[1]); n != 1 {
+		t.Errorf("Sent %d datapoints, want only the changed one", n)
+	}
+}
+
+func TestSendOnChangeFailedSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		values   []float64
+	}{
+		{"send_on_change", plugin.Config{"send_on_change": true}, []float64{1, 2, 2}},
+		{"deadband", plugin.Config{"deadband": "/intel/cpu=0.5"}, []float64{10, 11, 11}},
+	}
+	for _, tt := range tests {
+		// Fail the second value's request
+		is := newIngestServer()
+		is.status = func(request int) int {
+			if request == 2 {
+				return http.StatusBadRequest
+			}
+			return http.StatusOK
+		}
+
+		s := newTestPlugin()
+		cfg := testConfig(is.URL, tt.settings)
+		for _, v := range tt.values {
+			s.Publish([]plugin.Metric{newMetric(v, "intel", "cpu", "idle")}, cfg)
+		}
+		is.Close()
+
+		// The failed value was not remembered, so it is sent again
+		var sent []float64
+		is.mu.Lock()
+		for _, dp := range is.datapoints {
+			sent = append(sent, dp.Value.(float64))
+		}
+		is.mu.Unlock()
+		if want := []float64{tt.values[0], tt.values[2]}; !reflect.DeepEqual(sent, want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, sent, want)
+		}
+	}
+}
+
+func TestSendOnChangeBounded(t *testing.T) {
+	s := newTestPlugin()
+	s.setSendOnChange(plugin.Config{"send_on_change": true})
+	for i := 0; i < maxTrackedSeries; i++ {
+		s.changes[strconv.Itoa(i)] = &lastSent{value: int64(1)}
+	}
+
+	s.namespace = "snap.intel.cpu.idle"
+	if s.unchanged(int64(1)) {
+		t.Error("A new series was suppressed")
+	}
+	s.updateLastSent(s.pendingValues)
+	if len(s.changes) != 1 {
+		t.Errorf("Tracking %d series, want the new one only", len(s.changes))
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// How long fetching the cloud metadata may take in all
+const cloudMetadataTimeout = 2 * time.Second
+
+// cloudField - A metadata value sent as a dimension
+type cloudField struct {
+	dimension string // Dimension name
+	path      string // Path under the provider's metadata URL
+}
+
+// cloudProvider - Where and how to query an instance metadata service
+type cloudProvider struct {
+	url    string            // Metadata URL
+	header map[string]string // Headers the service requires
+	fields []cloudField      // Values sent as dimensions
+}
+
+// Instance metadata services by cloud_metadata provider
+var cloudProviders = map[string]cloudProvider{
+	"aws": {
+		url: "http://169.254.169.254/latest/meta-data/",
+		fields: []cloudField{
+			{"aws_instance_id", "instance-id"},
+			{"availability_zone", "placement/availability-zone"},
+		},
+	},
+	"gcp": {
+		url:    "http://metadata.google.internal/computeMetadata/v1/instance/",
+		header: map[string]string{"Metadata-Flavor": "Google"},
+		fields: []cloudField{
+			{"gcp_instance_id", "id"},
+			{"availability_zone", "zone"},
+		},
+	},
+	"azure": {
+		url:    "http://169.254.169.254/metadata/instance/compute/",
+		header: map[string]string{"Metadata": "true"},
+		fields: []cloudField{
+			{"azure_vm_id", "vmId?api-version=2017-08-01&format=text"},
+			{"availability_zone", "zone?api-version=2017-08-01&format=text"},
+		},
+	},
+}
+
+// setCloudMetadata will query the instance metadata of the provider named
+// by the cloud_metadata setting once, sending the results as dimensions
+// with every datapoint. Values that cannot be fetched in time are left out.
+func (s *SignalFx) setCloudMetadata(cfg plugin.Config) {
+	name, err := s.getString(cfg, "cloud_metadata")
+	if err != nil {
+		// No cloud_metadata defined, moving on
+		return
+	}
+
+	provider, ok := cloudProviders[name]
+	if !ok {
+		s.logf("Unknown cloud_metadata provider %q, ignoring", name)
+		return
+	}
+
+	s.cloudDims = map[string]string{"cloud_provider": name}
+	client := &http.Client{Timeout: cloudMetadataTimeout}
+	deadline := time.Now().Add(cloudMetadataTimeout)
+
+	for _, field := range provider.fields {
+		if time.Now().After(deadline) {
+			s.logf("Timed out fetching %s metadata, skipping %s", name, field.dimension)
+			continue
+		}
+
+		value, err := fetchMetadata(client, provider, field.path)
+		if err != nil {
+			s.logf("Unable to fetch %s metadata %s: %v", name, field.path, err)
+			continue
+		}
+
+		// GCP zones are projects/<number>/zones/<zone>
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			value = value[i+1:]
+		}
+		if value != "" {
+			s.cloudDims[field.dimension] = value
+		}
+	}
+
+	s.logf("Sending %s metadata %v as dimensions", name, s.cloudDims)
+}
+
+// fetchMetadata returns the metadata value at the path
+func fetchMetadata(client *http.Client, provider cloudProvider, path string) (string, error) {
+	req, err := http.NewRequest("GET", provider.url+path, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range provider.header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,128 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// metadataServer starts a mock instance metadata service answering the
+// paths, when the request has the header, if any
+func metadataServer(header map[string]string, values map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			if r.Header.Get(k) != v {
+				http.Error(w, "missing header "+k, http.StatusForbidden)
+				return
+			}
+		}
+		value, ok := values[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, value+"\n")
+	}))
+}
+
+func TestCloudMetadata(t *testing.T) {
+	aws := metadataServer(nil, map[string]string{
+		"/instance-id":                 "i-0123456789abcdef0",
+		"/placement/availability-zone": "us-east-1a",
+	})
+	defer aws.Close()
+	gcp := metadataServer(map[string]string{"Metadata-Flavor": "Google"}, map[string]string{
+		"/id":   "4520031799277581759",
+		"/zone": "projects/123456789/zones/us-central1-b",
+	})
+	defer gcp.Close()
+	azure := metadataServer(map[string]string{"Metadata": "true"}, map[string]string{
+		"/vmId?api-version=2017-08-01&format=text": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+	})
+	defer azure.Close()
+	down := metadataServer(nil, nil)
+	down.Close()
+
+	// Point the providers at the mock services
+	saved := cloudProviders
+	defer func() { cloudProviders = saved }()
+	cloudProviders = make(map[string]cloudProvider)
+	for name, url := range map[string]string{"aws": aws.URL, "gcp": gcp.URL, "azure": azure.URL} {
+		provider := saved[name]
+		provider.url = url + "/"
+		cloudProviders[name] = provider
+	}
+	unreachable := saved["aws"]
+	unreachable.url = down.URL + "/"
+	cloudProviders["unreachable"] = unreachable
+
+	tests := []struct {
+		name       string
+		provider   interface{}
+		dimensions map[string]string // Expected dimension, "" when absent
+	}{
+		{"aws", "aws", map[string]string{
+			"cloud_provider":    "aws",
+			"aws_instance_id":   "i-0123456789abcdef0",
+			"availability_zone": "us-east-1a",
+		}},
+		{"gcp", "gcp", map[string]string{
+			"cloud_provider":    "gcp",
+			"gcp_instance_id":   "4520031799277581759",
+			"availability_zone": "us-central1-b",
+		}},
+		{"missing value left out", "azure", map[string]string{
+			"cloud_provider":    "azure",
+			"azure_vm_id":       "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+			"availability_zone": "",
+		}},
+		{"service unreachable", "unreachable", map[string]string{
+			"cloud_provider":    "unreachable",
+			"aws_instance_id":   "",
+			"availability_zone": "",
+		}},
+		{"unknown provider", "openstack", map[string]string{"cloud_provider": ""}},
+		{"absent", nil, map[string]string{"cloud_provider": ""}},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{}
+		if tt.provider != nil {
+			settings["cloud_metadata"] = tt.provider
+		}
+		dp, ok := publish(t, settings, newMetric(int64(1), "intel", "cpu", "idle"))["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		for key, want := range tt.dimensions {
+			if got := dp.Dimensions[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
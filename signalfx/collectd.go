@@ -0,0 +1,79 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// setCollectdCompat will enable collectd-style dimensions if the
+// collectd_compat config setting is present in the task file
+func (s *SignalFx) setCollectdCompat(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "collectd_compat")
+	if err != nil || !enabled {
+		return
+	}
+	s.collectd = true
+
+	if value, err := s.getString(cfg, "collectd_namespaces"); err == nil {
+		s.collectdNS = splitList(value)
+	}
+
+	s.logf("Using collectd-style dimensions for %v", s.collectdNS)
+}
+
+// collectdCompatFor reports whether collectd-style dimensions should be
+// derived for the namespace; with no namespaces configured, all match
+func (s *SignalFx) collectdCompatFor(namespace string) bool {
+	if !s.collectd {
+		return false
+	}
+	return len(s.collectdNS) == 0 || hasAnyPrefix(namespace, s.collectdNS)
+}
+
+// collectdDimensions maps the namespace onto the collectd dimensions. The
+// first element is the vendor and is skipped, so that for example
+// /intel/procfs/iface/eth0/bytes_recv becomes plugin=procfs,
+// plugin_instance=iface, type=eth0, type_instance=bytes_recv.
+func collectdDimensions(ns []string) map[string]string {
+	dims := make(map[string]string)
+	if len(ns) < 2 {
+		return dims
+	}
+
+	elements := ns[1:]
+	dims["plugin"] = elements[0]
+
+	switch len(elements) {
+	case 1:
+	case 2:
+		dims["type"] = elements[1]
+	case 3:
+		dims["type"] = elements[1]
+		dims["type_instance"] = elements[2]
+	default:
+		dims["plugin_instance"] = elements[1]
+		dims["type"] = elements[2]
+		dims["type_instance"] = strings.Join(elements[3:], ".")
+	}
+	return dims
+}
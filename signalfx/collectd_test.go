@@ -0,0 +1,82 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestCollectdDimensions(t *testing.T) {
+	tests := []struct {
+		ns   []string
+		want map[string]string
+	}{
+		{[]string{"intel"}, map[string]string{}},
+		{[]string{"intel", "load"}, map[string]string{"plugin": "load"}},
+		{[]string{"intel", "load", "load1"}, map[string]string{"plugin": "load", "type": "load1"}},
+		{[]string{"intel", "cpu", "cpu0", "idle"}, map[string]string{
+			"plugin": "cpu", "type": "cpu0", "type_instance": "idle",
+		}},
+		{[]string{"intel", "procfs", "iface", "eth0", "bytes_recv"}, map[string]string{
+			"plugin": "procfs", "plugin_instance": "iface", "type": "eth0", "type_instance": "bytes_recv",
+		}},
+		{[]string{"intel", "procfs", "iface", "eth0", "packets", "recv"}, map[string]string{
+			"plugin": "procfs", "plugin_instance": "iface", "type": "eth0", "type_instance": "packets.recv",
+		}},
+	}
+	for _, tt := range tests {
+		if got := collectdDimensions(tt.ns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("collectdDimensions(%v) = %v, want %v", tt.ns, got, tt.want)
+		}
+	}
+}
+
+func TestCollectdCompat(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  plugin.Config
+		namespace string
+		want      bool
+	}{
+		{"disabled", plugin.Config{}, "/intel/procfs/iface/eth0/bytes_recv", false},
+		{"all namespaces", plugin.Config{"collectd_compat": true}, "/intel/procfs/iface/eth0/bytes_recv", true},
+		{"matching namespace", plugin.Config{
+			"collectd_compat":     true,
+			"collectd_namespaces": "/intel/procfs",
+		}, "/intel/procfs/iface/eth0/bytes_recv", true},
+		{"other namespace", plugin.Config{
+			"collectd_compat":     true,
+			"collectd_namespaces": "/intel/psutil",
+		}, "/intel/procfs/iface/eth0/bytes_recv", false},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setCollectdCompat(tt.settings)
+		if got := s.collectdCompatFor(tt.namespace); got != tt.want {
+			t.Errorf("%s: collectdCompatFor(%q) = %v, want %v", tt.name, tt.namespace, got, tt.want)
+		}
+	}
+}
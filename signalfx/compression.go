@@ -0,0 +1,56 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// setCompressionMinBytes will set the approximate payload size below which
+// requests are sent uncompressed, since gzip is not worth it for tiny
+// batches
+func (s *SignalFx) setCompressionMinBytes(cfg plugin.Config) {
+	min, err := s.getInt(cfg, "compression_min_bytes")
+	if err != nil || min <= 0 {
+		// No compression_min_bytes defined, moving on
+		return
+	}
+	s.compressMin = min
+
+	s.logf("Compressing payloads of %d bytes or more", min)
+}
+
+// addProtobuf sends the datapoints as protobuf, compressed only when they
+// reach compression_min_bytes, if set. Compression is a setting of the
+// sink, so sends choosing it take turns.
+func (s *SignalFx) addProtobuf(ctx context.Context, sink *sfxclient.HTTPDatapointSink, dps []*datapoint.Datapoint) error {
+	if s.compressMin <= 0 {
+		return sink.AddDatapoints(ctx, dps)
+	}
+
+	s.compressMu.Lock()
+	defer s.compressMu.Unlock()
+
+	sink.DisableCompression = approximateSize(dps) < s.compressMin
+	return sink.AddDatapoints(ctx, dps)
+}
@@ -0,0 +1,90 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestCompressionMinBytes(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		min        interface{}
+		datapoints int
+		gzipped    bool
+	}{
+		{"small protobuf batch", formatProtobuf, int64(2000), 1, false},
+		{"large protobuf batch", formatProtobuf, int64(2000), 200, true},
+		{"protobuf default", formatProtobuf, nil, 1, true},
+		{"small json batch", formatJSON, int64(2000), 1, false},
+		{"large json batch", formatJSON, int64(2000), 200, true},
+		{"json default", formatJSON, nil, 1, true},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var encodings []string
+		is := newIngestServer()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			encodings = append(encodings, r.Header.Get("Content-Encoding"))
+			mu.Unlock()
+			is.handle(w, r)
+		}))
+
+		settings := plugin.Config{"payload_format": tt.format}
+		if tt.min != nil {
+			settings["compression_min_bytes"] = tt.min
+		}
+		var dps []*datapoint.Datapoint
+		for i := 0; i < tt.datapoints; i++ {
+			dps = append(dps, sfxclient.Gauge("cpu.idle", map[string]string{"cpu": strconv.Itoa(i)}, int64(i)))
+		}
+
+		s := newTestPlugin()
+		s.init(testConfig(ts.URL, settings))
+		s.send(dps...)
+		s.flushBatches()
+		ts.Close()
+		is.Close()
+		if s.lastErr != nil {
+			t.Errorf("%s: send failed with %v", tt.name, s.lastErr)
+			continue
+		}
+
+		if len(encodings) != 1 || (encodings[0] == "gzip") != tt.gzipped {
+			t.Errorf("%s: sent content encodings %q, want gzipped %v", tt.name, encodings, tt.gzipped)
+		}
+		if n := len(is.datapoints); tt.format == formatJSON && n != tt.datapoints {
+			t.Errorf("%s: %d datapoints received, want %d", tt.name, n, tt.datapoints)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// String config values that stand in for an absent value
+var nullValues = map[string]bool{
+	"null":  true,
+	"nil":   true,
+	"<nil>": true,
+}
+
+// getString returns the string config value, treating the values config
+// serialization produces for an absent value (e.g. "null") as unset
+func getString(cfg plugin.Config, key string) (string, error) {
+	value, err := cfg.GetString(key)
+	if err != nil {
+		return "", err
+	}
+	if nullValues[strings.ToLower(strings.TrimSpace(value))] {
+		return "", plugin.ErrConfigNotFound
+	}
+	return value, nil
+}
+
+// configValue warns about an optional config value of the wrong type and
+// reports it as absent, so the setting keeps its default rather than the
+// mismatch failing the publish
+func (s *SignalFx) configValue(key string, err error) error {
+	if err != nil && err != plugin.ErrConfigNotFound {
+		s.warnf("Ignoring %s, using its default: %v", key, err)
+		return plugin.ErrConfigNotFound
+	}
+	return err
+}
+
+// getString returns the optional string config value
+func (s *SignalFx) getString(cfg plugin.Config, key string) (string, error) {
+	value, err := getString(cfg, key)
+	return value, s.configValue(key, err)
+}
+
+// getInt returns the optional int config value
+func (s *SignalFx) getInt(cfg plugin.Config, key string) (int64, error) {
+	value, err := cfg.GetInt(key)
+	return value, s.configValue(key, err)
+}
+
+// getFloat returns the optional float config value
+func (s *SignalFx) getFloat(cfg plugin.Config, key string) (float64, error) {
+	value, err := cfg.GetFloat(key)
+	return value, s.configValue(key, err)
+}
+
+// getBool returns the optional bool config value
+func (s *SignalFx) getBool(cfg plugin.Config, key string) (bool, error) {
+	value, err := cfg.GetBool(key)
+	return value, s.configValue(key, err)
+}
+
+// splitList splits a comma separated config value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// hasAnyPrefix reports whether the namespace starts with one of the prefixes
+func hasAnyPrefix(namespace string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(namespace, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesKey returns a key identifying a metric and its dimensions
+func seriesKey(name string, dimensions map[string]string) string {
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{name}
+	for _, k := range keys {
+		parts = append(parts, k+"="+dimensions[k])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// contains reports whether the list holds the value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
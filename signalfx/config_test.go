@@ -0,0 +1,170 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// Config values standing in for an absent value
+var sentinels = []string{"null", "NULL", "nil", " nil ", "<nil>"}
+
+func TestGetStringSentinels(t *testing.T) {
+	for _, value := range sentinels {
+		if got, err := getString(plugin.Config{"key": value}, "key"); err != plugin.ErrConfigNotFound {
+			t.Errorf("getString(%q) = %q, %v, want it unset", value, got, err)
+		}
+	}
+
+	for _, value := range []string{"nullable", "nil-host", "ABCD1234"} {
+		if got, err := getString(plugin.Config{"key": value}, "key"); err != nil || got != value {
+			t.Errorf("getString(%q) = %q, %v", value, got, err)
+		}
+	}
+}
+
+func TestTokenSentinels(t *testing.T) {
+	for _, value := range sentinels {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("token %q was used as a token", value)
+				}
+			}()
+			newTestPlugin().setToken(plugin.Config{"token": value})
+		}()
+	}
+}
+
+func TestHostnameSentinels(t *testing.T) {
+	want, err := os.Hostname()
+	if err != nil {
+		want = "localhost"
+	}
+
+	for _, value := range sentinels {
+		s := newTestPlugin()
+		s.setHostname(plugin.Config{"hostname": value})
+		if s.hostname != want {
+			t.Errorf("hostname %q: using %q, want %q", value, s.hostname, want)
+		}
+	}
+}
+
+func TestEndpointSentinels(t *testing.T) {
+	want := sfxclient.NewHTTPDatapointSink().Endpoint
+
+	for _, value := range sentinels {
+		s := newTestPlugin()
+		s.setSinks(plugin.Config{"endpoint": value})
+		if got := s.targets[0].sink.Endpoint; got != want {
+			t.Errorf("endpoint %q: sending to %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestConfigTypeMismatch(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		get   func(s *SignalFx, cfg plugin.Config, key string) error
+	}{
+		{"prefix", int64(5), func(s *SignalFx, cfg plugin.Config, key string) error {
+			_, err := s.getString(cfg, key)
+			return err
+		}},
+		{"max_series", "ten", func(s *SignalFx, cfg plugin.Config, key string) error {
+			_, err := s.getInt(cfg, key)
+			return err
+		}},
+		{"min_abs_float", int64(1), func(s *SignalFx, cfg plugin.Config, key string) error {
+			_, err := s.getFloat(cfg, key)
+			return err
+		}},
+		{"all_counters", "yes", func(s *SignalFx, cfg plugin.Config, key string) error {
+			_, err := s.getBool(cfg, key)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := newTestPlugin()
+		s.SetLogger(log.New(&out, "", 0))
+
+		// A mismatch reads as an absent value, with a warning
+		if err := tt.get(s, plugin.Config{tt.key: tt.value}, tt.key); err != plugin.ErrConfigNotFound {
+			t.Errorf("%s: mismatched value returned %v, want it unset", tt.key, err)
+		}
+		if !strings.Contains(out.String(), "Ignoring "+tt.key) {
+			t.Errorf("%s: no warning was logged: %q", tt.key, out.String())
+		}
+
+		out.Reset()
+		if err := tt.get(s, plugin.Config{}, tt.key); err != plugin.ErrConfigNotFound {
+			t.Errorf("%s: absent value returned %v", tt.key, err)
+		}
+		if out.Len() > 0 {
+			t.Errorf("%s: absent value logged %q", tt.key, out.String())
+		}
+	}
+}
+
+func TestConfigTypeMismatchPublish(t *testing.T) {
+	// Each mismatched optional setting keeps its default
+	dps := publish(t, plugin.Config{
+		"float_precision": "2",
+		"all_counters":    "yes",
+		"self_metrics":    "yes",
+		"max_series":      1.5,
+	},
+		newMetric(1.23456, "intel", "load", "load1"),
+		newMetric(int64(2), "intel", "cpu", "idle"),
+	)
+
+	dp, ok := dps["snap.intel.load.load1"]
+	if !ok || dp.Value != 1.23456 || dp.Type != "gauge" {
+		t.Errorf("load1 sent %s %v (%v), want it an unrounded gauge", dp.Type, dp.Value, ok)
+	}
+	if _, ok := dps["snap.intel.cpu.idle"]; !ok {
+		t.Error("The second series was dropped")
+	}
+	if _, ok := dps[selfMetricPrefix+"metrics_received"]; ok {
+		t.Error("Self metrics were sent")
+	}
+}
+
+func TestConfigTypeMismatchRequired(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("A token of the wrong type was accepted")
+		}
+	}()
+	newTestPlugin().setToken(plugin.Config{"token": int64(1234)})
+}
@@ -0,0 +1,272 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Default counter width in bits
+const defaultCounterWidth = 64
+
+// Actions taken on cumulative counters that decreased
+const (
+	monotonicSkip = "skip" // Skip the datapoint
+	monotonicMark = "mark" // Send it with the counter_reset property
+)
+
+// Property marking a cumulative counter that decreased
+const counterResetProperty = "counter_reset"
+
+// deltaRule - A namespace prefix whose values are sent as deltas
+type deltaRule struct {
+	prefix string // Snap namespace prefix (e.g. /intel/procfs/iface)
+	width  uint   // Counter width in bits (32 or 64)
+}
+
+// setAllCounters will send every metric as a cumulative counter if the
+// all_counters config setting is present in the task file
+func (s *SignalFx) setAllCounters(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "all_counters")
+	if err != nil || !enabled {
+		return
+	}
+	s.allCounters = true
+
+	s.logln("Sending all metrics as cumulative counters")
+}
+
+// setCounterSuffixes will send the metrics whose namespace ends with one of
+// the counter_suffixes, e.g. "/bytes_sent,/requests_total", as cumulative
+// counters
+func (s *SignalFx) setCounterSuffixes(cfg plugin.Config) {
+	value, err := s.getString(cfg, "counter_suffixes")
+	if err != nil {
+		// No counter_suffixes defined, moving on
+		return
+	}
+	s.counterSuffixes = splitList(value)
+
+	s.logf("Sending namespaces ending with %v as cumulative counters", s.counterSuffixes)
+}
+
+// isCumulative reports whether the metric is sent as a cumulative counter:
+// per its sfx_metric_type tag, if any, otherwise when all_counters is set
+// or its namespace ends with one of the counter_suffixes
+func (s *SignalFx) isCumulative(m plugin.Metric) bool {
+	if value, ok := m.Tags[tagMetricType]; ok {
+		switch value {
+		case "counter", "cumulative_counter":
+			return true
+		case "gauge":
+			return false
+		}
+		s.logf("Ignoring invalid %s tag %q", tagMetricType, value)
+	}
+
+	if s.allCounters {
+		return true
+	}
+	namespace := m.Namespace.String()
+	for _, suffix := range s.counterSuffixes {
+		if strings.HasSuffix(namespace, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setDeltaCounters will parse the delta_counters setting; each entry is
+// a namespace prefix with an optional counter width, e.g.
+// "/intel/procfs/iface:32,/intel/psutil/net:64"
+func (s *SignalFx) setDeltaCounters(cfg plugin.Config) {
+	s.counters = make(map[string]uint64)
+
+	value, err := s.getString(cfg, "delta_counters")
+	if err != nil {
+		// No delta_counters defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		rule := deltaRule{prefix: entry, width: defaultCounterWidth}
+
+		if i := strings.LastIndex(entry, ":"); i >= 0 {
+			rule.prefix = entry[:i]
+
+			width, err := strconv.ParseUint(entry[i+1:], 10, 0)
+			if err != nil || (width != 32 && width != 64) {
+				s.logf("Invalid counter width in %q, using %d bits", entry, defaultCounterWidth)
+			} else {
+				rule.width = uint(width)
+			}
+		}
+
+		s.logf("Sending %s as %d-bit delta counters", rule.prefix, rule.width)
+		s.deltas = append(s.deltas, rule)
+	}
+
+	if n, err := s.getInt(cfg, "min_delta"); err == nil && n > 0 {
+		s.minDelta = uint64(n)
+		s.logf("Skipping deltas below %d", n)
+	}
+}
+
+// deltaRuleFor returns the delta rule matching the namespace, if any
+func (s *SignalFx) deltaRuleFor(namespace string) (deltaRule, bool) {
+	for _, rule := range s.deltas {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule, true
+		}
+	}
+	return deltaRule{}, false
+}
+
+// sendDelta will send the difference between the value and the previous
+// value seen for the current series. The first value seen is only
+// recorded since there is nothing to compare it against, and deltas below
+// min_delta are recorded but not sent.
+func (s *SignalFx) sendDelta(rule deltaRule, value uint64) {
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	previous, seen := s.counters[key]
+	if !seen && len(s.counters) >= maxTrackedSeries {
+		// Forget everything rather than grow without bound
+		s.logf("Tracking over %d series, resetting delta counters", maxTrackedSeries)
+		s.counters = make(map[string]uint64)
+	}
+	s.counters[key] = value
+	s.mu.Unlock()
+
+	if !seen {
+		s.logf("Recorded first value for %s", s.namespace)
+		return
+	}
+
+	delta, wrapped := counterDelta(previous, value, rule.width)
+	if !wrapped && value < previous {
+		// Too far back to be a wrap, the new value is the next baseline
+		s.warnf("Skipping %s, counter reset from %d to %d", s.namespace, previous, value)
+		return
+	}
+	if delta < s.minDelta {
+		s.debugf("Skipping %s delta %d below %d", s.namespace, delta, s.minDelta)
+		return
+	}
+
+	s.sendCounterValue(int64(delta))
+}
+
+// counterDelta returns the forward distance from previous to current for a
+// counter of the given width, and whether the counter wrapped around to get
+// there. A decrease only counts as a wrap when the forward distance is
+// within half the counter's range; anything further is a counter reset.
+func counterDelta(previous, current uint64, width uint) (uint64, bool) {
+	mask := uint64(math.MaxUint64)
+	if width < 64 {
+		mask = uint64(1)<<width - 1
+	}
+
+	// Unsigned subtraction wraps at 2^64, the mask at narrower widths
+	delta := (current - previous) & mask
+	if current >= previous {
+		return delta, false
+	}
+	return delta, delta <= mask>>1
+}
+
+// toUint64 converts integer metric data to an unsigned counter value
+func toUint64(data interface{}) (uint64, bool) {
+	switch v := data.(type) {
+	case uint:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case int:
+		return uint64(v), true
+	case int32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	}
+	return 0, false
+}
+
+// setMonotonicCheck will check that every metric sent as a cumulative
+// counter never decreases if the monotonic_check config setting is present
+// in the task file, taking its action on a decrease
+func (s *SignalFx) setMonotonicCheck(cfg plugin.Config) {
+	action, err := s.getString(cfg, "monotonic_check")
+	if err != nil {
+		// No monotonic_check defined, moving on
+		return
+	}
+
+	switch action {
+	case monotonicSkip, monotonicMark:
+		s.monotonic = action
+		s.cumulatives = make(map[string]float64)
+		s.logf("Checking cumulative counters never decrease, action %s", action)
+	default:
+		s.logf("Unknown monotonic_check %q, ignoring", action)
+	}
+}
+
+// checkMonotonic reports whether the cumulative datapoint should be sent,
+// marking it with the counter_reset property when its value is below the
+// one last seen for the current series and the action is mark
+func (s *SignalFx) checkMonotonic(dp *datapoint.Datapoint, value float64) bool {
+	if s.monotonic == "" {
+		return true
+	}
+
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	last, seen := s.cumulatives[key]
+	if !seen && len(s.cumulatives) >= maxTrackedSeries {
+		// Forget everything rather than grow without bound
+		s.logf("Tracking over %d series, resetting monotonic checks", maxTrackedSeries)
+		s.cumulatives = make(map[string]float64)
+	}
+	s.cumulatives[key] = value
+	s.mu.Unlock()
+
+	if !seen || value >= last {
+		return true
+	}
+
+	if s.monotonic == monotonicSkip {
+		s.warnf("Skipping %s, decreased from %v to %v", s.namespace, last, value)
+		return false
+	}
+
+	s.warnf("Marking %s as reset, decreased from %v to %v", s.namespace, last, value)
+	dp.SetProperty(counterResetProperty, true)
+	return true
+}
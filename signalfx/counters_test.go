@@ -0,0 +1,315 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous uint64
+		current  uint64
+		width    uint
+		delta    uint64
+		wrapped  bool
+	}{
+		{"increase", 100, 150, 64, 50, false},
+		{"32-bit wrap", math.MaxUint32 - 9, 10, 32, 20, true},
+		{"64-bit wrap", math.MaxUint64 - 9, 10, 64, 20, true},
+		{"32-bit unchanged", 7, 7, 32, 0, false},
+		{"32-bit reset", 5000, 10, 32, math.MaxUint32 - 4989, false},
+		{"64-bit reset", 5000, 10, 64, math.MaxUint64 - 4989, false},
+	}
+	for _, tt := range tests {
+		delta, wrapped := counterDelta(tt.previous, tt.current, tt.width)
+		if delta != tt.delta || wrapped != tt.wrapped {
+			t.Errorf("%s: counterDelta(%d, %d, %d) = %d, %t, want %d, %t",
+				tt.name, tt.previous, tt.current, tt.width, delta, wrapped, tt.delta, tt.wrapped)
+		}
+	}
+}
+
+func TestCounterReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  string
+		values []uint64
+		deltas []string
+	}{
+		{"32-bit wrap", "32", []uint64{math.MaxUint32 - 9, 10}, []string{"", "20"}},
+		{"32-bit reset", "32", []uint64{5000, 10, 25}, []string{"", "", "15"}},
+		{"64-bit wrap", "64", []uint64{math.MaxUint64 - 9, 10}, []string{"", "20"}},
+		{"64-bit reset", "64", []uint64{5000, 10, 25}, []string{"", "", "15"}},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"delta_counters": "/intel/net:" + tt.width}
+
+		var cycles [][]plugin.Metric
+		for _, v := range tt.values {
+			cycles = append(cycles, []plugin.Metric{newMetric(v, "intel", "net", "bytes")})
+		}
+
+		for i, dps := range publishEach(t, settings, cycles...) {
+			var got string
+			if dp, ok := dps["snap.intel.net.bytes"]; ok {
+				got = fmt.Sprint(dp.Value)
+			}
+			if got != tt.deltas[i] {
+				t.Errorf("%s: publish %d sent %q, want %q", tt.name, i, got, tt.deltas[i])
+			}
+		}
+	}
+}
+
+func TestDeltaCountersBounded(t *testing.T) {
+	s := newTestPlugin()
+	s.counters = make(map[string]uint64)
+	for i := 0; i < maxTrackedSeries; i++ {
+		s.counters[strconv.Itoa(i)] = 1
+	}
+
+	s.namespace = "snap.intel.net.bytes"
+	s.sendDelta(deltaRule{prefix: "/intel/net", width: 64}, 1)
+
+	if len(s.counters) != 1 {
+		t.Errorf("Tracking %d series, want the new one only", len(s.counters))
+	}
+}
+
+func TestAllCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		data     interface{}
+		tags     map[string]string
+		typ      string
+	}{
+		{"int", plugin.Config{"all_counters": true}, int64(42), nil, "cumulative_counter"},
+		{"float", plugin.Config{"all_counters": true}, 3.5, nil, "cumulative_counter"},
+		{"unsigned", plugin.Config{"all_counters": true}, uint32(7), nil, "cumulative_counter"},
+		{"disabled", plugin.Config{"all_counters": false}, int64(42), nil, "gauge"},
+		{"default", nil, 3.5, nil, "gauge"},
+	}
+	for _, tt := range tests {
+		m := newMetric(tt.data, "intel", "cpu", "idle")
+		m.Tags = tt.tags
+
+		dp, ok := publish(t, tt.settings, m)["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if dp.Type != tt.typ {
+			t.Errorf("%s: sent as %s, want %s", tt.name, dp.Type, tt.typ)
+		}
+	}
+}
+
+func TestMinDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		values   []uint64
+		deltas   []string
+	}{
+		{"below and above the threshold", plugin.Config{"min_delta": int64(10)},
+			[]uint64{100, 105, 120, 121}, []string{"", "", "15", ""}},
+		{"at the threshold", plugin.Config{"min_delta": int64(10)},
+			[]uint64{100, 110}, []string{"", "10"}},
+		{"zero deltas sent by default", nil,
+			[]uint64{100, 100, 101}, []string{"", "0", "1"}},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"delta_counters": "/intel/net"}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		var cycles [][]plugin.Metric
+		for _, v := range tt.values {
+			cycles = append(cycles, []plugin.Metric{newMetric(v, "intel", "net", "bytes")})
+		}
+
+		for i, dps := range publishEach(t, settings, cycles...) {
+			var got string
+			if dp, ok := dps["snap.intel.net.bytes"]; ok {
+				got = fmt.Sprint(dp.Value)
+			}
+			if got != tt.deltas[i] {
+				t.Errorf("%s: publish %d sent %q, want %q", tt.name, i, got, tt.deltas[i])
+			}
+		}
+	}
+}
+
+func TestMonotonicCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		values []float64
+		sent   []bool
+		marked []bool
+	}{
+		{"increasing", monotonicSkip, []float64{1, 2, 2, 5}, []bool{true, true, true, true}, []bool{false, false, false, false}},
+		{"reset skipped", monotonicSkip, []float64{10, 20, 3, 4}, []bool{true, true, false, true}, []bool{false, false, false, false}},
+		{"reset marked", monotonicMark, []float64{10, 20, 3, 4}, []bool{true, true, true, true}, []bool{false, false, true, false}},
+		{"disabled", "", []float64{10, 20, 3, 4}, []bool{true, true, true, true}, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setMonotonicCheck(plugin.Config{"monotonic_check": tt.action})
+		s.namespace = "snap.intel.net.bytes"
+
+		for i, value := range tt.values {
+			dp := datapoint.New(s.namespace, nil, datapoint.NewFloatValue(value), datapoint.Counter, time.Time{})
+			if sent := s.checkMonotonic(dp, value); sent != tt.sent[i] {
+				t.Errorf("%s: value %d sent = %v, want %v", tt.name, i, sent, tt.sent[i])
+			}
+			_, marked := dp.GetProperties()[counterResetProperty]
+			if marked != tt.marked[i] {
+				t.Errorf("%s: value %d marked = %v, want %v", tt.name, i, marked, tt.marked[i])
+			}
+		}
+	}
+}
+
+func TestMonotonicCheckPublished(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     []bool
+	}{
+		{"skip", plugin.Config{"monotonic_check": monotonicSkip, "all_counters": true}, []bool{true, true, false, true}},
+		{"mark", plugin.Config{"monotonic_check": monotonicMark, "all_counters": true}, []bool{true, true, true, true}},
+		{"unknown action", plugin.Config{"monotonic_check": "reset", "all_counters": true}, []bool{true, true, true, true}},
+		{"counter_suffixes", plugin.Config{"monotonic_check": monotonicSkip, "counter_suffixes": "/bytes"}, []bool{true, true, false, true}},
+		{"gauge", plugin.Config{"monotonic_check": monotonicSkip}, []bool{true, true, true, true}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for _, v := range []int64{10, 20, 3, 4} {
+			cycles = append(cycles, []plugin.Metric{newMetric(v, "intel", "net", "bytes")})
+		}
+
+		var sent []bool
+		for _, dps := range publishEach(t, tt.settings, cycles...) {
+			_, ok := dps["snap.intel.net.bytes"]
+			sent = append(sent, ok)
+		}
+		if !reflect.DeepEqual(sent, tt.sent) {
+			t.Errorf("%s: sent %v, want %v", tt.name, sent, tt.sent)
+		}
+	}
+}
+
+func TestMonotonicCheckBounded(t *testing.T) {
+	s := newTestPlugin()
+	s.setMonotonicCheck(plugin.Config{"monotonic_check": monotonicSkip})
+	for i := 0; i < maxTrackedSeries; i++ {
+		s.cumulatives[strconv.Itoa(i)] = 1
+	}
+
+	s.namespace = "snap.intel.net.bytes"
+	dp := datapoint.New(s.namespace, nil, datapoint.NewIntValue(1), datapoint.Counter, time.Time{})
+	if !s.checkMonotonic(dp, 1) {
+		t.Error("A new series was skipped")
+	}
+	if len(s.cumulatives) != 1 {
+		t.Errorf("Tracking %d series, want the new one only", len(s.cumulatives))
+	}
+}
+
+func TestIsCumulative(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		tag      string // "" for no sfx_metric_type tag
+		ns       []string
+		want     bool
+	}{
+		{"default", nil, "", []string{"intel", "net", "bytes_sent"}, false},
+		{"counter tag", nil, "counter", []string{"intel", "cpu", "idle"}, true},
+		{"cumulative_counter tag", nil, "cumulative_counter", []string{"intel", "cpu", "idle"}, true},
+		{"gauge tag", plugin.Config{"counter_suffixes": "/bytes_sent"}, "gauge", []string{"intel", "net", "bytes_sent"}, false},
+		{"invalid tag", nil, "histogram", []string{"intel", "cpu", "idle"}, false},
+		{"suffix", plugin.Config{"counter_suffixes": "/bytes_sent, /requests_total"}, "", []string{"intel", "net", "bytes_sent"}, true},
+		{"second suffix", plugin.Config{"counter_suffixes": "/bytes_sent, /requests_total"}, "", []string{"intel", "web", "requests_total"}, true},
+		{"other suffix", plugin.Config{"counter_suffixes": "/bytes_sent"}, "", []string{"intel", "net", "bytes_recv"}, false},
+		{"partial element", plugin.Config{"counter_suffixes": "/bytes_sent"}, "", []string{"intel", "net", "total_bytes_sent"}, false},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setCounterSuffixes(tt.settings)
+
+		m := newMetric(int64(1), tt.ns...)
+		if tt.tag != "" {
+			m.Tags = map[string]string{tagMetricType: tt.tag}
+		}
+		if got := s.isCumulative(m); got != tt.want {
+			t.Errorf("%s: isCumulative(%s) = %v, want %v", tt.name, m.Namespace.String(), got, tt.want)
+		}
+	}
+}
+
+func TestCounterTypePublished(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		data     interface{}
+		tag      string
+		typ      string
+		value    string
+	}{
+		{"int gauge", nil, int64(42), "", "gauge", "42"},
+		{"float gauge", nil, 4.5, "", "gauge", "4.5"},
+		{"int counter by tag", nil, int64(42), "counter", "cumulative_counter", "42"},
+		{"float counter by tag", nil, 4.5, "counter", "cumulative_counter", "4.5"},
+		{"int counter by suffix", plugin.Config{"counter_suffixes": "/bytes_sent"}, uint64(42), "", "cumulative_counter", "42"},
+		{"float counter by suffix", plugin.Config{"counter_suffixes": "/bytes_sent"}, float32(4.5), "", "cumulative_counter", "4.5"},
+	}
+	for _, tt := range tests {
+		m := newMetric(tt.data, "intel", "net", "bytes_sent")
+		if tt.tag != "" {
+			m.Tags = map[string]string{tagMetricType: tt.tag}
+		}
+
+		dp, ok := publish(t, tt.settings, m)["snap.intel.net.bytes_sent"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if dp.Type != tt.typ || fmt.Sprint(dp.Value) != tt.value {
+			t.Errorf("%s: sent %s %v, want %s %s", tt.name, dp.Type, dp.Value, tt.typ, tt.value)
+		}
+	}
+}
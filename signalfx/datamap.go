@@ -0,0 +1,60 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Default key holding the value of map metric data
+const defaultDataValueKey = "value"
+
+// setDataKeyDimensions will set the keys of map metric data promoted to
+// dimensions and the key holding the value
+func (s *SignalFx) setDataKeyDimensions(cfg plugin.Config) {
+	value, err := s.getString(cfg, "data_key_dimensions")
+	if err != nil {
+		// No data_key_dimensions defined, moving on
+		return
+	}
+	s.dataKeyDims = splitList(value)
+
+	s.dataValueKey = defaultDataValueKey
+	if key, err := s.getString(cfg, "data_value_key"); err == nil && key != "" {
+		s.dataValueKey = key
+	}
+
+	s.logf("Using %v of map data as dimensions and %s as the value", s.dataKeyDims, s.dataValueKey)
+}
+
+// unpackData adds the configured keys of the map to the dimensions and
+// returns the value held under the value key
+func (s *SignalFx) unpackData(data map[string]interface{}) interface{} {
+	dims := make(map[string]string)
+	for _, key := range s.dataKeyDims {
+		if v, ok := data[key]; ok {
+			dims[key] = fmt.Sprint(v)
+		}
+	}
+	s.addDimensions(sourceInferred, dims)
+	return data[s.dataValueKey]
+}
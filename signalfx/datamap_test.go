@@ -0,0 +1,81 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestDataKeyDimensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		data     map[string]interface{}
+		value    string
+		dims     map[string]string
+	}{
+		{"value key", plugin.Config{"data_key_dimensions": "device"},
+			map[string]interface{}{"value": int64(12), "device": "sda", "mode": "rw"},
+			"12", map[string]string{"device": "sda"}},
+		{"several labels", plugin.Config{"data_key_dimensions": "device,mode"},
+			map[string]interface{}{"value": 1.5, "device": "sda", "mode": "rw"},
+			"1.5", map[string]string{"device": "sda", "mode": "rw"}},
+		{"non-string label", plugin.Config{"data_key_dimensions": "cpu"},
+			map[string]interface{}{"value": int64(3), "cpu": int64(0)},
+			"3", map[string]string{"cpu": "0"}},
+		{"custom value key", plugin.Config{"data_key_dimensions": "device", "data_value_key": "bytes"},
+			map[string]interface{}{"bytes": int64(512), "device": "sdb"},
+			"512", map[string]string{"device": "sdb"}},
+		{"missing label", plugin.Config{"data_key_dimensions": "device"},
+			map[string]interface{}{"value": int64(7)},
+			"7", map[string]string{}},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(tt.data, "intel", "disk", "bytes"))
+
+		dp, ok := dps["snap.intel.disk.bytes"]
+		if !ok {
+			t.Errorf("%s: nothing was sent, got %v", tt.name, dps)
+			continue
+		}
+		if got := fmt.Sprint(dp.Value); got != tt.value {
+			t.Errorf("%s: sent %s, want %s", tt.name, got, tt.value)
+		}
+		for _, key := range []string{"device", "mode", "cpu"} {
+			if got, want := dp.Dimensions[key], tt.dims[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestDataKeyDimensionsWithoutValue(t *testing.T) {
+	dps := publish(t, plugin.Config{"data_key_dimensions": "device"},
+		newMetric(map[string]interface{}{"device": "sda"}, "intel", "disk", "bytes"))
+	if _, ok := dps["snap.intel.disk.bytes"]; ok {
+		t.Errorf("Map data without a value was sent: %v", dps)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// deadbandRule - Suppresses values of namespaces starting with prefix that
+// are within band of the value last sent
+type deadbandRule struct {
+	prefix  string  // Namespace prefix
+	band    float64 // Tolerance
+	percent bool    // Band is a percentage of the last value
+}
+
+// setDeadband will set the namespaces whose values are suppressed while
+// within a band of the last value sent from the deadband setting, which is
+// "prefix=band,..." with the band an absolute value or a percentage, e.g.
+// "/intel/psutil/load=0.05,/intel/procfs/meminfo=1%"
+func (s *SignalFx) setDeadband(cfg plugin.Config) {
+	value, err := s.getString(cfg, "deadband")
+	if err != nil {
+		// No deadband defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring deadband %q, expected prefix=band or prefix=band%%", entry)
+			continue
+		}
+
+		rule := deadbandRule{prefix: parts[0]}
+		band := strings.TrimSpace(parts[1])
+		if strings.HasSuffix(band, "%") {
+			rule.percent = true
+			band = strings.TrimSuffix(band, "%")
+		}
+
+		n, err := strconv.ParseFloat(band, 64)
+		if err != nil || n < 0 {
+			s.logf("Ignoring deadband %q, expected prefix=band or prefix=band%%", entry)
+			continue
+		}
+		rule.band = n
+
+		s.logf("Suppressing %s values within %s of the last sent", rule.prefix, parts[1])
+		s.deadbands = append(s.deadbands, rule)
+	}
+	if len(s.deadbands) == 0 {
+		return
+	}
+	s.deadbandLast = make(map[string]*lastSent)
+
+	s.deadbandHeartbeat = defaultChangeHeartbeat
+	if n, err := s.getInt(cfg, "change_heartbeat"); err == nil && n > 0 {
+		s.deadbandHeartbeat = n
+	}
+}
+
+// deadbandRuleFor returns the deadband rule of the namespace, if any
+func (s *SignalFx) deadbandRuleFor(namespace string) (deadbandRule, bool) {
+	for _, rule := range s.deadbands {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule, true
+		}
+	}
+	return deadbandRule{}, false
+}
+
+// withinDeadband reports whether the value is within the rule's band of
+// the value last sent for the current series and should be suppressed.
+// Every change_heartbeat cycles the value is sent anyway so the series
+// does not appear to stop. A value not suppressed is only remembered once
+// it is sent.
+func (s *SignalFx) withinDeadband(rule deadbandRule, value float64) bool {
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.deadbandLast[key]
+	if ok && last.skipped+1 < s.deadbandHeartbeat {
+		sent := last.value.(float64)
+		band := rule.band
+		if rule.percent {
+			band = math.Abs(sent) * rule.band / 100
+		}
+		if math.Abs(value-sent) <= band {
+			last.skipped++
+			return true
+		}
+	}
+
+	s.pendingValues = append(s.pendingValues, lastSentUpdate{deadband: true, key: key, value: value})
+	return false
+}
@@ -0,0 +1,88 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestDeadband(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		values   []float64
+		sent     []bool
+	}{
+		{"disabled", plugin.Config{}, []float64{1, 1, 1}, []bool{true, true, true}},
+		{"within the band", plugin.Config{"deadband": "/intel/cpu=0.5"},
+			[]float64{10, 10.2, 9.5, 10.6}, []bool{true, false, false, true}},
+		{"outside the band", plugin.Config{"deadband": "/intel/cpu=0.5"},
+			[]float64{10, 11, 9, 10}, []bool{true, true, true, true}},
+		{"compared to the last sent", plugin.Config{"deadband": "/intel/cpu=0.5"},
+			[]float64{10, 10.4, 10.8, 10.9}, []bool{true, false, true, false}},
+		{"percentage", plugin.Config{"deadband": "/intel/cpu=10%"},
+			[]float64{100, 109, 91, 111, 121}, []bool{true, false, false, true, false}},
+		{"other namespace", plugin.Config{"deadband": "/intel/mem=0.5"},
+			[]float64{10, 10.2}, []bool{true, true}},
+		{"malformed", plugin.Config{"deadband": "/intel/cpu=wide"},
+			[]float64{10, 10.2}, []bool{true, true}},
+		{"forced send", plugin.Config{"deadband": "/intel/cpu=0.5", "change_heartbeat": int64(3)},
+			[]float64{10, 10, 10, 10, 10, 10, 10}, []bool{true, false, false, true, false, false, true}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for _, v := range tt.values {
+			cycles = append(cycles, []plugin.Metric{newMetric(v, "intel", "cpu", "idle")})
+		}
+
+		var sent []bool
+		for _, dps := range publishEach(t, tt.settings, cycles...) {
+			_, ok := dps["snap.intel.cpu.idle"]
+			sent = append(sent, ok)
+		}
+		if !reflect.DeepEqual(sent, tt.sent) {
+			t.Errorf("%s: sent %v, want %v", tt.name, sent, tt.sent)
+		}
+	}
+}
+
+func TestDeadbandBounded(t *testing.T) {
+	s := newTestPlugin()
+	s.setDeadband(plugin.Config{"deadband": "/intel/cpu=0.5"})
+	for i := 0; i < maxTrackedSeries; i++ {
+		s.deadbandLast[strconv.Itoa(i)] = &lastSent{value: float64(1)}
+	}
+
+	s.namespace = "snap.intel.cpu.idle"
+	if s.withinDeadband(s.deadbands[0], 1) {
+		t.Error("A new series was suppressed")
+	}
+	s.updateLastSent(s.pendingValues)
+	if len(s.deadbandLast) != 1 {
+		t.Errorf("Tracking %d series, want the new one only", len(s.deadbandLast))
+	}
+}
@@ -0,0 +1,356 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Constants
+const (
+	sourceTypeDimension = "sf_source" // Dimension holding the source_type
+	sequenceDimension   = "seq"       // Dimension holding the debug_sequence
+	maxDimensionKey     = 128         // Longest dimension key allowed
+	actionWarn          = "warn"      // Only warn about invalid dimension keys
+	maxDimensionValue   = 256         // Longest dimension value allowed
+)
+
+// setSourceType will set the source_type dimension sent with every
+// datapoint, provided it is a valid dimension value
+func (s *SignalFx) setSourceType(cfg plugin.Config) {
+	value, err := s.getString(cfg, "source_type")
+	if err != nil {
+		// No source_type defined, moving on
+		return
+	}
+
+	if err := validateDimensionValue(value); err != nil {
+		s.logf("Ignoring source_type: %v", err)
+		return
+	}
+	s.sourceType = value
+
+	s.logf("Using source type %s", value)
+}
+
+// setStaticDimensions will parse the dimensions setting, comma separated
+// key=value pairs sent with every datapoint, e.g.
+// "environment=prod,dc=us-east". Malformed pairs are skipped, and runes
+// SignalFx does not allow in keys are replaced with '_'.
+func (s *SignalFx) setStaticDimensions(cfg plugin.Config) {
+	value, err := s.getString(cfg, "dimensions")
+	if err != nil {
+		// No dimensions defined, moving on
+		return
+	}
+
+	s.staticDims = make(map[string]string)
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			s.logf("Ignoring dimension %q, expected key=value", entry)
+			continue
+		}
+
+		k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if k == "" || v == "" {
+			s.logf("Ignoring dimension %q, expected key=value", entry)
+			continue
+		}
+		s.staticDims[strings.Map(dimensionKeyRune, k)] = v
+	}
+
+	s.logf("Sending dimensions %v with every datapoint", s.staticDims)
+}
+
+// setOwnership will set the org and team dimensions sent with every
+// datapoint, provided they are valid dimension values
+func (s *SignalFx) setOwnership(cfg plugin.Config) {
+	for _, owner := range []struct {
+		key   string
+		value *string
+	}{
+		{"org", &s.org},
+		{"team", &s.team},
+	} {
+		value, err := s.getString(cfg, owner.key)
+		if err != nil || value == "" {
+			// No owner defined, moving on
+			continue
+		}
+
+		if err := validateDimensionValue(value); err != nil {
+			s.logf("Ignoring %s: %v", owner.key, err)
+			continue
+		}
+		*owner.value = value
+
+		s.logf("Using %s %s", owner.key, value)
+	}
+}
+
+// setIncludePid will send the plugin's process id with every datapoint if
+// the include_pid config setting is present in the task file
+func (s *SignalFx) setIncludePid(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "include_pid")
+	if err != nil || !enabled {
+		return
+	}
+	s.pid = strconv.Itoa(os.Getpid())
+
+	s.logf("Sending pid %s as a dimension", s.pid)
+}
+
+// Files holding the machine id, in order of preference
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// setIncludeHostID will send a stable hash of the hostname and machine id
+// as the host_id dimension if the include_host_id config setting is
+// present in the task file
+func (s *SignalFx) setIncludeHostID(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "include_host_id")
+	if err != nil || !enabled {
+		return
+	}
+
+	machineID := ""
+	for _, name := range machineIDFiles {
+		if data, err := ioutil.ReadFile(name); err == nil {
+			machineID = strings.TrimSpace(string(data))
+			break
+		}
+	}
+	if machineID == "" {
+		s.logln("No machine id found, hashing the hostname alone")
+	}
+	s.hostID = hostID(s.hostname, machineID)
+
+	s.logf("Sending host id %s as a dimension", s.hostID)
+}
+
+// hostID returns a stable hash of the hostname and machine id
+func hostID(hostname, machineID string) string {
+	sum := sha256.Sum256([]byte(hostname + "\x00" + machineID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// setDebugSequence will number every datapoint sent by the process in a
+// seq dimension if the debug_sequence config setting is present in the
+// task file
+func (s *SignalFx) setDebugSequence(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "debug_sequence")
+	if err != nil || !enabled {
+		return
+	}
+	s.debugSeq = true
+
+	s.warnf("Sending a %s dimension on every datapoint; this creates a new series per datapoint "+
+		"and is only meant for debugging ingest ordering", sequenceDimension)
+}
+
+// addSequence adds the next sequence number to the datapoint's dimensions,
+// copying them since they may be shared with other datapoints
+func (s *SignalFx) addSequence(dp *datapoint.Datapoint) {
+	dims := make(map[string]string, len(dp.Dimensions)+1)
+	for k, v := range dp.Dimensions {
+		dims[k] = v
+	}
+	s.sequence++
+	dims[sequenceDimension] = strconv.FormatUint(s.sequence, 10)
+	dp.Dimensions = dims
+}
+
+// baseDimensions returns the dimensions sent with every datapoint
+func (s *SignalFx) baseDimensions() map[string]string {
+	// The dimensions setting, which the settings below override
+	dims := make(map[string]string, len(s.staticDims)+1)
+	for k, v := range s.staticDims {
+		dims[k] = v
+	}
+	dims["host"] = s.hostname
+	if s.sourceType != "" {
+		dims[sourceTypeDimension] = s.sourceType
+	}
+	if s.org != "" {
+		dims["org"] = s.org
+	}
+	if s.team != "" {
+		dims["team"] = s.team
+	}
+	if s.pid != "" {
+		dims["pid"] = s.pid
+	}
+	if s.hostID != "" {
+		dims["host_id"] = s.hostID
+	}
+	for k, v := range s.cloudDims {
+		dims[k] = v
+	}
+	for k, v := range s.hostDims {
+		dims[k] = v
+	}
+	return dims
+}
+
+// setValidateDimensions will check dimension keys against the SignalFx
+// rules if the validate_dimensions config setting is present in the task
+// file, taking the validate_dimensions_policy action on invalid keys
+func (s *SignalFx) setValidateDimensions(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "validate_dimensions")
+	if err != nil || !enabled {
+		return
+	}
+
+	s.keyPolicy = actionWarn
+	if policy, err := s.getString(cfg, "validate_dimensions_policy"); err == nil {
+		switch policy {
+		case actionWarn, actionStrip, actionDrop:
+			s.keyPolicy = policy
+		default:
+			s.logf("Unknown validate_dimensions_policy %q, using %s", policy, actionWarn)
+		}
+	}
+
+	s.logf("Validating dimension keys, policy %s", s.keyPolicy)
+}
+
+// validateDimensionKeys checks the current dimension keys, warning about
+// invalid ones and stripping them per the policy, returning false when the
+// datapoint should be dropped
+func (s *SignalFx) validateDimensionKeys() bool {
+	if s.keyPolicy == "" {
+		return true
+	}
+
+	for key := range s.dimensions {
+		err := validateDimensionKey(key)
+		if err == nil {
+			continue
+		}
+
+		switch s.keyPolicy {
+		case actionDrop:
+			s.warnf("Dropping %s, invalid dimension key: %v", s.namespace, err)
+			s.countFiltered(filterDimensionKeys)
+			return false
+		case actionStrip:
+			s.warnf("Stripping dimension from %s, invalid key: %v", s.namespace, err)
+			delete(s.dimensions, key)
+		default:
+			s.warnf("Invalid dimension key on %s: %v", s.namespace, err)
+		}
+	}
+	return true
+}
+
+// validateDimensionKey checks the key is one SignalFx will accept: not too
+// long, starting with a letter, and made up of letters, digits, '_', and
+// '-'
+func validateDimensionKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	if len(key) > maxDimensionKey {
+		return fmt.Errorf("%q is longer than %d characters", key, maxDimensionKey)
+	}
+	if c := key[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+		return fmt.Errorf("%q does not start with a letter", key)
+	}
+
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-':
+		default:
+			return fmt.Errorf("%q contains %q", key, r)
+		}
+	}
+	return nil
+}
+
+// validateDimensionValue checks the value is one SignalFx will accept
+// for a well-known dimension: non-empty, not too long, and made up of
+// letters, digits, '_', '-', and '.'
+func validateDimensionValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty value")
+	}
+	if len(value) > maxDimensionValue {
+		return fmt.Errorf("%q is longer than %d characters", value, maxDimensionValue)
+	}
+
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.':
+		default:
+			return fmt.Errorf("%q contains %q", value, r)
+		}
+	}
+	return nil
+}
+
+// keyAlias - Renames the dimension key from to the canonical key to
+type keyAlias struct {
+	from string // Key as sent by collectors
+	to   string // Canonical key
+}
+
+// setDimensionKeyAliases will set the dimension keys renamed to a
+// canonical key from the dimension_key_aliases setting, which is
+// "from=to,...", e.g. "Region=region,HOST=host"
+func (s *SignalFx) setDimensionKeyAliases(cfg plugin.Config) {
+	value, err := s.getString(cfg, "dimension_key_aliases")
+	if err != nil {
+		// No dimension_key_aliases defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			s.logf("Ignoring dimension_key_aliases %q, expected from=to", entry)
+			continue
+		}
+
+		s.logf("Renaming the %s dimension to %s", parts[0], parts[1])
+		s.keyAliases = append(s.keyAliases, keyAlias{from: parts[0], to: parts[1]})
+	}
+}
+
+// aliasDimensionKeys renames the current metric's aliased dimension keys.
+// When several keys end up the same, the alias defined last wins.
+func (s *SignalFx) aliasDimensionKeys() {
+	for _, alias := range s.keyAliases {
+		if v, ok := s.dimensions[alias.from]; ok {
+			delete(s.dimensions, alias.from)
+			s.dimensions[alias.to] = v
+		}
+	}
+}
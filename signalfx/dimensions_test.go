@@ -0,0 +1,406 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestSourceType(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		value    string
+		sent     bool
+	}{
+		{"configured", plugin.Config{"source_type": "snap"}, "snap", true},
+		{"dots and dashes", plugin.Config{"source_type": "snap-agent.v1"}, "snap-agent.v1", true},
+		{"overrides the dimensions setting", plugin.Config{
+			"source_type": "snap",
+			"dimensions":  "sf_source=other",
+		}, "snap", true},
+		{"invalid", plugin.Config{"source_type": "snap agent!"}, "", false},
+		{"absent", nil, "", false},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))
+
+		dp, ok := dps["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if got, ok := dp.Dimensions[sourceTypeDimension]; ok != tt.sent || got != tt.value {
+			t.Errorf("%s: %s = %q (%v), want %q (%v)", tt.name, sourceTypeDimension, got, ok, tt.value, tt.sent)
+		}
+	}
+}
+
+func TestIncludePid(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		value    string
+		sent     bool
+	}{
+		{"enabled", plugin.Config{"include_pid": true}, pid, true},
+		{"disabled", plugin.Config{"include_pid": false}, "", false},
+		{"absent", nil, "", false},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))
+
+		dp, ok := dps["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if got, ok := dp.Dimensions["pid"]; ok != tt.sent || got != tt.value {
+			t.Errorf("%s: pid = %q (%v), want %q (%v)", tt.name, got, ok, tt.value, tt.sent)
+		}
+	}
+}
+
+func TestDebugSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		seqs     []string // Per publish, per metric
+	}{
+		{"enabled", plugin.Config{"debug_sequence": true}, []string{"1", "2", "3", "4"}},
+		{"disabled", plugin.Config{"debug_sequence": false}, []string{"", "", "", ""}},
+		{"absent", nil, []string{"", "", "", ""}},
+	}
+	for _, tt := range tests {
+		cycle := []plugin.Metric{
+			newMetric(int64(1), "intel", "cpu", "idle"),
+			newMetric(int64(2), "intel", "cpu", "user"),
+		}
+
+		var got []string
+		for _, dps := range publishEach(t, tt.settings, cycle, cycle) {
+			for _, metric := range []string{"snap.intel.cpu.idle", "snap.intel.cpu.user"} {
+				dp, ok := dps[metric]
+				if !ok {
+					t.Errorf("%s: %s was not sent", tt.name, metric)
+					continue
+				}
+				got = append(got, dp.Dimensions[sequenceDimension])
+			}
+		}
+		if !reflect.DeepEqual(got, tt.seqs) {
+			t.Errorf("%s: sequence numbers %v, want %v", tt.name, got, tt.seqs)
+		}
+	}
+}
+
+func TestDimensionKeyAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		tags     map[string]string
+		want     map[string]string // Expected dimension, "" when absent
+	}{
+		{"renamed", plugin.Config{"dimension_key_aliases": "Region=region"},
+			map[string]string{"Region": "us-east"},
+			map[string]string{"region": "us-east", "Region": ""}},
+		{"two casings merged", plugin.Config{"dimension_key_aliases": "Region=region,REGION=region"},
+			map[string]string{"Region": "us-east", "REGION": "us-west"},
+			map[string]string{"region": "us-west", "Region": "", "REGION": ""}},
+		{"later alias wins", plugin.Config{"dimension_key_aliases": "REGION=region,Region=region"},
+			map[string]string{"Region": "us-east", "REGION": "us-west"},
+			map[string]string{"region": "us-east", "Region": "", "REGION": ""}},
+		{"canonical key overridden", plugin.Config{"dimension_key_aliases": "Region=region"},
+			map[string]string{"region": "us-west", "Region": "us-east"},
+			map[string]string{"region": "us-east", "Region": ""}},
+		{"malformed", plugin.Config{"dimension_key_aliases": "Region"},
+			map[string]string{"Region": "us-east"},
+			map[string]string{"Region": "us-east", "region": ""}},
+		{"absent", nil,
+			map[string]string{"Region": "us-east", "REGION": "us-west"},
+			map[string]string{"Region": "us-east", "REGION": "us-west", "region": ""}},
+	}
+	for _, tt := range tests {
+		m := newMetric(int64(1), "intel", "cpu", "idle")
+		m.Tags = tt.tags
+
+		dp, ok := publish(t, tt.settings, m)["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		for key, want := range tt.want {
+			if got := dp.Dimensions[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateDimensionKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"letters", "host", true},
+		{"digits, underscores and dashes", "Cpu_0-total", true},
+		{"longest", strings.Repeat("k", maxDimensionKey), true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("k", maxDimensionKey+1), false},
+		{"starts with a digit", "0cpu", false},
+		{"starts with an underscore", "_cpu", false},
+		{"dot", "cpu.id", false},
+		{"space", "cpu id", false},
+		{"non-ASCII letter", "prozessör", false},
+	}
+	for _, tt := range tests {
+		if err := validateDimensionKey(tt.key); (err == nil) != tt.valid {
+			t.Errorf("%s: validateDimensionKey(%q) = %v, want valid %v", tt.name, tt.key, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateDimensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     bool
+		kept     bool // Whether the invalid key was sent
+	}{
+		{"warn by default", plugin.Config{"validate_dimensions": true}, true, true},
+		{"warn", plugin.Config{"validate_dimensions": true, "validate_dimensions_policy": "warn"}, true, true},
+		{"strip", plugin.Config{"validate_dimensions": true, "validate_dimensions_policy": "strip"}, true, false},
+		{"drop", plugin.Config{"validate_dimensions": true, "validate_dimensions_policy": "drop"}, false, false},
+		{"unknown policy", plugin.Config{"validate_dimensions": true, "validate_dimensions_policy": "fix"}, true, true},
+		{"disabled", nil, true, true},
+	}
+	for _, tt := range tests {
+		m := newMetric(int64(1), "intel", "cpu", "idle")
+		m.Tags = map[string]string{"cpu": "0", "0core": "1"}
+
+		dp, ok := publish(t, tt.settings, m)["snap.intel.cpu.idle"]
+		if ok != tt.sent {
+			t.Errorf("%s: sent = %v, want %v", tt.name, ok, tt.sent)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, ok := dp.Dimensions["0core"]; ok != tt.kept {
+			t.Errorf("%s: invalid key sent = %v, want %v", tt.name, ok, tt.kept)
+		}
+		if dp.Dimensions["cpu"] != "0" {
+			t.Errorf("%s: the valid key was not sent", tt.name)
+		}
+	}
+}
+
+func TestHostID(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       [2]string // Hostname and machine id
+		sameResult bool
+	}{
+		{"same inputs", [2]string{"web1", "0123abcd"}, [2]string{"web1", "0123abcd"}, true},
+		{"no machine id", [2]string{"web1", ""}, [2]string{"web1", ""}, true},
+		{"other hostname", [2]string{"web1", "0123abcd"}, [2]string{"web2", "0123abcd"}, false},
+		{"other machine id", [2]string{"web1", "0123abcd"}, [2]string{"web1", "4567ef01"}, false},
+		{"ambiguous split", [2]string{"web1", "0"}, [2]string{"web10", ""}, false},
+	}
+	for _, tt := range tests {
+		a := hostID(tt.a[0], tt.a[1])
+		b := hostID(tt.b[0], tt.b[1])
+		if len(a) != 16 {
+			t.Errorf("%s: host id %q is not 16 hex digits", tt.name, a)
+		}
+		if (a == b) != tt.sameResult {
+			t.Errorf("%s: host ids %s and %s, want the same %v", tt.name, a, b, tt.sameResult)
+		}
+	}
+}
+
+func TestIncludeHostID(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     bool
+	}{
+		{"enabled", plugin.Config{"include_host_id": true}, true},
+		{"disabled", plugin.Config{"include_host_id": false}, false},
+		{"absent", nil, false},
+	}
+	for _, tt := range tests {
+		// Two plugins on the same host send the same id
+		var ids []string
+		for i := 0; i < 2; i++ {
+			dp, ok := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))["snap.intel.cpu.idle"]
+			if !ok {
+				t.Errorf("%s: nothing was sent", tt.name)
+				continue
+			}
+			id, ok := dp.Dimensions["host_id"]
+			if ok != tt.sent {
+				t.Errorf("%s: host_id sent = %v, want %v", tt.name, ok, tt.sent)
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) == 2 && ids[0] != ids[1] {
+			t.Errorf("%s: host ids %s and %s differ", tt.name, ids[0], ids[1])
+		}
+	}
+}
+
+func TestStaticDimensions(t *testing.T) {
+	tests := []struct {
+		value string
+		dims  map[string]string
+	}{
+		{"environment=prod,dc=us-east", map[string]string{"environment": "prod", "dc": "us-east"}},
+		{" environment = prod , dc=us-east ", map[string]string{"environment": "prod", "dc": "us-east"}},
+		{"environment=prod,malformed,=x,dc=", map[string]string{"environment": "prod"}},
+		{"data.center=east", map[string]string{"data_center": "east"}},
+		{"url=http://host/?a=b", map[string]string{"url": "http://host/?a=b"}},
+		{"", map[string]string{}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setStaticDimensions(plugin.Config{"dimensions": tt.value})
+		if !reflect.DeepEqual(s.staticDims, tt.dims) {
+			t.Errorf("dimensions %q = %v, want %v", tt.value, s.staticDims, tt.dims)
+		}
+	}
+}
+
+func TestStaticDimensionsSent(t *testing.T) {
+	tagged := newMetric(int64(1), "intel", "cpu", "idle")
+	tagged.Tags = map[string]string{"environment": "staging"}
+
+	dps := publish(t, plugin.Config{"dimensions": "environment=prod,data.center=east"},
+		tagged,
+		newMetric(int64(2), "intel", "cpu", "user"),
+	)
+
+	tests := []struct {
+		metric      string
+		environment string
+	}{
+		{"snap.intel.cpu.idle", "staging"}, // The tag overrides the global
+		{"snap.intel.cpu.user", "prod"},
+	}
+	for _, tt := range tests {
+		dp, ok := dps[tt.metric]
+		if !ok {
+			t.Errorf("%s was not sent", tt.metric)
+			continue
+		}
+		if got := dp.Dimensions["environment"]; got != tt.environment {
+			t.Errorf("%s: environment = %q, want %q", tt.metric, got, tt.environment)
+		}
+		if got := dp.Dimensions["data_center"]; got != "east" {
+			t.Errorf("%s: data_center = %q, want east", tt.metric, got)
+		}
+	}
+}
+
+func TestDimensionPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		precedence interface{}
+		source     string
+		host       string
+	}{
+		{"default", nil, "inferred", "inferred"},
+		{"tag first", "tag", "tag", "inferred"},
+		{"static first", "static,inferred,tag", "static", "inferred"},
+		{"host first", "host", "inferred", "web1"},
+		{"unknown source ignored", "collector,tag", "tag", "inferred"},
+	}
+	for _, tt := range tests {
+		// The source set by the namespace, source_type and a tag, and the
+		// host by the namespace and the host source
+		settings := plugin.Config{
+			"infer_dimensions": "^/intel/(?P<sf_source>inferred)/(?P<host>inferred)/(?P<metric>.+)$",
+			"source_type":      "static",
+			"hostname":         "web1",
+		}
+		if tt.precedence != nil {
+			settings["dimension_precedence"] = tt.precedence
+		}
+		m := newMetric(int64(1), "intel", "inferred", "inferred", "cpu", "idle")
+		m.Tags = map[string]string{"sf_source": "tag"}
+
+		dp, ok := publish(t, settings, m)["snap.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if got := dp.Dimensions["sf_source"]; got != tt.source {
+			t.Errorf("%s: sf_source = %q, want %q", tt.name, got, tt.source)
+		}
+		if got := dp.Dimensions["host"]; got != tt.host {
+			t.Errorf("%s: host = %q, want %q", tt.name, got, tt.host)
+		}
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		org      string
+		team     string
+	}{
+		{"both", plugin.Config{"org": "acme", "team": "platform"}, "acme", "platform"},
+		{"org only", plugin.Config{"org": "acme"}, "acme", ""},
+		{"team only", plugin.Config{"team": "sre-eu.1"}, "", "sre-eu.1"},
+		{"overrides the dimensions setting", plugin.Config{"org": "acme", "dimensions": "org=other,env=prod"}, "acme", ""},
+		{"invalid value", plugin.Config{"org": "acme corp", "team": "platform"}, "", "platform"},
+		{"empty", plugin.Config{"org": "", "team": ""}, "", ""},
+		{"absent", nil, "", ""},
+	}
+	for _, tt := range tests {
+		dp, ok := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		for key, want := range map[string]string{"org": tt.org, "team": tt.team} {
+			got, sent := dp.Dimensions[key]
+			if want == "" && sent {
+				t.Errorf("%s: %s sent as %q", tt.name, key, got)
+			}
+			if want != "" && got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
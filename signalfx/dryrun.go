@@ -0,0 +1,46 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// setDryRun will log datapoints instead of sending them, without creating
+// any sinks or needing a token, if the dry_run config setting is present
+// in the task file
+func (s *SignalFx) setDryRun(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "dry_run")
+	if err != nil || !enabled {
+		return
+	}
+	s.dryRun = true
+
+	s.logln("Dry run, logging datapoints instead of sending them")
+}
+
+// logDatapoints logs the datapoints that would have been sent, with their
+// type
+func (s *SignalFx) logDatapoints(dps []*datapoint.Datapoint) {
+	for _, dp := range dps {
+		s.logf("Dry run [%s] %s", jsonTypeKeys[dp.MetricType], formatLine(dp, s.now()))
+	}
+}
@@ -0,0 +1,93 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		noToken  bool
+		dryRun   bool
+	}{
+		{"dry run", plugin.Config{"dry_run": true}, false, true},
+		{"dry run without a token", plugin.Config{"dry_run": true}, true, true},
+		{"disabled", plugin.Config{"dry_run": false}, false, false},
+		{"absent", nil, false, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		cfg := testConfig(is.URL, tt.settings)
+		if tt.noToken {
+			delete(cfg, "token")
+		}
+
+		var logged bytes.Buffer
+		s := newTestPlugin()
+		s.SetLogger(log.New(&logged, "", 0))
+
+		counter := newMetric(int64(7), "intel", "net", "bytes")
+		counter.Tags = map[string]string{tagMetricType: "counter", "env": "prod"}
+		err := s.Publish([]plugin.Metric{newMetric(int64(42), "intel", "cpu", "idle"), counter}, cfg)
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		if sent := is.requestCount() > 0; sent == tt.dryRun {
+			t.Errorf("%s: sent = %v on a dry run = %v", tt.name, sent, tt.dryRun)
+		}
+		if tt.dryRun && s.targets != nil {
+			t.Errorf("%s: sinks were created for a dry run", tt.name)
+		}
+
+		for _, want := range []string{
+			"Dry run [gauge] snap.intel.cpu.idle",
+			"Dry run [cumulative_counter] snap.intel.net.bytes",
+			"env=prod",
+		} {
+			if strings.Contains(logged.String(), want) != tt.dryRun {
+				t.Errorf("%s: logged %q = %v, want %v", tt.name, want, !tt.dryRun, tt.dryRun)
+			}
+		}
+	}
+}
+
+func TestTokenRequiredWithoutDryRun(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("A missing token was accepted without a dry run")
+		}
+	}()
+	newTestPlugin().setToken(plugin.Config{"dry_run": false})
+}
@@ -0,0 +1,60 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// setDynamicDimensions will send the dynamic elements of namespaces as
+// dimensions, leaving them out of metric names, if the dynamic_dimensions
+// config setting is present in the task file
+func (s *SignalFx) setDynamicDimensions(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "dynamic_dimensions")
+	if err != nil || !enabled {
+		return
+	}
+	s.dynamicDims = true
+
+	s.logln("Sending dynamic namespace elements as dimensions")
+}
+
+// dynamicElements returns the namespace elements named in the metric name
+// and, with dynamic_dimensions, the dynamic elements as dimensions keyed by
+// their names, e.g. /intel/procfs/disk/sda/bytes_read with a dynamic disk
+// element becomes intel.procfs.disk.bytes_read with disk=sda
+func (s *SignalFx) dynamicElements(ns plugin.Namespace) ([]string, map[string]string) {
+	if !s.dynamicDims {
+		return ns.Strings(), nil
+	}
+
+	elements := make([]string, 0, len(ns))
+	dims := make(map[string]string)
+	for _, element := range ns {
+		if element.Name == "" || element.Value == "" || element.Value == "*" {
+			elements = append(elements, element.Value)
+			continue
+		}
+		dims[strings.Map(dimensionKeyRune, element.Name)] = element.Value
+	}
+	return elements, dims
+}
@@ -0,0 +1,125 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// diskMetric returns a metric under /intel/procfs/disk/<disk>/bytes_read,
+// the disk element being dynamic
+func diskMetric(data interface{}, disk string) plugin.Metric {
+	ns := plugin.NewNamespace("intel", "procfs", "disk").
+		AddDynamicElement("disk", "Disk name").
+		AddStaticElement("bytes_read")
+	ns[3].Value = disk
+
+	m := newMetric(data)
+	m.Namespace = ns
+	return m
+}
+
+func TestDynamicElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		disk     string
+		elements []string
+		dims     map[string]string
+	}{
+		{"one dynamic element", true, "sda",
+			[]string{"intel", "procfs", "disk", "bytes_read"}, map[string]string{"disk": "sda"}},
+		{"unfilled element", true, "*",
+			[]string{"intel", "procfs", "disk", "*", "bytes_read"}, map[string]string{}},
+		{"disabled", false, "sda",
+			[]string{"intel", "procfs", "disk", "sda", "bytes_read"}, nil},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.dynamicDims = tt.enabled
+
+		elements, dims := s.dynamicElements(diskMetric(int64(1), tt.disk).Namespace)
+		if !reflect.DeepEqual(elements, tt.elements) {
+			t.Errorf("%s: elements %v, want %v", tt.name, elements, tt.elements)
+		}
+		if !reflect.DeepEqual(dims, tt.dims) {
+			t.Errorf("%s: dimensions %v, want %v", tt.name, dims, tt.dims)
+		}
+	}
+}
+
+func TestDynamicDimensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		metrics  map[string]string // Metric name sent for each disk
+		dims     bool
+	}{
+		{"enabled", plugin.Config{"dynamic_dimensions": true}, map[string]string{
+			"sda": "snap.intel.procfs.disk.bytes_read",
+			"sdb": "snap.intel.procfs.disk.bytes_read",
+		}, true},
+		{"disabled", plugin.Config{"dynamic_dimensions": false}, map[string]string{
+			"sda": "snap.intel.procfs.disk.sda.bytes_read",
+			"sdb": "snap.intel.procfs.disk.sdb.bytes_read",
+		}, false},
+		{"absent", nil, map[string]string{
+			"sda": "snap.intel.procfs.disk.sda.bytes_read",
+			"sdb": "snap.intel.procfs.disk.sdb.bytes_read",
+		}, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{diskMetric(int64(1), "sda"), diskMetric(int64(2), "sdb")}, testConfig(is.URL, tt.settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+		if len(is.datapoints) != 2 {
+			t.Errorf("%s: sent %v, want one datapoint per disk", tt.name, is.datapoints)
+			continue
+		}
+
+		for disk, metric := range tt.metrics {
+			var dp received
+			var ok bool
+			if tt.dims {
+				dp, ok = is.find(metric, "disk", disk)
+			} else {
+				dp, ok = is.find(metric)
+			}
+			if !ok {
+				t.Errorf("%s: %s was not sent for %s, got %v", tt.name, metric, disk, is.datapoints)
+				continue
+			}
+			if _, dim := dp.Dimensions["disk"]; dim != tt.dims {
+				t.Errorf("%s: %s sent with a disk dimension = %v", tt.name, metric, dim)
+			}
+		}
+	}
+}
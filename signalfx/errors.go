@@ -0,0 +1,137 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/signalfx/golib/errors"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// Error categories
+const (
+	errorTimeout     = "timeout"      // The request timed out
+	errorNetwork     = "network"      // SignalFx could not be reached
+	errorAuth        = "auth"         // The token was rejected
+	errorRateLimited = "rate_limited" // SignalFx is throttling us
+	errorClient      = "client"       // SignalFx rejected the request
+	errorServer      = "server"       // SignalFx failed the request
+	errorDropped     = "dropped"      // The plugin dropped the datapoints
+	errorUnknown     = "unknown"      // Anything else
+)
+
+// classifyError returns the category of a send failure, looking past the
+// annotations the sink wraps transport and cancellation errors in
+func classifyError(err error) string {
+	cause := errors.Tail(err)
+	switch cause {
+	case context.DeadlineExceeded:
+		return errorTimeout
+	case errInflightFull:
+		return errorDropped
+	}
+
+	switch e := cause.(type) {
+	case sfxclient.SFXAPIError:
+		switch {
+		case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+			return errorAuth
+		case e.StatusCode == http.StatusTooManyRequests:
+			return errorRateLimited
+		case e.StatusCode >= http.StatusInternalServerError:
+			return errorServer
+		case e.StatusCode >= http.StatusBadRequest:
+			return errorClient
+		}
+	case net.Error:
+		if e.Timeout() {
+			return errorTimeout
+		}
+		return errorNetwork
+	}
+	return errorUnknown
+}
+
+// isServerError reports whether the error is a network failure or a 5xx
+// response from SignalFx, i.e. one another endpoint may not suffer from
+func isServerError(err error) bool {
+	switch classifyError(err) {
+	case errorTimeout, errorNetwork, errorServer:
+		return true
+	}
+	return false
+}
+
+// publishError - The send failures of a publish, telling a partial failure,
+// where some datapoints were sent, from a total one
+type publishError struct {
+	sent   int   // Datapoints sent
+	failed int   // Datapoints that failed to send
+	err    error // First failure
+}
+
+// Error describes the failure and how much of the publish was sent
+func (e *publishError) Error() string {
+	if e.sent > 0 {
+		return fmt.Sprintf("partial failure (%s), sent %d of %d datapoints to SignalFx: %v",
+			classifyError(e.err), e.sent, e.sent+e.failed, e.err)
+	}
+	return fmt.Sprintf("failed to send %d datapoints to SignalFx (%s): %v",
+		e.failed, classifyError(e.err), e.err)
+}
+
+// Error text the sink annotates a response body that is not a JSON string
+// with
+const unmarshalBodyPrefix = "cannot unmarshal response body "
+
+// partialSuccess returns the number of rejected datapoints when the error
+// is SignalFx accepting the request but reporting, in the response body,
+// that some datapoints were rejected, e.g. {"rejected": 3}. The sink fails
+// to unmarshal such a body, expecting the JSON string "OK".
+func partialSuccess(err error) (int, bool) {
+	if _, ok := errors.Tail(err).(*json.UnmarshalTypeError); !ok {
+		return 0, false
+	}
+	body := strings.TrimPrefix(errors.Message(err), unmarshalBodyPrefix)
+
+	var result struct {
+		Rejected int `json:"rejected"`
+	}
+	if json.Unmarshal([]byte(body), &result) != nil || result.Rejected <= 0 {
+		return 0, false
+	}
+	return result.Rejected, true
+}
+
+// isRetryable reports whether the error may go away if the request is
+// sent again
+func isRetryable(err error) bool {
+	switch classifyError(err) {
+	case errorTimeout, errorNetwork, errorServer, errorRateLimited:
+		return true
+	}
+	return false
+}
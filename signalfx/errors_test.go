@@ -0,0 +1,298 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// sinkError returns the error of a sink sending a datapoint to the
+// endpoint, with the client timeout, if any, and the context
+func sinkError(ctx context.Context, endpoint string, timeout time.Duration) error {
+	sink := sfxclient.NewHTTPDatapointSink()
+	sink.Endpoint = endpoint
+	if timeout > 0 {
+		sink.Client.Timeout = timeout
+	}
+	return sink.AddDatapoints(ctx, []*datapoint.Datapoint{sfxclient.Gauge("test", nil, 1)})
+}
+
+func TestClassifyError(t *testing.T) {
+	closed := statusServer(http.StatusOK)
+	closed.Close()
+	slow := slowServer()
+	defer slow.Close()
+
+	tests := []struct {
+		name      string
+		err       func() error
+		category  string
+		retryable bool
+	}{
+		{"unavailable", func() error {
+			ts := statusServer(http.StatusServiceUnavailable)
+			defer ts.Close()
+			return sinkError(context.Background(), ts.URL, 0)
+		}, errorServer, true},
+		{"unauthorized", func() error {
+			ts := statusServer(http.StatusUnauthorized)
+			defer ts.Close()
+			return sinkError(context.Background(), ts.URL, 0)
+		}, errorAuth, false},
+		{"throttled", func() error {
+			ts := statusServer(http.StatusTooManyRequests)
+			defer ts.Close()
+			return sinkError(context.Background(), ts.URL, 0)
+		}, errorRateLimited, true},
+		{"bad request", func() error {
+			ts := statusServer(http.StatusBadRequest)
+			defer ts.Close()
+			return sinkError(context.Background(), ts.URL, 0)
+		}, errorClient, false},
+		{"connection refused", func() error {
+			return sinkError(context.Background(), closed.URL, 0)
+		}, errorNetwork, true},
+		{"client timeout", func() error {
+			return sinkError(context.Background(), slow.URL, 10*time.Millisecond)
+		}, errorTimeout, true},
+		{"context deadline", func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			return sinkError(ctx, slow.URL, 0)
+		}, errorTimeout, true},
+		{"dropped over the in-flight budget", func() error {
+			return errInflightFull
+		}, errorDropped, false},
+	}
+	for _, tt := range tests {
+		err := tt.err()
+		if err == nil {
+			t.Errorf("%s: the sink returned no error", tt.name)
+			continue
+		}
+		if got := classifyError(err); got != tt.category {
+			t.Errorf("%s: classifyError(%v) = %s, want %s", tt.name, err, got, tt.category)
+		}
+		if got := isRetryable(err); got != tt.retryable {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", tt.name, err, got, tt.retryable)
+		}
+	}
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		requests int
+		ok       bool
+	}{
+		{"recovers", 2, 3, true},
+		{"gives up", 5, 4, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		failures := tt.failures
+		is.status = func(request int) int {
+			if request <= failures {
+				return http.StatusServiceUnavailable
+			}
+			return http.StatusOK
+		}
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(is.URL, plugin.Config{
+			"payload_format": formatProtobuf,
+			"max_retries":    int64(3),
+			"retry_backoff":  int64(1),
+		}))
+		is.Close()
+
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+		if got := is.requestCount(); got != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.requests)
+		}
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+	is.status = func(int) int { return http.StatusServiceUnavailable }
+
+	s := newTestPlugin()
+	start := time.Now()
+	err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(is.URL, plugin.Config{
+		"payload_format": formatProtobuf,
+		"max_retries":    int64(10),
+		"retry_backoff":  int64(50),
+		"timeout":        "100ms",
+	}))
+
+	if err == nil {
+		t.Fatal("Publish returned no error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Publish took %v, retrying past its timeout", elapsed)
+	}
+	if got := is.requestCount(); got >= 10 {
+		t.Errorf("%d requests, want fewer than the 11 attempts allowed", got)
+	}
+}
+
+func TestPartialSuccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		rejected int
+		ok       bool
+	}{
+		{"rejected", http.StatusOK, `{"rejected": 3}`, 3, true},
+		{"none rejected", http.StatusOK, `{"rejected": 0}`, 0, false},
+		{"other object", http.StatusOK, `{"accepted": 3}`, 0, false},
+		{"array", http.StatusOK, `[3]`, 0, false},
+		{"invalid string", http.StatusOK, `"NOT OK"`, 0, false},
+		{"not JSON", http.StatusOK, `rejected 3`, 0, false},
+		{"server error", http.StatusInternalServerError, `{"rejected": 3}`, 0, false},
+	}
+	for _, tt := range tests {
+		server := bodyServer(tt.status, tt.body)
+
+		s := newTestPlugin()
+		sink := sfxclient.NewHTTPDatapointSink()
+		sink.Endpoint = server.URL
+		dps := []*datapoint.Datapoint{sfxclient.Gauge("test", nil, 1)}
+		errs := map[string]error{
+			"sink": sink.AddDatapoints(context.Background(), dps),
+			"json": s.postJSON(context.Background(), sink, dps),
+		}
+		server.Close()
+
+		for path, err := range errs {
+			if err == nil {
+				t.Errorf("%s: %s send succeeded", tt.name, path)
+				continue
+			}
+			rejected, ok := partialSuccess(err)
+			if rejected != tt.rejected || ok != tt.ok {
+				t.Errorf("%s: partialSuccess(%v) from the %s send = %d, %v, want %d, %v",
+					tt.name, err, path, rejected, ok, tt.rejected, tt.ok)
+			}
+		}
+	}
+}
+
+func TestPublishPartialSuccess(t *testing.T) {
+	for _, format := range []string{formatProtobuf, formatJSON} {
+		server := bodyServer(http.StatusOK, `{"rejected": 3}`)
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(server.URL, plugin.Config{
+			"payload_format": format,
+		}))
+		server.Close()
+
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", format, err)
+		} else if s.lastErr != nil {
+			t.Errorf("%s: Publish failed with %v, want the partial success accepted", format, s.lastErr)
+		}
+	}
+}
+
+func TestPublishError(t *testing.T) {
+	cause := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		err  *publishError
+		want string
+	}{
+		{"total failure", &publishError{sent: 0, failed: 4, err: cause},
+			"failed to send 4 datapoints to SignalFx (unknown): connection reset"},
+		{"partial failure", &publishError{sent: 3, failed: 1, err: cause},
+			"partial failure (unknown), sent 3 of 4 datapoints to SignalFx: connection reset"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishReturnsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status func(request int) int
+		err    string // Expected in the error, "" for none
+		sent   int
+		failed int
+	}{
+		{"accepted", func(int) int { return http.StatusOK }, "", 0, 0},
+		{"unauthorized", func(int) int { return http.StatusUnauthorized }, "(" + errorAuth + ")", 0, 2},
+		{"throttled", func(int) int { return http.StatusTooManyRequests }, "(" + errorRateLimited + ")", 0, 2},
+		{"partial failure", func(request int) int {
+			if request == 1 {
+				return http.StatusOK
+			}
+			return http.StatusBadRequest
+		}, "partial failure (" + errorClient + ")", 1, 1},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		is.status = tt.status
+
+		// A timeout of its own sends the second metric as its own request
+		other := newMetric(int64(2), "intel", "net", "bytes")
+		other.Tags = map[string]string{tagTimeout: "5000"}
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle"), other}, testConfig(is.URL, nil))
+		is.Close()
+
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Publish returned %v, want %q", tt.name, err, tt.err)
+			continue
+		}
+		if pe, ok := err.(*publishError); !ok || pe.sent != tt.sent || pe.failed != tt.failed {
+			t.Errorf("%s: Publish returned %#v, want %d sent and %d failed", tt.name, err, tt.sent, tt.failed)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Filters metrics or datapoints are dropped by, the filter dimension of
+// the filtered self metric
+const (
+	filterEmptyNamespace  = "empty_namespace"       // Namespaces only the prefix would name
+	filterEmptyName       = "empty_name"            // Names left empty by the naming settings
+	filterFutureTimestamp = "future_timestamp"      // reject_future_timestamps
+	filterTransform       = "transform"             // NaN or Inf transform_rules results
+	filterDimensionKeys   = "dimension_keys"        // validate_dimensions
+	filterAllowlist       = "allowlist"             // dimension_value_allowlist
+	filterMaxSeries       = "max_series"            // max_series
+	filterDeadband        = "deadband"              // deadband
+	filterUnchanged       = "send_on_change"        // send_on_change
+	filterOversize        = "max_datapoint_bytes"   // max_datapoint_bytes
+	filterTinyFloat       = "min_abs_float"         // min_abs_float_action drop
+	filterRateLimit       = "per_metric_rate_limit" // per_metric_rate_limit
+)
+
+// Filters in the order they are reported
+var filters = []string{
+	filterEmptyNamespace,
+	filterFutureTimestamp,
+	filterEmptyName,
+	filterTransform,
+	filterDimensionKeys,
+	filterAllowlist,
+	filterMaxSeries,
+	filterDeadband,
+	filterTinyFloat,
+	filterUnchanged,
+	filterOversize,
+	filterRateLimit,
+}
+
+// countFiltered counts a metric or datapoint dropped by the filter during
+// the current publish
+func (s *SignalFx) countFiltered(filter string) {
+	if s.filtered != nil {
+		s.filtered[filter]++
+	}
+}
@@ -0,0 +1,124 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestFilteredCounts(t *testing.T) {
+	tagged := func(data interface{}, tags map[string]string, ns ...string) plugin.Metric {
+		m := newMetric(data, ns...)
+		m.Tags = tags
+		return m
+	}
+
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	err := s.Publish([]plugin.Metric{
+		// Sent
+		newMetric(int64(1), "intel", "cpu", "idle"),
+		tagged(int64(1), map[string]string{"env": "prod"}, "intel", "cpu", "user"),
+		// Empty namespaces
+		newMetric(int64(1)),
+		newMetric(int64(1), " "),
+		// Invalid dimension key
+		tagged(int64(1), map[string]string{"0core": "1"}, "intel", "cpu", "system"),
+		// Value outside the allowlist
+		tagged(int64(1), map[string]string{"env": "dev"}, "intel", "cpu", "nice"),
+		// Transform dividing by zero
+		newMetric(int64(0), "intel", "ratio"),
+		// Tiny float
+		newMetric(1e-12, "intel", "load"),
+	}, testConfig(is.URL, plugin.Config{
+		"self_metrics":               true,
+		"validate_dimensions":        true,
+		"validate_dimensions_policy": actionDrop,
+		"dimension_value_allowlist":  "env=prod|staging:drop",
+		"transform_rules":            "/intel/ratio=1 / value",
+		"min_abs_float":              1e-9,
+		"min_abs_float_action":       "drop",
+	}))
+	if err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	for _, name := range []string{"snap.intel.cpu.idle", "snap.intel.cpu.user"} {
+		if _, ok := is.find(name); !ok {
+			t.Errorf("%s was not sent", name)
+		}
+	}
+
+	want := map[string]string{
+		filterEmptyNamespace: "2",
+		filterDimensionKeys:  "1",
+		filterAllowlist:      "1",
+		filterTransform:      "1",
+		filterTinyFloat:      "1",
+	}
+	for _, filter := range filters {
+		count, ok := want[filter]
+		if !ok {
+			count = "0"
+		}
+		dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filter)
+		if !ok {
+			t.Errorf("filtered was not sent for %s", filter)
+			continue
+		}
+		if fmt.Sprint(dp.Value) != count {
+			t.Errorf("filtered %s = %v, want %s", filter, dp.Value, count)
+		}
+	}
+}
+
+func TestFilteredCountsPerPublish(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{"self_metrics": true})
+	cycles := [][]plugin.Metric{
+		{newMetric(int64(1)), newMetric(int64(1), "intel", "cpu", "idle")},
+		{newMetric(int64(1), "intel", "cpu", "idle")},
+	}
+
+	for i, want := range []string{"1", "0"} {
+		is.mu.Lock()
+		is.datapoints = nil
+		is.mu.Unlock()
+
+		if err := s.Publish(cycles[i], cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+		dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filterEmptyNamespace)
+		if !ok || fmt.Sprint(dp.Value) != want {
+			t.Errorf("Publish %d: filtered %v (%v), want %s", i, dp.Value, ok, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Default seconds between resets of the endpoint health scores
+const defaultHealthReset = 300
+
+// endpointHealth - Scores the recent sends to a target's primary and
+// fallback endpoints, one point up for a success and down for a failure
+type endpointHealth struct {
+	primary  int        // Score of the primary endpoint
+	fallback int        // Score of the fallback endpoint
+	resetAt  time.Time  // When the scores were last reset
+	mu       sync.Mutex // Guards the above
+}
+
+// setEndpointHealth will enable preferring the healthier of the primary and
+// fallback endpoints if the endpoint_health config setting is present in
+// the task file
+func (s *SignalFx) setEndpointHealth(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "endpoint_health")
+	if err != nil || !enabled {
+		return
+	}
+
+	reset := int64(defaultHealthReset)
+	if n, err := s.getInt(cfg, "endpoint_health_reset"); err == nil && n > 0 {
+		reset = n
+	}
+	s.healthReset = time.Duration(reset) * time.Second
+
+	s.logf("Preferring the healthier endpoint, resetting scores every %d seconds", reset)
+}
+
+// preferFallback reports whether the fallback endpoint has been healthier
+// than the primary, resetting the scores once the window has passed
+func (h *endpointHealth) preferFallback(now time.Time, window time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(h.resetAt) >= window {
+		h.primary, h.fallback = 0, 0
+		h.resetAt = now
+	}
+	return h.fallback > h.primary
+}
+
+// record scores a send to the primary or fallback endpoint
+func (h *endpointHealth) record(fallback bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	score := &h.primary
+	if fallback {
+		score = &h.fallback
+	}
+
+	if err == nil {
+		*score++
+	} else {
+		*score--
+	}
+}
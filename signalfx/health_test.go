@@ -0,0 +1,116 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestEndpointHealthScores(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name     string
+		primary  []error
+		fallback []error
+		prefer   bool
+	}{
+		{"no sends", nil, nil, false},
+		{"primary failing", []error{failed, failed}, []error{nil, nil}, true},
+		{"primary recovered", []error{failed, nil, nil}, []error{nil}, false},
+		{"both failing", []error{failed}, []error{failed, failed}, false},
+		{"tied", []error{nil}, []error{nil}, false},
+	}
+	for _, tt := range tests {
+		start := time.Unix(1500000000, 0)
+		var h endpointHealth
+		h.preferFallback(start, time.Minute)
+
+		for _, err := range tt.primary {
+			h.record(false, err)
+		}
+		for _, err := range tt.fallback {
+			h.record(true, err)
+		}
+
+		if got := h.preferFallback(start.Add(time.Second), time.Minute); got != tt.prefer {
+			t.Errorf("%s: prefer fallback = %v, want %v", tt.name, got, tt.prefer)
+		}
+		if h.preferFallback(start.Add(time.Minute), time.Minute) {
+			t.Errorf("%s: fallback preferred after the scores were reset", tt.name)
+		}
+	}
+}
+
+func TestEndpointHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		advance  time.Duration // Clock advance between publishes
+		requests int           // Requests to the failing primary
+	}{
+		{"prefers the fallback", plugin.Config{"endpoint_health": true}, time.Second, 1},
+		{"reset retries the primary", plugin.Config{
+			"endpoint_health":       true,
+			"endpoint_health_reset": int64(1),
+		}, 2 * time.Second, 3},
+		{"disabled", plugin.Config{"endpoint_health": false}, time.Second, 3},
+		{"absent", nil, time.Second, 3},
+	}
+	for _, tt := range tests {
+		primary := newIngestServer()
+		primary.status = func(int) int { return http.StatusServiceUnavailable }
+		fallback := newIngestServer()
+
+		settings := plugin.Config{"fallback_endpoint": fallback.URL}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		now := time.Unix(1500000000, 0)
+		s := newTestPlugin()
+		s.now = func() time.Time { return now }
+
+		cfg := testConfig(primary.URL, settings)
+		for i := 0; i < 3; i++ {
+			if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+			now = now.Add(tt.advance)
+		}
+		primary.Close()
+		fallback.Close()
+
+		if n := primary.requestCount(); n != tt.requests {
+			t.Errorf("%s: %d requests to the primary, want %d", tt.name, n, tt.requests)
+		}
+		if n := fallback.requestCount(); n != 3 {
+			t.Errorf("%s: %d requests to the fallback, want 3", tt.name, n)
+		}
+	}
+}
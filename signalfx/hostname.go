@@ -0,0 +1,74 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Delimiter between hostname parts, unless hostname_delimiter is set
+const defaultHostnameDelimiter = "-"
+
+// setHostnameDimensions will split the hostname on the hostname_delimiter
+// and send its parts as the dimensions named by the hostname_dimensions
+// setting, e.g. role-env-region-az-index for web-prod-us-east-01; a name
+// of _ skips a part
+func (s *SignalFx) setHostnameDimensions(cfg plugin.Config) {
+	value, err := s.getString(cfg, "hostname_dimensions")
+	if err != nil || value == "" {
+		// No hostname_dimensions defined, moving on
+		return
+	}
+
+	delimiter := defaultHostnameDelimiter
+	if d, err := s.getString(cfg, "hostname_delimiter"); err == nil && d != "" {
+		delimiter = d
+	}
+
+	fields := strings.Split(value, delimiter)
+	s.hostDims = hostnameDimensions(s.hostname, fields, delimiter)
+	for _, field := range fields {
+		if _, ok := s.hostDims[field]; !ok && field != "_" {
+			s.logf("Hostname %s has no valid %s part, skipping it", s.hostname, field)
+		}
+	}
+
+	s.logf("Using hostname dimensions %v", s.hostDims)
+}
+
+// hostnameDimensions returns the parts of the hostname as the dimensions
+// named by the fields, skipping fields without a valid part
+func hostnameDimensions(hostname string, fields []string, delimiter string) map[string]string {
+	parts := strings.Split(hostname, delimiter)
+
+	dims := make(map[string]string)
+	for i, field := range fields {
+		if field == "_" || i >= len(parts) {
+			continue
+		}
+		if validateDimensionKey(field) != nil || validateDimensionValue(parts[i]) != nil {
+			continue
+		}
+		dims[field] = parts[i]
+	}
+	return dims
+}
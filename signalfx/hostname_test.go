@@ -0,0 +1,102 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestHostnameDimensions(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostname  string
+		fields    string
+		delimiter string
+		dims      map[string]string
+	}{
+		{"structured", "web-prod-us-east-01", "role-env-region-az-index", "-", map[string]string{
+			"role": "web", "env": "prod", "region": "us", "az": "east", "index": "01",
+		}},
+		{"skipped part", "web-prod-us-east-01", "role-_-region", "-", map[string]string{
+			"role": "web", "region": "us",
+		}},
+		{"fewer parts", "web-prod", "role-env-region", "-", map[string]string{
+			"role": "web", "env": "prod",
+		}},
+		{"other delimiter", "db.staging.eu", "role.env.region", ".", map[string]string{
+			"role": "db", "env": "staging", "region": "eu",
+		}},
+		{"invalid field", "web-prod", "role-1env", "-", map[string]string{
+			"role": "web",
+		}},
+		{"invalid part", "web-pr+od", "role-env", "-", map[string]string{
+			"role": "web",
+		}},
+		{"empty part", "web--01", "role-env-index", "-", map[string]string{
+			"role": "web", "index": "01",
+		}},
+	}
+	for _, tt := range tests {
+		got := hostnameDimensions(tt.hostname, strings.Split(tt.fields, tt.delimiter), tt.delimiter)
+		if !reflect.DeepEqual(got, tt.dims) {
+			t.Errorf("%s: hostnameDimensions(%q, %q) = %v, want %v", tt.name, tt.hostname, tt.fields, got, tt.dims)
+		}
+	}
+}
+
+func TestHostnameDimensionsSent(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		dims     map[string]string // Expected dimension, "" when absent
+	}{
+		{"default delimiter", plugin.Config{
+			"hostname":            "web-prod-us-east-01",
+			"hostname_dimensions": "role-env-region-az-index",
+		}, map[string]string{"host": "web-prod-us-east-01", "role": "web", "env": "prod", "index": "01"}},
+		{"configured delimiter", plugin.Config{
+			"hostname":            "web_prod",
+			"hostname_dimensions": "role_env",
+			"hostname_delimiter":  "_",
+		}, map[string]string{"host": "web_prod", "role": "web", "env": "prod"}},
+		{"absent", plugin.Config{
+			"hostname": "web-prod-us-east-01",
+		}, map[string]string{"host": "web-prod-us-east-01", "role": "", "env": ""}},
+	}
+	for _, tt := range tests {
+		dp, ok := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		for key, want := range tt.dims {
+			if got := dp.Dimensions[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
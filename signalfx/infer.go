@@ -0,0 +1,82 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"regexp"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Named group of an infer_dimensions pattern holding the metric name
+const inferMetricGroup = "metric"
+
+// setInferDimensions will compile the infer_dimensions patterns, which are
+// regular expressions separated by ';' whose named groups are matched
+// against the namespace, e.g. "^/intel/disk/(?P<device>[^/]+)/(?P<metric>.+)$"
+func (s *SignalFx) setInferDimensions(cfg plugin.Config) {
+	value, err := s.getString(cfg, "infer_dimensions")
+	if err != nil {
+		// No infer_dimensions defined, moving on
+		return
+	}
+
+	for _, pattern := range strings.Split(value, ";") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			s.logf("Ignoring infer_dimensions pattern %q: %v", pattern, err)
+			continue
+		}
+
+		s.logf("Inferring dimensions from namespaces matching %s", pattern)
+		s.inferPatterns = append(s.inferPatterns, re)
+	}
+}
+
+// inferDimensions matches the namespace against the infer_dimensions
+// patterns, returning the metric name from the metric group, if any, and
+// the other named groups as dimensions. The first matching pattern wins.
+func (s *SignalFx) inferDimensions(namespace string) (string, map[string]string, bool) {
+	for _, re := range s.inferPatterns {
+		match := re.FindStringSubmatch(namespace)
+		if match == nil {
+			continue
+		}
+
+		var name string
+		dims := make(map[string]string)
+		for i, group := range re.SubexpNames() {
+			switch {
+			case group == "" || match[i] == "":
+			case group == inferMetricGroup:
+				name = s.prefixName(strings.Replace(strings.Trim(match[i], "/"), "/", ".", -1))
+			default:
+				dims[group] = match[i]
+			}
+		}
+		return name, dims, true
+	}
+	return "", nil, false
+}
@@ -0,0 +1,77 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestInferDimensions(t *testing.T) {
+	const disk = "^/intel/psutil/disk/(?P<device>[^/]+)/(?P<metric>.+)$"
+
+	tests := []struct {
+		name       string
+		patterns   string
+		ns         []string
+		metric     string
+		dimensions map[string]string
+	}{
+		{"device and metric", disk,
+			[]string{"intel", "psutil", "disk", "sda", "read_bytes"},
+			"snap.read_bytes", map[string]string{"device": "sda"}},
+		{"nested metric", disk,
+			[]string{"intel", "psutil", "disk", "sda", "io", "read_bytes"},
+			"snap.io.read_bytes", map[string]string{"device": "sda"}},
+		{"no metric group", "^/intel/psutil/disk/(?P<device>[^/]+)/",
+			[]string{"intel", "psutil", "disk", "sda", "read_bytes"},
+			"snap.intel.psutil.disk.sda.read_bytes", map[string]string{"device": "sda"}},
+		{"first matching pattern", "^/intel/(?P<plugin>[^/]+)/(?P<metric>.+)$;" + disk,
+			[]string{"intel", "psutil", "disk", "sda", "read_bytes"},
+			"snap.disk.sda.read_bytes", map[string]string{"plugin": "psutil", "device": ""}},
+		{"second pattern", "^/intel/procfs/(?P<metric>.+)$;" + disk,
+			[]string{"intel", "psutil", "disk", "sda", "read_bytes"},
+			"snap.read_bytes", map[string]string{"device": "sda"}},
+		{"no match", disk,
+			[]string{"intel", "psutil", "cpu", "idle"},
+			"snap.intel.psutil.cpu.idle", map[string]string{"device": ""}},
+		{"invalid pattern ignored", "^/intel/(?P<device[^/]+;" + disk,
+			[]string{"intel", "psutil", "disk", "sda", "read_bytes"},
+			"snap.read_bytes", map[string]string{"device": "sda"}},
+	}
+	for _, tt := range tests {
+		dps := publish(t, plugin.Config{"infer_dimensions": tt.patterns}, newMetric(int64(1), tt.ns...))
+
+		dp, ok := dps[tt.metric]
+		if !ok {
+			t.Errorf("%s: %s was not sent, got %v", tt.name, tt.metric, dps)
+			continue
+		}
+		for key, want := range tt.dimensions {
+			if got := dp.Dimensions[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Buffer full policies
+const (
+	policyBlock = "block" // Wait for room
+	policyDrop  = "drop"  // Drop the datapoints
+)
+
+// Approximate encoded size of a datapoint value and timestamp
+const datapointOverhead = 32
+
+// Failure of the datapoints dropped by the drop policy
+var errInflightFull = errors.New("max_inflight_bytes exceeded, dropped the datapoints")
+
+// inflightLimiter - Caps the approximate number of bytes being sent
+type inflightLimiter struct {
+	max  int64      // Maximum in-flight bytes (0 is unlimited)
+	drop bool       // Drop instead of block when full
+	used int64      // Current in-flight bytes
+	cond *sync.Cond // Signalled as sends complete
+
+	dropped int64 // Datapoints dropped when full
+}
+
+// setInflightLimit will configure the max_inflight_bytes setting and the
+// buffer_full_policy applied when it is exceeded
+func (s *SignalFx) setInflightLimit(cfg plugin.Config) {
+	s.inflight.cond = sync.NewCond(new(sync.Mutex))
+
+	max, err := s.getInt(cfg, "max_inflight_bytes")
+	if err != nil || max <= 0 {
+		// No max_inflight_bytes defined, moving on
+		return
+	}
+	s.inflight.max = max
+
+	policy, err := s.getString(cfg, "buffer_full_policy")
+	if err == nil && policy == policyDrop {
+		s.inflight.drop = true
+	} else if err == nil && policy != policyBlock {
+		s.logf("Unknown buffer_full_policy %q, using %s", policy, policyBlock)
+	}
+
+	s.logf("Limiting in-flight datapoints to %d bytes", max)
+}
+
+// acquire reserves room for size bytes, blocking until the batches being
+// sent by other workers make room or returning false when the policy is to
+// drop. A single request larger than the limit is let through once nothing
+// else is in flight.
+func (l *inflightLimiter) acquire(size int64) bool {
+	if l.max <= 0 {
+		return true
+	}
+
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	for l.used > 0 && l.used+size > l.max {
+		if l.drop {
+			return false
+		}
+		l.cond.Wait()
+	}
+	l.used += size
+	return true
+}
+
+// release returns size bytes to the budget once a send completes
+func (l *inflightLimiter) release(size int64) {
+	if l.max <= 0 {
+		return
+	}
+
+	l.cond.L.Lock()
+	l.used -= size
+	l.cond.L.Unlock()
+	l.cond.Broadcast()
+}
+
+// approximateSize estimates the serialized size of the datapoints
+func approximateSize(dps []*datapoint.Datapoint) int64 {
+	var size int64
+	for _, dp := range dps {
+		size += datapointSize(dp)
+	}
+	return size
+}
+
+// datapointSize estimates the serialized size of a single datapoint
+func datapointSize(dp *datapoint.Datapoint) int64 {
+	size := int64(len(dp.Metric) + datapointOverhead)
+	for k, v := range dp.Dimensions {
+		size += int64(len(k) + len(v))
+	}
+	for k, v := range dp.GetProperties() {
+		size += int64(len(k) + len(fmt.Sprint(v)))
+	}
+	if dp.Value != nil {
+		size += int64(len(dp.Value.String()))
+	}
+	return size
+}
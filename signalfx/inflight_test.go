@@ -0,0 +1,205 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// newLimiter returns an in-flight limiter configured by the settings
+func newLimiter(settings plugin.Config) *inflightLimiter {
+	s := newTestPlugin()
+	s.setInflightLimit(settings)
+	return &s.inflight
+}
+
+func TestInflightLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sizes    []int64
+		acquired []bool
+	}{
+		{"unlimited", plugin.Config{}, []int64{1000, 1000}, []bool{true, true}},
+		{"within budget", plugin.Config{
+			"max_inflight_bytes": int64(100),
+			"buffer_full_policy": policyDrop,
+		}, []int64{40, 60}, []bool{true, true}},
+		{"budget exceeded", plugin.Config{
+			"max_inflight_bytes": int64(100),
+			"buffer_full_policy": policyDrop,
+		}, []int64{60, 60, 40}, []bool{true, false, true}},
+		{"oversized alone", plugin.Config{
+			"max_inflight_bytes": int64(100),
+			"buffer_full_policy": policyDrop,
+		}, []int64{500, 1}, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		l := newLimiter(tt.settings)
+		for i, size := range tt.sizes {
+			if got := l.acquire(size); got != tt.acquired[i] {
+				t.Errorf("%s: acquire(%d) #%d = %v, want %v", tt.name, size, i, got, tt.acquired[i])
+			}
+		}
+	}
+}
+
+func TestInflightLimitBlocks(t *testing.T) {
+	l := newLimiter(plugin.Config{"max_inflight_bytes": int64(100)})
+	if !l.acquire(80) {
+		t.Fatal("acquire within the budget failed")
+	}
+
+	var wg sync.WaitGroup
+	acquired := make(chan bool, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		acquired <- l.acquire(80)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire over the budget did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.release(80)
+	wg.Wait()
+	if !<-acquired {
+		t.Error("acquire failed once the budget was released")
+	}
+	if l.used != 80 {
+		t.Errorf("%d bytes in flight, want 80", l.used)
+	}
+}
+
+func TestInflightLimitDropsDatapoints(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	s.setSinks(plugin.Config{"endpoint": is.URL})
+	s.setInflightLimit(plugin.Config{
+		"max_inflight_bytes": int64(10),
+		"buffer_full_policy": policyDrop,
+	})
+	s.inflight.used = 1
+	s.send(sfxclient.Gauge("snap.intel.cpu.idle", nil, 1))
+	s.flushBatches()
+	if n := is.requestCount(); n != 0 {
+		t.Errorf("%d requests sent over the in-flight budget", n)
+	}
+}
+
+func TestInflightLimitPublish(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		sent    int
+		dropped int64
+		most    int
+	}{
+		{"block", policyBlock, 2, 0, 1},
+		{"drop", policyDrop, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		// Hold each request so that the batches overlap
+		var mu sync.Mutex
+		var active, most int
+		is := newIngestServer()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			active++
+			if active > most {
+				most = active
+			}
+			mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+			is.handle(w, r)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}))
+
+		// Two batches sent at once, by their timeouts, either over the budget
+		idle := newMetric(int64(1), "intel", "cpu", "idle")
+		idle.Tags = map[string]string{tagTimeout: "1000"}
+		user := newMetric(int64(2), "intel", "cpu", "user")
+		user.Tags = map[string]string{tagTimeout: "2000"}
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{idle, user}, testConfig(ts.URL, plugin.Config{
+			"worker_pool_size":   int64(2),
+			"max_inflight_bytes": int64(10),
+			"buffer_full_policy": tt.policy,
+			"self_metrics":       true,
+		}))
+		ts.Close()
+		is.Close()
+
+		if most != tt.most {
+			t.Errorf("%s: %d batches sent at once, want %d", tt.name, most, tt.most)
+		}
+		var sent int
+		for _, name := range []string{"snap.intel.cpu.idle", "snap.intel.cpu.user"} {
+			if _, ok := is.find(name); ok {
+				sent++
+			}
+		}
+		if sent != tt.sent {
+			t.Errorf("%s: %d metrics sent, want %d", tt.name, sent, tt.sent)
+		}
+
+		if tt.dropped == 0 {
+			if err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+			continue
+		}
+
+		perr, ok := err.(*publishError)
+		if !ok {
+			t.Errorf("%s: Publish returned %v, want the dropped datapoints", tt.name, err)
+			continue
+		}
+		if int64(perr.failed) != tt.dropped || classifyError(perr.err) != errorDropped {
+			t.Errorf("%s: Publish returned %v, want %d dropped", tt.name, err, tt.dropped)
+		}
+		if dp, ok := is.find(selfMetricPrefix + "inflight_dropped"); !ok || dp.Value != tt.dropped {
+			t.Errorf("%s: sent inflight_dropped %v, want %d", tt.name, dp.Value, tt.dropped)
+		}
+		if dp, ok := is.find(selfMetricPrefix + "last_error"); !ok || dp.Dimensions["error_category"] != errorDropped {
+			t.Errorf("%s: sent last_error %v, want the dropped category", tt.name, dp.Dimensions)
+		}
+	}
+}
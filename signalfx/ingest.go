@@ -0,0 +1,96 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Ingest API version used when api_version is absent
+const defaultAPIVersion = "v2"
+
+// Ingest datapoint paths by API version
+var apiPaths = map[string]string{
+	"v2": "/v2/datapoint",
+}
+
+// setAPIVersion will set the ingest path appended to endpoints given
+// without one from the api_version setting, returning an error when the
+// version is unknown
+func (s *SignalFx) setAPIVersion(cfg plugin.Config) error {
+	s.ingestPath = apiPaths[defaultAPIVersion]
+
+	version, err := s.getString(cfg, "api_version")
+	if err != nil {
+		// No api_version defined, moving on
+		return nil
+	}
+
+	path, ok := apiPaths[strings.TrimSpace(version)]
+	if !ok {
+		return fmt.Errorf("unknown api_version %q", version)
+	}
+	s.ingestPath = path
+
+	s.logf("Using ingest API %s", version)
+	return nil
+}
+
+// validateEndpoint checks the endpoint is an absolute http or https URL,
+// e.g. https://ingest.eu0.signalfx.com/v2/datapoint
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", endpoint)
+	}
+	return nil
+}
+
+// checkEndpoint returns the endpoint setting when it is valid and empty,
+// for the library default, otherwise
+func (s *SignalFx) checkEndpoint(key, endpoint string) string {
+	if endpoint == "" {
+		return ""
+	}
+	if err := validateEndpoint(endpoint); err != nil {
+		s.errorf("Ignoring %s, using the default: %v", key, err)
+		return ""
+	}
+	return endpoint
+}
+
+// ingestURL returns the endpoint with the ingest path of the API version
+// when it has no path of its own
+func (s *SignalFx) ingestURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || strings.Trim(u.Path, "/") != "" {
+		return endpoint
+	}
+
+	u.Path = s.ingestPath
+	return u.String()
+}
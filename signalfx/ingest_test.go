@@ -0,0 +1,113 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestAPIVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		path     string
+		ok       bool
+	}{
+		{"default", nil, "/v2/datapoint", true},
+		{"known", plugin.Config{"api_version": "v2"}, "/v2/datapoint", true},
+		{"unknown", plugin.Config{"api_version": "v9"}, "", false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(is.URL, tt.settings))
+		is.Close()
+
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: Publish returned no error", tt.name)
+			}
+			if is.requestCount() != 0 {
+				t.Errorf("%s: datapoints were sent", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+		if want := is.URL + tt.path; s.targets[0].sink.Endpoint != want {
+			t.Errorf("%s: endpoint = %s, want %s", tt.name, s.targets[0].sink.Endpoint, want)
+		}
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		ok       bool
+	}{
+		{"https://ingest.eu0.signalfx.com/v2/datapoint", true},
+		{"https://ingest.eu0.signalfx.com", true},
+		{"http://gateway.local:8080/v2/datapoint", true},
+		{"ingest.eu0.signalfx.com/v2/datapoint", false},
+		{"ftp://ingest.eu0.signalfx.com", false},
+		{"https://", false},
+		{"http://[::1", false},
+	}
+	for _, tt := range tests {
+		if err := validateEndpoint(tt.endpoint); (err == nil) != tt.ok {
+			t.Errorf("validateEndpoint(%q) = %v", tt.endpoint, err)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	def := sfxclient.NewHTTPDatapointSink().Endpoint
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		endpoint string
+	}{
+		{"realm", plugin.Config{"endpoint": "https://ingest.eu0.signalfx.com/v2/datapoint"},
+			"https://ingest.eu0.signalfx.com/v2/datapoint"},
+		{"proxy without a path", plugin.Config{"endpoint": "http://gateway.local:8080"},
+			"http://gateway.local:8080/v2/datapoint"},
+		{"invalid", plugin.Config{"endpoint": "ingest.eu0.signalfx.com"}, def},
+		{"absent", plugin.Config{}, def},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.ingestPath = "/v2/datapoint"
+		s.setSinks(tt.settings)
+		if got := s.targets[0].sink.Endpoint; got != tt.endpoint {
+			t.Errorf("%s: sending to %s, want %s", tt.name, got, tt.endpoint)
+		}
+	}
+}
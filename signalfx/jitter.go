@@ -0,0 +1,85 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// setRandom will seed the random number generator from the hostname, so
+// each host gets a stable but different sequence
+func (s *SignalFx) setRandom() {
+	h := fnv.New64a()
+	h.Write([]byte(s.hostname))
+	s.rng = rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// setPublishJitter will set the maximum delay before each publish from
+// the publish_jitter setting, in milliseconds
+func (s *SignalFx) setPublishJitter(cfg plugin.Config) {
+	ms, err := s.getInt(cfg, "publish_jitter")
+	if err != nil || ms <= 0 {
+		// No publish_jitter defined, moving on
+		return
+	}
+	s.publishJitter = time.Duration(ms) * time.Millisecond
+
+	s.logf("Delaying publishes by up to %v", s.publishJitter)
+}
+
+// jitter returns a random duration in [0, max)
+func (s *SignalFx) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Duration(s.rng.Int63n(int64(max)))
+}
+
+// delayPublish waits a random delay up to publish_jitter, capped at the
+// publish's timeout, returning early with the error of the publish's
+// context if it is done first, e.g. by Close
+func (s *SignalFx) delayPublish() error {
+	max := s.publishJitter
+	if s.publishTimeout > 0 && max > s.publishTimeout {
+		max = s.publishTimeout
+	}
+	if max <= 0 {
+		return nil
+	}
+
+	delay := s.jitter(max)
+	s.debugf("Delaying publish by %v", delay)
+
+	ctx := s.sendContext()
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,146 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// jitters returns n jitters up to max from a plugin on the host
+func jitters(hostname string, max time.Duration, n int) []time.Duration {
+	s := newTestPlugin()
+	s.hostname = hostname
+	s.setRandom()
+
+	delays := make([]time.Duration, n)
+	for i := range delays {
+		delays[i] = s.jitter(max)
+	}
+	return delays
+}
+
+func TestJitterBounds(t *testing.T) {
+	tests := []struct {
+		hostname string
+		max      time.Duration
+	}{
+		{"web-01", time.Second},
+		{"web-02", 250 * time.Millisecond},
+		{"db-01", time.Nanosecond},
+		{"db-02", 0},
+	}
+	for _, tt := range tests {
+		for _, delay := range jitters(tt.hostname, tt.max, 1000) {
+			if delay < 0 || (tt.max > 0 && delay >= tt.max) || (tt.max <= 0 && delay != 0) {
+				t.Errorf("%s: jitter %v outside [0, %v)", tt.hostname, delay, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestJitterSeededByHost(t *testing.T) {
+	first := jitters("web-01", time.Minute, 10)
+	if again := jitters("web-01", time.Minute, 10); !reflect.DeepEqual(first, again) {
+		t.Errorf("The same host jittered %v, then %v", first, again)
+	}
+	if other := jitters("web-02", time.Minute, 10); reflect.DeepEqual(first, other) {
+		t.Errorf("Two hosts jittered the same %v", first)
+	}
+}
+
+func TestPublishJitter(t *testing.T) {
+	s := newTestPlugin()
+	cfg := plugin.Config{
+		"token":          "ABCD1234",
+		"output":         outputStdout,
+		"hostname":       "web-01",
+		"publish_jitter": int64(50),
+	}
+	s.stdout = ioutil.Discard
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Publish took %v with a 50ms jitter", elapsed)
+		}
+	}
+	if s.publishJitter != 50*time.Millisecond {
+		t.Errorf("publish_jitter = %v, want 50ms", s.publishJitter)
+	}
+}
+
+func TestPublishJitterWithinTimeout(t *testing.T) {
+	s := newTestPlugin()
+	cfg := plugin.Config{
+		"token":          "ABCD1234",
+		"output":         outputStdout,
+		"hostname":       "web-01",
+		"publish_jitter": int64(60000),
+		"timeout":        "50ms",
+	}
+	s.stdout = ioutil.Discard
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg)
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Publish took %v with a 50ms timeout", elapsed)
+		}
+	}
+}
+
+func TestPublishJitterClosed(t *testing.T) {
+	s := newTestPlugin()
+	cfg := plugin.Config{
+		"token":          "ABCD1234",
+		"output":         outputStdout,
+		"hostname":       "web-01",
+		"publish_jitter": int64(60000),
+	}
+	s.stdout = ioutil.Discard
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	s.cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Publish cut short by closing returned no error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish kept waiting out its jitter once closed")
+	}
+}
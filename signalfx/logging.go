@@ -0,0 +1,147 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Log levels
+const (
+	levelDebug = iota // Verbose output for troubleshooting
+	levelInfo         // Normal operational messages
+	levelWarn         // Recoverable problems
+	levelError        // Failures
+)
+
+// Log level names used in the log_level setting
+var logLevels = map[string]int{
+	"debug": levelDebug,
+	"info":  levelInfo,
+	"warn":  levelWarn,
+	"error": levelError,
+}
+
+// setLogLevel will set the log level from the config file, defaulting
+// to info
+func (s *SignalFx) setLogLevel(cfg plugin.Config) {
+	s.logLevel = levelInfo
+
+	name, err := s.getString(cfg, "log_level")
+	if err != nil {
+		// No log_level defined, moving on
+		return
+	}
+
+	level, ok := logLevels[strings.ToLower(name)]
+	if !ok {
+		s.logf("Unknown log level %q, using info", name)
+		return
+	}
+	s.logLevel = level
+}
+
+// debugf logs the message when the log level is debug
+func (s *SignalFx) debugf(format string, v ...interface{}) {
+	if s.logLevel <= levelDebug {
+		s.printf("DEBUG "+format, v...)
+	}
+}
+
+// warnf logs the message when the log level is warn or lower
+func (s *SignalFx) warnf(format string, v ...interface{}) {
+	if s.logLevel <= levelWarn {
+		s.printf("WARN "+format, v...)
+	}
+}
+
+// errorf logs the message to the error log, if any, or the plugin's log
+func (s *SignalFx) errorf(format string, v ...interface{}) {
+	if s.errorLogger != nil {
+		s.errorLogger.Printf("ERROR "+format, v...)
+		return
+	}
+	s.printf("ERROR "+format, v...)
+}
+
+// setErrorLog will send error messages to the file named by the
+// error_log_path setting instead of the plugin's log
+func (s *SignalFx) setErrorLog(cfg plugin.Config) {
+	fileName, err := s.getString(cfg, "error_log_path")
+	if err != nil || s.errorLogger != nil {
+		// No error_log_path defined, or an error logger was set, moving on
+		return
+	}
+
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		s.logf("Unable to open error_log_path: %v", err)
+		return
+	}
+	s.errorLogger = log.New(f, "", log.LstdFlags)
+
+	s.logf("Sending errors to %s", fileName)
+}
+
+// SetLogger sends the plugin's log output to the logger instead of the
+// standard logger, and keeps debug_file from redirecting it, e.g. for tests
+func (s *SignalFx) SetLogger(logger *log.Logger) {
+	s.logger = logger
+	s.loggerSet = true
+}
+
+// SetErrorLogger sends the plugin's error messages to the logger instead
+// of its log, and keeps error_log_path from redirecting them, e.g. for tests
+func (s *SignalFx) SetErrorLogger(logger *log.Logger) {
+	s.errorLogger = logger
+}
+
+// logf logs the message when the log level is info or lower
+func (s *SignalFx) logf(format string, v ...interface{}) {
+	if s.logLevel <= levelInfo {
+		s.printf(format, v...)
+	}
+}
+
+// logln logs the values when the log level is info or lower
+func (s *SignalFx) logln(v ...interface{}) {
+	if s.logLevel > levelInfo {
+		return
+	}
+	if s.logger != nil {
+		s.logger.Println(v...)
+		return
+	}
+	log.Println(v...)
+}
+
+// printf logs the message to the plugin's logger, if any, or the standard
+// logger, whatever the log level
+func (s *SignalFx) printf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
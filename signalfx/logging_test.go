@@ -0,0 +1,252 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestErrorLog(t *testing.T) {
+	tests := []struct {
+		level string
+		debug bool
+	}{
+		{"debug", true},
+		{"info", false},
+		{"error", false},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "signalfx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// Existing content is appended to
+		path := filepath.Join(dir, "errors.log")
+		if err := ioutil.WriteFile(path, []byte("previous\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		server := statusServer(http.StatusUnauthorized)
+		defer server.Close()
+
+		var out bytes.Buffer
+		s := New()
+		s.SetLogger(log.New(&out, "", 0))
+		if s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, testConfig(server.URL, plugin.Config{
+			"log_level":      tt.level,
+			"error_log_path": path,
+		})) == nil {
+			t.Errorf("%s: Publish to a rejecting server succeeded", tt.level)
+		}
+
+		errors, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(errors), "previous\n") {
+			t.Errorf("%s: error log was not appended to: %q", tt.level, errors)
+		}
+		if !strings.Contains(string(errors), "ERROR Failed to send datapoints") {
+			t.Errorf("%s: error log is missing the failed send: %q", tt.level, errors)
+		}
+		if strings.Contains(string(errors), "DEBUG") {
+			t.Errorf("%s: error log has debug lines: %q", tt.level, errors)
+		}
+
+		if strings.Contains(out.String(), "ERROR") {
+			t.Errorf("%s: log has error lines: %q", tt.level, out.String())
+		}
+		if strings.Contains(out.String(), "DEBUG") != tt.debug {
+			t.Errorf("%s: log debug lines = %v, want %v", tt.level, !tt.debug, tt.debug)
+		}
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	tests := []struct {
+		level  int
+		logged []string
+	}{
+		{levelDebug, []string{"DEBUG debug", "info", "line", "WARN warn", "ERROR error"}},
+		{levelInfo, []string{"info", "line", "WARN warn", "ERROR error"}},
+		{levelWarn, []string{"WARN warn", "ERROR error"}},
+		{levelError, []string{"ERROR error"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := newTestPlugin()
+		s.SetLogger(log.New(&out, "", 0))
+		s.logLevel = tt.level
+
+		s.debugf("debug")
+		s.logf("info")
+		s.logln("line")
+		s.warnf("warn")
+		s.errorf("error")
+
+		got := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if !reflect.DeepEqual(got, tt.logged) {
+			t.Errorf("level %d: logged %q, want %q", tt.level, got, tt.logged)
+		}
+	}
+}
+
+func TestErrorLogMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signalfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "errors.log")
+	s := newTestPlugin()
+	s.setErrorLog(plugin.Config{"error_log_path": path})
+	if s.errorLogger == nil {
+		t.Fatal("error log was not opened")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode&^0600 != 0 {
+		t.Errorf("error log mode = %v, want it readable only by its owner", mode)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		inject  bool
+		created bool
+	}{
+		{"injected logger", "log_file", true, false},
+		{"injected logger, older setting", "debug_file", true, false},
+		{"log_file", "log_file", false, true},
+		{"debug_file", "debug_file", false, true},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "signalfx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "signalfx.log")
+
+		// Nothing is written to the standard logger either way
+		var global bytes.Buffer
+		log.SetOutput(&global)
+
+		is := newIngestServer()
+		var out bytes.Buffer
+		s := New()
+		if tt.inject {
+			s.SetLogger(log.New(&out, "", 0))
+		}
+		err = s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, testConfig(is.URL, plugin.Config{
+			tt.setting: path,
+		}))
+		is.Close()
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+
+		if _, err := os.Stat(path); (err == nil) != tt.created {
+			t.Errorf("%s: log file created = %v, want %v", tt.name, err == nil, tt.created)
+		}
+		if (out.Len() > 0) != tt.inject {
+			t.Errorf("%s: injected logger written = %v, want %v", tt.name, out.Len() > 0, tt.inject)
+		}
+		if global.Len() > 0 {
+			t.Errorf("%s: the standard logger was written to: %q", tt.name, global.String())
+		}
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signalfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		opened   bool
+	}{
+		{"set", plugin.Config{"log_file": filepath.Join(dir, "set.log")}, true},
+		{"empty", plugin.Config{"log_file": ""}, false},
+		{"absent", nil, false},
+		{"unwritable", plugin.Config{"log_file": filepath.Join(dir, "missing", "signalfx.log")}, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := New()
+		cfg := testConfig(is.URL, tt.settings)
+
+		// The file is opened once, and a failure to open it does not stop
+		// the publish
+		var loggers []*log.Logger
+		for i := 0; i < 2; i++ {
+			if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+			loggers = append(loggers, s.logger)
+		}
+		is.Close()
+
+		if (loggers[0] != nil) != tt.opened {
+			t.Errorf("%s: log file opened = %v, want %v", tt.name, loggers[0] != nil, tt.opened)
+		}
+		if loggers[0] != loggers[1] {
+			t.Errorf("%s: the log file was reopened", tt.name)
+		}
+		if _, ok := is.received()["snap.intel.cpu.idle"]; !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+		}
+	}
+
+	// Only the file that was set was created
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "set.log" || files[0].Mode().Perm() != 0600 {
+		t.Errorf("Created %v, want only set.log readable by its owner", files)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Constants
+const (
+	defaultMetadataEndpoint = "https://api.signalfx.com" // SignalFx API
+	metadataTimeout         = 10 * time.Second           // Wait for a metadata update
+	metadataQueueSize       = 1000                       // Updates waiting to be pushed
+)
+
+// metricMetadata - The body of a SignalFx metric metadata update
+type metricMetadata struct {
+	Description      string            `json:"description,omitempty"`
+	CustomProperties map[string]string `json:"customProperties,omitempty"`
+}
+
+// metadataUpdate - A metric metadata update waiting to be pushed
+type metadataUpdate struct {
+	name     string
+	token    string
+	metadata metricMetadata
+}
+
+// setSyncMetricMetadata will push the description and unit of each metric
+// name to the SignalFx metric metadata API the first time the name is seen
+// if the sync_metric_metadata config setting is present in the task file
+func (s *SignalFx) setSyncMetricMetadata(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "sync_metric_metadata")
+	if err != nil || !enabled || s.dryRun {
+		return
+	}
+
+	s.metadataEndpoint = defaultMetadataEndpoint
+	if endpoint, err := s.getString(cfg, "metadata_endpoint"); err == nil && endpoint != "" {
+		s.metadataEndpoint = endpoint
+	}
+	s.metadataSynced = make(map[string]struct{})
+
+	// One worker pushes the updates, until the plugin is closed
+	if s.metadataQueue == nil {
+		s.metadataClient = &http.Client{Timeout: metadataTimeout}
+		s.metadataQueue = make(chan metadataUpdate, metadataQueueSize)
+
+		s.mu.Lock()
+		s.metadataDone = make(chan struct{})
+		s.mu.Unlock()
+		go s.pushMetricMetadata(s.metadataQueue, s.metadataDone)
+	}
+
+	s.logf("Syncing metric metadata to %s", s.metadataEndpoint)
+}
+
+// syncMetricMetadata queues the metric's description and unit to be pushed
+// under the current metric name, once per name; names without either are
+// only remembered. A name whose update does not fit in the queue is tried
+// again the next time it is seen.
+func (s *SignalFx) syncMetricMetadata(m plugin.Metric) {
+	if s.metadataSynced == nil {
+		return
+	}
+
+	s.mu.Lock()
+	_, synced := s.metadataSynced[s.namespace]
+	if !synced {
+		// Forget one name rather than grow without bound, so that only it
+		// is pushed again
+		if len(s.metadataSynced) >= maxTrackedSeries {
+			for name := range s.metadataSynced {
+				delete(s.metadataSynced, name)
+				break
+			}
+		}
+		s.metadataSynced[s.namespace] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	if synced || (m.Description == "" && m.Unit == "") {
+		return
+	}
+
+	metadata := metricMetadata{Description: m.Description}
+	if m.Unit != "" {
+		metadata.CustomProperties = map[string]string{"unit": m.Unit}
+	}
+
+	atomic.AddInt64(&s.metadataPending, 1)
+	select {
+	case s.metadataQueue <- metadataUpdate{name: s.namespace, token: s.token, metadata: metadata}:
+	default:
+		atomic.AddInt64(&s.metadataPending, -1)
+		s.debugf("Metadata queue full, syncing %s later", s.namespace)
+		s.mu.Lock()
+		delete(s.metadataSynced, s.namespace)
+		s.mu.Unlock()
+	}
+}
+
+// pushMetricMetadata pushes the queued metadata updates one at a time until
+// the plugin is closed
+func (s *SignalFx) pushMetricMetadata(queue <-chan metadataUpdate, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case u := <-queue:
+			if err := s.putMetricMetadata(u.name, u.token, u.metadata); err != nil {
+				s.warnf("Failed to sync metadata of %s: %v", u.name, err)
+			} else {
+				s.debugf("Synced metadata of %s", u.name)
+			}
+			atomic.AddInt64(&s.metadataPending, -1)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// putMetricMetadata updates the metadata of the metric name
+func (s *SignalFx) putMetricMetadata(name, token string, metadata metricMetadata) error {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	path := (&url.URL{Path: name}).EscapedPath()
+	req, err := http.NewRequest("PUT", s.metadataEndpoint+"/v2/metric/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-SF-Token", token)
+
+	resp, err := s.metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
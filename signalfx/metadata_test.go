@@ -0,0 +1,257 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// metadataAPI - A metric metadata API recording the updates by metric name
+type metadataAPI struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	updates map[string][]metricMetadata
+	tokens  map[string]string
+}
+
+// newMetadataAPI starts a metric metadata API
+func newMetadataAPI() *metadataAPI {
+	ms := &metadataAPI{
+		updates: make(map[string][]metricMetadata),
+		tokens:  make(map[string]string),
+	}
+	ms.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var metadata metricMetadata
+		if r.Method != "PUT" || json.NewDecoder(r.Body).Decode(&metadata) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		name := r.URL.Path[len("/v2/metric/"):]
+		ms.mu.Lock()
+		ms.updates[name] = append(ms.updates[name], metadata)
+		ms.tokens[name] = r.Header.Get("X-SF-Token")
+		ms.mu.Unlock()
+	}))
+	return ms
+}
+
+// synced waits briefly for n updates, and returns the updates made
+func (ms *metadataAPI) synced(n int) map[string][]metricMetadata {
+	deadline := time.Now().Add(time.Second)
+	for {
+		ms.mu.Lock()
+		count := 0
+		for _, updates := range ms.updates {
+			count += len(updates)
+		}
+		ms.mu.Unlock()
+		if count >= n || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+
+	// Leave time for unwanted updates to arrive
+	time.Sleep(50 * time.Millisecond)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	updates := make(map[string][]metricMetadata)
+	for name, u := range ms.updates {
+		updates[name] = u
+	}
+	return updates
+}
+
+// describedMetric returns a metric with a description and unit
+func describedMetric(data interface{}, description, unit string, ns ...string) plugin.Metric {
+	m := newMetric(data, ns...)
+	m.Description = description
+	m.Unit = unit
+	return m
+}
+
+func TestSyncMetricMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		updates  map[string][]metricMetadata
+	}{
+		{"once per new name", plugin.Config{"sync_metric_metadata": true}, map[string][]metricMetadata{
+			"snap.intel.cpu.idle": {{Description: "Idle time", CustomProperties: map[string]string{"unit": "ms"}}},
+			"snap.intel.cpu.user": {{Description: "User time"}},
+			"snap.intel.mem.free": {{CustomProperties: map[string]string{"unit": "B"}}},
+		}},
+		{"disabled", plugin.Config{"sync_metric_metadata": false}, map[string][]metricMetadata{}},
+		{"absent", nil, map[string][]metricMetadata{}},
+		{"dry run", plugin.Config{"sync_metric_metadata": true, "dry_run": true}, map[string][]metricMetadata{}},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		ms := newMetadataAPI()
+
+		settings := plugin.Config{"metadata_endpoint": ms.URL}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		s := newTestPlugin()
+		cfg := testConfig(is.URL, settings)
+
+		cycles := [][]plugin.Metric{
+			{
+				describedMetric(int64(1), "Idle time", "ms", "intel", "cpu", "idle"),
+				describedMetric(int64(2), "User time", "", "intel", "cpu", "user"),
+				newMetric(int64(3), "intel", "cpu", "system"),
+			},
+			{
+				describedMetric(int64(4), "Idle time", "ms", "intel", "cpu", "idle"),
+				describedMetric(int64(5), "User time", "", "intel", "cpu", "user"),
+				describedMetric(int64(6), "", "B", "intel", "mem", "free"),
+			},
+		}
+		for _, mts := range cycles {
+			if err := s.Publish(mts, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+		}
+
+		updates := ms.synced(len(tt.updates))
+		if !reflect.DeepEqual(updates, tt.updates) {
+			t.Errorf("%s: synced %v, want %v", tt.name, updates, tt.updates)
+		}
+		ms.mu.Lock()
+		for name, token := range ms.tokens {
+			if token != "ABCD1234" {
+				t.Errorf("%s: %s synced with token %q", tt.name, name, token)
+			}
+		}
+		ms.mu.Unlock()
+
+		s.Close()
+		ms.Close()
+		is.Close()
+	}
+}
+
+func TestSyncMetricMetadataAsync(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+	slow := slowServer()
+	defer slow.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{
+		"sync_metric_metadata":   true,
+		"metadata_endpoint":      slow.URL,
+		"shutdown_flush_timeout": int64(100),
+	})
+
+	start := time.Now()
+	if err := s.Publish([]plugin.Metric{describedMetric(int64(1), "Idle time", "ms", "intel", "cpu", "idle")}, cfg); err != nil {
+		t.Errorf("Publish returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Publish took %v, held up by the metadata sync", elapsed)
+	}
+	if _, ok := is.received()["snap.intel.cpu.idle"]; !ok {
+		t.Error("The datapoint was not sent")
+	}
+
+	// Closing abandons the sync in progress, ending the worker
+	start = time.Now()
+	s.Close()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Close took %v, held up by the metadata sync", elapsed)
+	}
+	select {
+	case <-s.metadataDone:
+	default:
+		t.Error("The metadata worker outlived Close")
+	}
+}
+
+func TestSyncMetricMetadataClose(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+	ms := newMetadataAPI()
+	defer ms.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{
+		"sync_metric_metadata": true,
+		"metadata_endpoint":    ms.URL,
+	})
+
+	var mts []plugin.Metric
+	for i := 0; i < 20; i++ {
+		mts = append(mts, describedMetric(int64(i), "Idle time", "ms", "intel", "cpu", strconv.Itoa(i), "idle"))
+	}
+	if err := s.Publish(mts, cfg); err != nil {
+		t.Errorf("Publish returned %v", err)
+	}
+
+	// Close waits for the queued updates
+	s.Close()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if len(ms.updates) != len(mts) {
+		t.Errorf("Synced %d metric names before closing, want %d", len(ms.updates), len(mts))
+	}
+}
+
+func TestSyncMetricMetadataBounded(t *testing.T) {
+	ms := newMetadataAPI()
+	defer ms.Close()
+
+	s := newTestPlugin()
+	s.setSyncMetricMetadata(plugin.Config{"sync_metric_metadata": true, "metadata_endpoint": ms.URL})
+	defer s.Close()
+	for i := 0; i < maxTrackedSeries; i++ {
+		s.metadataSynced[strconv.Itoa(i)] = struct{}{}
+	}
+
+	s.namespace = "snap.intel.cpu.idle"
+	s.syncMetricMetadata(describedMetric(int64(1), "Idle time", "ms", "intel", "cpu", "idle"))
+	if len(s.metadataSynced) != maxTrackedSeries {
+		t.Errorf("Tracking %d metric names, want %d", len(s.metadataSynced), maxTrackedSeries)
+	}
+	if _, ok := s.metadataSynced[s.namespace]; !ok {
+		t.Error("The new metric name was not tracked")
+	}
+
+	// Only the new name is pushed, not the ones tracked before
+	if updates := ms.synced(1); len(updates) != 1 {
+		t.Errorf("Synced %d metric names, want the new one only", len(updates))
+	}
+}
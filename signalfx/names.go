@@ -0,0 +1,118 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// setStripPrefix will set the leading namespace elements removed from
+// metric names, e.g. "/intel/procfs"
+func (s *SignalFx) setStripPrefix(cfg plugin.Config) {
+	prefix, err := s.getString(cfg, "strip_prefix")
+	if err != nil {
+		// No strip_prefix defined, moving on
+		return
+	}
+
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		s.stripPrefix = strings.Split(prefix, "/")
+		s.logf("Stripping /%s from metric names", prefix)
+	}
+}
+
+// stripNamespace removes the strip_prefix elements from the namespace when
+// it starts with them
+func (s *SignalFx) stripNamespace(ns []string) []string {
+	if len(s.stripPrefix) == 0 || len(ns) < len(s.stripPrefix) {
+		return ns
+	}
+
+	for i, element := range s.stripPrefix {
+		if ns[i] != element {
+			return ns
+		}
+	}
+	return ns[len(s.stripPrefix):]
+}
+
+// emptyNamespace reports whether the namespace has no non-blank elements
+func emptyNamespace(ns []string) bool {
+	return strings.TrimSpace(strings.Join(ns, "")) == ""
+}
+
+// setMetricPrefix will set the prefix of metric names from the
+// metric_prefix setting, keeping snap when it is absent; an empty prefix
+// leaves names unprefixed
+func (s *SignalFx) setMetricPrefix(cfg plugin.Config) {
+	s.metricPrefix = defaultMetricPrefix
+
+	prefix, err := s.getString(cfg, "metric_prefix")
+	if err != nil {
+		// No metric_prefix defined, moving on
+		return
+	}
+	s.metricPrefix = strings.Trim(prefix, ".")
+
+	s.logf("Prefixing metric names with %q", s.metricPrefix)
+}
+
+// prefixName returns the metric name under the metric prefix, if any
+func (s *SignalFx) prefixName(name string) string {
+	if s.metricPrefix == "" {
+		return name
+	}
+	return s.metricPrefix + "." + name
+}
+
+// emptyName reports whether the metric name is empty, only separators, or
+// only the prefix once every naming setting has been applied
+func (s *SignalFx) emptyName(name string) bool {
+	name = strings.Trim(name, ". \t")
+	return name == "" || name == s.metricPrefix
+}
+
+// setLowercaseNames will lowercase metric names if the lowercase_names
+// config setting is present in the task file, leaving the namespaces in
+// lowercase_exceptions untouched
+func (s *SignalFx) setLowercaseNames(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "lowercase_names")
+	if err != nil || !enabled {
+		return
+	}
+	s.lowercaseNames = true
+
+	if value, err := s.getString(cfg, "lowercase_exceptions"); err == nil {
+		s.lowercaseExcept = splitList(value)
+	}
+
+	s.logf("Lowercasing metric names, except %v", s.lowercaseExcept)
+}
+
+// lowercaseName returns the metric name lowercased, unless lowercasing is
+// disabled or the namespace is an exception
+func (s *SignalFx) lowercaseName(namespace, name string) string {
+	if !s.lowercaseNames || hasAnyPrefix(namespace, s.lowercaseExcept) {
+		return name
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,202 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestStripPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		ns     []string
+		metric string
+	}{
+		{"matching", "/intel/procfs", []string{"intel", "procfs", "iface", "eth0", "bytes"}, "snap.iface.eth0.bytes"},
+		{"trailing slash", "intel/procfs/", []string{"intel", "procfs", "iface", "eth0", "bytes"}, "snap.iface.eth0.bytes"},
+		{"non-matching", "/intel/procfs", []string{"intel", "psutil", "load", "load1"}, "snap.intel.psutil.load.load1"},
+		{"partial element", "/intel/proc", []string{"intel", "procfs", "load", "load1"}, "snap.intel.procfs.load.load1"},
+		{"longer than the namespace", "/intel/procfs/load", []string{"intel", "procfs"}, "snap.intel.procfs"},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setStripPrefix(plugin.Config{"strip_prefix": tt.prefix})
+		if got := "snap." + strings.Join(s.stripNamespace(tt.ns), "."); got != tt.metric {
+			t.Errorf("%s: named %s, want %s", tt.name, got, tt.metric)
+		}
+	}
+}
+
+func TestSkipEmptyNamespace(t *testing.T) {
+	tests := []struct {
+		name string
+		ns   []string
+	}{
+		{"no elements", nil},
+		{"empty element", []string{""}},
+		{"blank elements", []string{" ", "\t"}},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{
+			newMetric(int64(1), tt.ns...),
+			newMetric(int64(2), "intel", "cpu", "idle"),
+		}, testConfig(is.URL, plugin.Config{"self_metrics": true}))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		dps := is.received()
+		if _, ok := dps["snap.intel.cpu.idle"]; !ok {
+			t.Errorf("%s: the named metric was not sent", tt.name)
+		}
+		for metric := range dps {
+			if strings.Trim(metric, ". \t") == "snap" {
+				t.Errorf("%s: sent the degenerate metric %q", tt.name, metric)
+			}
+		}
+		if dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filterEmptyNamespace); !ok || dp.Value != int64(1) {
+			t.Errorf("%s: filtered %v (%v), want 1 empty namespace", tt.name, dp.Value, ok)
+		}
+	}
+}
+
+func TestLowercaseNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		ns       []string
+		metric   string
+	}{
+		{"lowercased", plugin.Config{"lowercase_names": true},
+			[]string{"intel", "MSSQL", "BufferCacheHitRatio"}, "snap.intel.mssql.buffercachehitratio"},
+		{"exception", plugin.Config{"lowercase_names": true, "lowercase_exceptions": "/intel/MSSQL"},
+			[]string{"intel", "MSSQL", "BufferCacheHitRatio"}, "snap.intel.MSSQL.BufferCacheHitRatio"},
+		{"outside the exceptions", plugin.Config{"lowercase_names": true, "lowercase_exceptions": "/intel/Oracle"},
+			[]string{"intel", "MSSQL", "BufferCacheHitRatio"}, "snap.intel.mssql.buffercachehitratio"},
+		{"exceptions alone", plugin.Config{"lowercase_exceptions": "/intel/Oracle"},
+			[]string{"intel", "MSSQL", "BufferCacheHitRatio"}, "snap.intel.MSSQL.BufferCacheHitRatio"},
+		{"disabled", nil,
+			[]string{"intel", "MSSQL", "BufferCacheHitRatio"}, "snap.intel.MSSQL.BufferCacheHitRatio"},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(int64(1), tt.ns...))
+		if _, ok := dps[tt.metric]; !ok {
+			t.Errorf("%s: %s was not sent, got %v", tt.name, tt.metric, dps)
+		}
+	}
+}
+
+func TestEmptyName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		empty  bool
+	}{
+		{"snap", "", true},
+		{"snap", ".", true},
+		{"snap", "..", true},
+		{"snap", " . ", true},
+		{"snap", "snap", true},
+		{"snap", "snap.", true},
+		{"snap", "snap.intel", false},
+		{"", "", true},
+		{"", "intel", false},
+		{"snap", "intel", false},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.metricPrefix = tt.prefix
+		if got := s.emptyName(tt.name); got != tt.empty {
+			t.Errorf("emptyName(%q) under prefix %q = %v, want %v", tt.name, tt.prefix, got, tt.empty)
+		}
+	}
+}
+
+func TestSkipEmptyName(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		skipped  string
+	}{
+		{"template of separators", plugin.Config{"name_template": "{ns[5]}.{ns[6]}"}, "2"},
+		{"template of the prefix", plugin.Config{"name_template": "{prefix}.{ns[5]}"}, "2"},
+		{"unprefixed template of separators", plugin.Config{"name_template": "{ns[5]}. .", "metric_prefix": ""}, "2"},
+		{"named", plugin.Config{"name_template": "{prefix}.{ns[0]}.{ns[1]}"}, "0"},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"self_metrics": true}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{
+			newMetric(int64(1), "intel", "disk", "reads"),
+			newMetric(int64(2), "intel", "cpu", "idle"),
+		}, testConfig(is.URL, settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		for metric := range is.received() {
+			if s.emptyName(metric) {
+				t.Errorf("%s: sent the empty name %q", tt.name, metric)
+			}
+		}
+		if dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filterEmptyName); !ok || fmt.Sprint(dp.Value) != tt.skipped {
+			t.Errorf("%s: filtered %v (%v), want %s", tt.name, dp.Value, ok, tt.skipped)
+		}
+	}
+}
+
+func TestMetricPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		metric   string
+	}{
+		{"default", nil, "snap.intel.cpu.idle"},
+		{"custom", plugin.Config{"metric_prefix": "prod"}, "prod.intel.cpu.idle"},
+		{"dotted", plugin.Config{"metric_prefix": "team.prod"}, "team.prod.intel.cpu.idle"},
+		{"trailing separator", plugin.Config{"metric_prefix": "prod."}, "prod.intel.cpu.idle"},
+		{"empty", plugin.Config{"metric_prefix": ""}, "intel.cpu.idle"},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))
+		if _, ok := dps[tt.metric]; len(dps) != 1 || !ok {
+			t.Errorf("%s: sent %v, want %s", tt.name, dps, tt.metric)
+		}
+	}
+}
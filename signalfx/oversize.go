@@ -0,0 +1,57 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// setMaxDatapointBytes will set the approximate size above which a single
+// datapoint is dropped, so that it cannot get the whole request rejected
+func (s *SignalFx) setMaxDatapointBytes(cfg plugin.Config) {
+	max, err := s.getInt(cfg, "max_datapoint_bytes")
+	if err != nil || max <= 0 {
+		// No max_datapoint_bytes defined, moving on
+		return
+	}
+	s.maxDatapoint = max
+
+	s.logf("Dropping datapoints larger than %d bytes", max)
+}
+
+// dropOversized returns the datapoints no larger than max_datapoint_bytes,
+// warning about each one dropped
+func (s *SignalFx) dropOversized(dps []*datapoint.Datapoint) []*datapoint.Datapoint {
+	if s.maxDatapoint <= 0 {
+		return dps
+	}
+
+	kept := dps[:0]
+	for _, dp := range dps {
+		if size := datapointSize(dp); size > s.maxDatapoint {
+			s.warnf("Dropping %s, %d bytes exceeds max_datapoint_bytes %d", dp.Metric, size, s.maxDatapoint)
+			s.countFiltered(filterOversize)
+			continue
+		}
+		kept = append(kept, dp)
+	}
+	return kept
+}
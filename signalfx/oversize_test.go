@@ -0,0 +1,94 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestMaxDatapointBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     map[string]bool
+	}{
+		{"oversized dropped", plugin.Config{"max_datapoint_bytes": int64(512)}, map[string]bool{
+			"snap.intel.cpu.idle":  true,
+			"snap.intel.disk.tags": false,
+			"snap.intel.cpu.user":  true,
+		}},
+		{"large limit", plugin.Config{"max_datapoint_bytes": int64(1 << 20)}, map[string]bool{
+			"snap.intel.cpu.idle":  true,
+			"snap.intel.disk.tags": true,
+			"snap.intel.cpu.user":  true,
+		}},
+		{"absent", nil, map[string]bool{
+			"snap.intel.cpu.idle":  true,
+			"snap.intel.disk.tags": true,
+			"snap.intel.cpu.user":  true,
+		}},
+	}
+	for _, tt := range tests {
+		// Take a long label dimension from the namespace
+		settings := plugin.Config{"alias_rules": "/intel/disk/{label}/tags=intel.disk.tags"}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		settings["self_metrics"] = true
+
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{
+			newMetric(int64(1), "intel", "cpu", "idle"),
+			newMetric(int64(2), "intel", "disk", strings.Repeat("x", 800), "tags"),
+			newMetric(int64(3), "intel", "cpu", "user"),
+		}, testConfig(is.URL, settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		dps := is.received()
+		var dropped int64
+		for metric, sent := range tt.sent {
+			if _, ok := dps[metric]; ok != sent {
+				t.Errorf("%s: %s sent = %v, want %v", tt.name, metric, ok, sent)
+			}
+			if !sent {
+				dropped++
+			}
+		}
+
+		var filtered interface{}
+		if dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filterOversize); ok {
+			filtered = dp.Value
+		}
+		if dropped > 0 && filtered != dropped {
+			t.Errorf("%s: filtered %v oversized, want %d", tt.name, filtered, dropped)
+		}
+	}
+}
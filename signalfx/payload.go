@@ -0,0 +1,167 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/errors"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// Payload formats
+const (
+	formatProtobuf = "protobuf" // The sink's own encoding
+	formatJSON     = "json"     // SignalFx JSON ingest format
+)
+
+// Keys of the JSON ingest body by metric type
+var jsonTypeKeys = map[datapoint.MetricType]string{
+	datapoint.Gauge:   "gauge",
+	datapoint.Count:   "counter",
+	datapoint.Counter: "cumulative_counter",
+}
+
+// jsonDatapoint - A datapoint in the SignalFx JSON ingest format
+type jsonDatapoint struct {
+	Metric     string                 `json:"metric"`
+	Dimensions map[string]string      `json:"dimensions,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Value      interface{}            `json:"value"`
+	Timestamp  int64                  `json:"timestamp,omitempty"`
+}
+
+// setPayloadFormat will send JSON instead of protobuf if the
+// payload_format config setting is json
+func (s *SignalFx) setPayloadFormat(cfg plugin.Config) {
+	format, err := s.getString(cfg, "payload_format")
+	if err != nil {
+		// No payload_format defined, moving on
+		return
+	}
+
+	switch format {
+	case formatJSON:
+		s.jsonPayload = true
+		s.logln("Sending JSON payloads")
+	case formatProtobuf:
+	default:
+		s.logf("Unknown payload_format %q, using %s", format, formatProtobuf)
+	}
+}
+
+// postJSON sends the datapoints to the sink's endpoint in the SignalFx JSON
+// ingest format, gzipped like protobuf payloads are: always, or once they
+// reach compression_min_bytes if set. Errors are returned like the sink
+// does.
+func (s *SignalFx) postJSON(ctx context.Context, sink *sfxclient.HTTPDatapointSink, dps []*datapoint.Datapoint) error {
+	body := make(map[string][]jsonDatapoint)
+	for _, dp := range dps {
+		key, ok := jsonTypeKeys[dp.MetricType]
+		if !ok {
+			key = jsonTypeKeys[datapoint.Gauge]
+		}
+
+		jdp := jsonDatapoint{
+			Metric:     dp.Metric,
+			Dimensions: dp.Dimensions,
+			Properties: dp.GetProperties(),
+			Value:      jsonValue(dp.Value),
+		}
+		if !dp.Timestamp.IsZero() {
+			jdp.Timestamp = dp.Timestamp.UnixNano() / int64(1e6)
+		}
+		body[key] = append(body[key], jdp)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	compress := s.compressMin <= 0 || int64(len(payload)) >= s.compressMin
+	if compress {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", sink.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("X-SF-Token", sink.AuthToken)
+	if sink.UserAgent != "" {
+		req.Header.Set("User-Agent", sink.UserAgent)
+	}
+
+	resp, err := sink.Client.Do(req)
+	if err != nil {
+		return errors.Annotatef(err, "failed to send/receive http request")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return sfxclient.SFXAPIError{StatusCode: resp.StatusCode, ResponseBody: string(respBody)}
+	}
+
+	var result string
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return errors.Annotatef(err, unmarshalBodyPrefix+"%s", respBody)
+	}
+	if result != "OK" {
+		return errors.Errorf("invalid response body %s", result)
+	}
+	return nil
+}
+
+// jsonValue returns the datapoint value as a JSON number, or a string for
+// other values
+func jsonValue(v datapoint.Value) interface{} {
+	switch x := v.(type) {
+	case datapoint.IntValue:
+		return x.Int()
+	case datapoint.FloatValue:
+		return x.Float()
+	}
+	return v.String()
+}
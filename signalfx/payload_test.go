@@ -0,0 +1,124 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestPayloadFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      interface{}
+		contentType string
+	}{
+		{"json", formatJSON, "application/json"},
+		{"protobuf", formatProtobuf, "application/x-protobuf"},
+		{"unknown", "xml", "application/x-protobuf"},
+		{"absent", nil, "application/x-protobuf"},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var contentTypes []string
+		is := newIngestServer()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+			mu.Unlock()
+			is.handle(w, r)
+		}))
+
+		cfg := testConfig(ts.URL, plugin.Config{"delta_counters": "/intel/net"})
+		delete(cfg, "payload_format")
+		if tt.format != nil {
+			cfg["payload_format"] = tt.format
+		}
+
+		// The delta counter is sent from the second publish on
+		s := newTestPlugin()
+		var err error
+		for _, bytes := range []int64{2, 5} {
+			mts := []plugin.Metric{newMetric(1.5, "intel", "cpu", "idle"), newMetric(bytes, "intel", "net", "bytes")}
+			if err = s.Publish(mts, cfg); err != nil {
+				break
+			}
+		}
+		ts.Close()
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		for _, contentType := range contentTypes {
+			if contentType != tt.contentType {
+				t.Errorf("%s: sent content types %q, want %s", tt.name, contentTypes, tt.contentType)
+				break
+			}
+		}
+		if tt.contentType != "application/json" {
+			continue
+		}
+
+		// The mock server decodes JSON bodies by type key
+		received := is.received()
+		for _, want := range []struct{ metric, typ, value string }{
+			{"snap.intel.cpu.idle", "gauge", "1.5"},
+			{"snap.intel.net.bytes", "counter", "3"},
+		} {
+			dp, ok := received[want.metric]
+			if !ok {
+				t.Errorf("%s: %s was not in the JSON body", tt.name, want.metric)
+				continue
+			}
+			if dp.Type != want.typ || fmt.Sprint(dp.Value) != want.value {
+				t.Errorf("%s: %s sent as %s %v, want %s %s", tt.name, want.metric, dp.Type, dp.Value, want.typ, want.value)
+			}
+		}
+	}
+}
+
+func TestJSONProperties(t *testing.T) {
+	m := newMetric(int64(1), "intel", "disk", "reads")
+	m.Tags = map[string]string{"serial": "WD-1234"}
+
+	dp, ok := publish(t, plugin.Config{
+		"payload_format":           formatJSON,
+		"dimensions_to_properties": "serial",
+	}, m)["snap.intel.disk.reads"]
+	if !ok {
+		t.Fatal("nothing was sent")
+	}
+	if v := dp.Properties["serial"]; v != "WD-1234" {
+		t.Errorf("sent property serial %v, want WD-1234", v)
+	}
+	if _, ok := dp.Dimensions["serial"]; ok {
+		t.Error("serial was also sent as a dimension")
+	}
+}
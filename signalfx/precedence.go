@@ -0,0 +1,100 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Sources of dimensions, named in the dimension_precedence setting
+const (
+	sourceInferred = "inferred" // Derived from the namespace or data
+	sourceTag      = "tag"      // Snap metric tags
+	sourceStatic   = "static"   // Set by the task config
+	sourceHost     = "host"     // The host dimension
+)
+
+// Sources of dimensions, from the highest precedence to the lowest
+var defaultPrecedence = []string{sourceInferred, sourceTag, sourceStatic, sourceHost}
+
+// setDimensionPrecedence will set which source wins when several set the
+// same dimension key from the dimension_precedence setting, a list of the
+// sources from the highest precedence to the lowest. Sources left out rank
+// below those listed, in their default order.
+func (s *SignalFx) setDimensionPrecedence(cfg plugin.Config) {
+	s.precedence = make(map[string]int)
+	for i, source := range defaultPrecedence {
+		s.precedence[source] = i
+	}
+
+	value, err := s.getString(cfg, "dimension_precedence")
+	if err != nil {
+		// No dimension_precedence defined, moving on
+		return
+	}
+
+	var order []string
+	for _, source := range splitList(value) {
+		if _, ok := s.precedence[source]; !ok {
+			s.logf("Ignoring unknown dimension_precedence source %q", source)
+			continue
+		}
+		order = append(order, source)
+	}
+	for _, source := range defaultPrecedence {
+		if !contains(order, source) {
+			order = append(order, source)
+		}
+	}
+
+	for i, source := range order {
+		s.precedence[source] = i
+	}
+	s.logf("Using dimension precedence %v", order)
+}
+
+// resetDimensions starts the current metric's dimensions with the host and
+// static dimensions
+func (s *SignalFx) resetDimensions() {
+	s.dimensions = make(map[string]string)
+	s.dimSources = make(map[string]string)
+
+	s.addDimensions(sourceHost, map[string]string{"host": s.hostname})
+	static := s.baseDimensions()
+	delete(static, "host")
+	s.addDimensions(sourceStatic, static)
+}
+
+// addDimensions adds dimensions from the source to the current metric,
+// keeping any set by a source of higher precedence. Sources of equal
+// precedence overwrite each other.
+func (s *SignalFx) addDimensions(source string, dims map[string]string) {
+	for k, v := range dims {
+		if owner, ok := s.dimSources[k]; ok && owner != source {
+			if s.precedence[owner] < s.precedence[source] {
+				s.debugf("Keeping %s %s=%s over %s value %s", owner, k, s.dimensions[k], source, v)
+				continue
+			}
+			s.debugf("Using %s %s=%s over %s value %s", source, k, v, owner, s.dimensions[k])
+		}
+		s.dimensions[k] = v
+		s.dimSources[k] = source
+	}
+}
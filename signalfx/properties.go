@@ -0,0 +1,126 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"sort"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// setDimensionsToProperties will set the dimension keys sent as datapoint
+// properties instead
+func (s *SignalFx) setDimensionsToProperties(cfg plugin.Config) {
+	value, err := s.getString(cfg, "dimensions_to_properties")
+	if err != nil {
+		// No dimensions_to_properties defined, moving on
+		return
+	}
+	s.propertyKeys = splitList(value)
+
+	s.logf("Sending dimensions %v as properties", s.propertyKeys)
+}
+
+// setMaxProperties will cap the number of properties sent per datapoint if
+// the max_properties config setting is present in the task file
+func (s *SignalFx) setMaxProperties(cfg plugin.Config) {
+	n, err := s.getInt(cfg, "max_properties")
+	if err != nil || n <= 0 {
+		return
+	}
+	s.maxProps = int(n)
+
+	s.logf("Sending at most %d properties per datapoint", n)
+}
+
+// extractProperties removes the dimensions configured to be properties
+// from the current dimensions and returns them
+func (s *SignalFx) extractProperties() map[string]interface{} {
+	var props map[string]interface{}
+	for _, key := range s.propertyKeys {
+		if v, ok := s.dimensions[key]; ok {
+			delete(s.dimensions, key)
+			if props == nil {
+				props = make(map[string]interface{})
+			}
+			props[key] = v
+		}
+	}
+	return props
+}
+
+// setProperties sets the properties of the current metric on the
+// datapoint
+func (s *SignalFx) setProperties(dp *datapoint.Datapoint) {
+	for k, v := range s.properties {
+		value, ok := propertyValue(v)
+		if !ok {
+			s.warnf("Skipping property %s of %s, unsupported type %T", k, dp.Metric, v)
+			continue
+		}
+		dp.SetProperty(k, value)
+	}
+}
+
+// limitProperties drops the properties of the datapoint beyond
+// max_properties, with a warning. The counter_reset property is kept
+// first, then the keys in the order listed in dimensions_to_properties.
+func (s *SignalFx) limitProperties(dp *datapoint.Datapoint) {
+	props := dp.GetProperties()
+	if s.maxProps <= 0 || len(props) <= s.maxProps {
+		return
+	}
+
+	// Rank the keys, any unlisted ones last in name order
+	ranked := make([]string, 0, len(props))
+	seen := make(map[string]bool, len(props))
+	for _, key := range append([]string{counterResetProperty}, s.propertyKeys...) {
+		if _, ok := props[key]; ok && !seen[key] {
+			ranked = append(ranked, key)
+			seen[key] = true
+		}
+	}
+	var rest []string
+	for key := range props {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	ranked = append(ranked, rest...)
+
+	for _, key := range ranked[s.maxProps:] {
+		s.warnf("Dropping property %s of %s, over max_properties %d", key, dp.Metric, s.maxProps)
+		dp.RemoveProperty(key)
+	}
+}
+
+// propertyValue coerces the value to a type the sink can send as a
+// property: a string, bool, int64, or float64
+func propertyValue(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case string, bool, int64, float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	return toInt64(v)
+}
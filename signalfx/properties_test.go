@@ -0,0 +1,218 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestExtractProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		dimensions map[string]string
+		properties map[string]interface{}
+	}{
+		{"moved", []string{"serial"},
+			map[string]string{"disk": "sda", "model": "WD10"},
+			map[string]interface{}{"serial": "WD-1234"}},
+		{"several", []string{"serial", "model"},
+			map[string]string{"disk": "sda"},
+			map[string]interface{}{"serial": "WD-1234", "model": "WD10"}},
+		{"absent key", []string{"firmware"},
+			map[string]string{"disk": "sda", "serial": "WD-1234", "model": "WD10"},
+			nil},
+		{"disabled", nil,
+			map[string]string{"disk": "sda", "serial": "WD-1234", "model": "WD10"},
+			nil},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.propertyKeys = tt.keys
+		s.dimensions = map[string]string{"disk": "sda", "serial": "WD-1234", "model": "WD10"}
+
+		props := s.extractProperties()
+		if !reflect.DeepEqual(props, tt.properties) {
+			t.Errorf("%s: properties = %v, want %v", tt.name, props, tt.properties)
+		}
+		if !reflect.DeepEqual(s.dimensions, tt.dimensions) {
+			t.Errorf("%s: dimensions = %v, want %v", tt.name, s.dimensions, tt.dimensions)
+		}
+	}
+}
+
+func TestLimitProperties(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int
+		props []string
+		want  []string
+	}{
+		{"unlimited", 0, []string{"counter_reset", "a", "b", "c"}, []string{"a", "b", "c", "counter_reset"}},
+		{"under the cap", 4, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"listed first kept", 2, []string{"c", "b", "a"}, []string{"a", "b"}},
+		{"counter_reset kept first", 2, []string{"counter_reset", "a", "b", "c"}, []string{"a", "counter_reset"}},
+		{"counter_reset alone", 1, []string{"a", "counter_reset"}, []string{"counter_reset"}},
+		{"unlisted last", 3, []string{"z", "y", "b", "a"}, []string{"a", "b", "y"}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.maxProps = tt.max
+		s.propertyKeys = []string{"a", "b", "c"}
+
+		dp := datapoint.New("cpu.idle", nil, datapoint.NewIntValue(1), datapoint.Gauge, time.Time{})
+		for _, key := range tt.props {
+			dp.SetProperty(key, true)
+		}
+		s.limitProperties(dp)
+
+		var got []string
+		for key := range dp.GetProperties() {
+			got = append(got, key)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sent properties %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractPropertiesUncapped(t *testing.T) {
+	s := newTestPlugin()
+	s.maxProps = 1
+	s.propertyKeys = []string{"a", "b"}
+	s.dimensions = map[string]string{"a": "1", "b": "2", "host": "h"}
+
+	props := s.extractProperties()
+	if len(props) != 2 {
+		t.Errorf("extracted %v, want both properties left for limitProperties", props)
+	}
+	if !reflect.DeepEqual(s.dimensions, map[string]string{"host": "h"}) {
+		t.Errorf("dimensions = %v, want only host", s.dimensions)
+	}
+}
+
+func TestDimensionsToProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     []string // Dimensions still sent
+		moved    []string // Dimensions sent as properties instead
+	}{
+		{"moved", plugin.Config{"dimensions_to_properties": "serial"},
+			[]string{"disk", "model"}, []string{"serial"}},
+		{"several", plugin.Config{"dimensions_to_properties": "serial,model"},
+			[]string{"disk"}, []string{"serial", "model"}},
+		{"absent key", plugin.Config{"dimensions_to_properties": "firmware"},
+			[]string{"disk", "serial", "model"}, nil},
+		{"disabled", nil,
+			[]string{"disk", "serial", "model"}, nil},
+	}
+	for _, tt := range tests {
+		// Take the dimensions from the namespace
+		settings := plugin.Config{"alias_rules": "/intel/disk/{disk}/{serial}/{model}/reads=intel.disk.reads"}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		dps := publish(t, settings, newMetric(int64(1), "intel", "disk", "sda", "WD-1234", "WD10", "reads"))
+		dp, ok := dps["snap.intel.disk.reads"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+
+		for _, key := range tt.sent {
+			if _, ok := dp.Dimensions[key]; !ok {
+				t.Errorf("%s: dimension %s was not sent", tt.name, key)
+			}
+		}
+		for _, key := range tt.moved {
+			if _, ok := dp.Dimensions[key]; ok {
+				t.Errorf("%s: %s was sent as a dimension, want a property", tt.name, key)
+			}
+		}
+	}
+}
+
+func TestPropertyValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+		ok    bool
+	}{
+		{"string", "WD-1234", "WD-1234", true},
+		{"bool", true, true, true},
+		{"int64", int64(-7), int64(-7), true},
+		{"float64", 2.5, 2.5, true},
+		{"int", 7, int64(7), true},
+		{"int32", int32(7), int64(7), true},
+		{"uint32", uint32(7), int64(7), true},
+		{"uint64", uint64(7), int64(7), true},
+		{"float32", float32(0.5), 0.5, true},
+		{"func", func() {}, nil, false},
+		{"slice", []string{"a"}, nil, false},
+		{"nil", nil, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := propertyValue(tt.value)
+		if ok != tt.ok {
+			t.Errorf("%s: propertyValue(%v) ok = %v, want %v", tt.name, tt.value, ok, tt.ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("%s: propertyValue(%v) = %T %v, want %T %v", tt.name, tt.value, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestSetProperties(t *testing.T) {
+	s := newTestPlugin()
+	s.properties = map[string]interface{}{
+		"serial":   "WD-1234",
+		"ssd":      false,
+		"sectors":  uint64(512),
+		"ratio":    float32(0.25),
+		"callback": func() {},
+	}
+
+	dp := datapoint.New("snap.intel.disk.reads", nil, datapoint.NewIntValue(1), datapoint.Gauge, time.Time{})
+	s.setProperties(dp)
+
+	want := map[string]interface{}{
+		"serial":  "WD-1234",
+		"ssd":     false,
+		"sectors": int64(512),
+		"ratio":   0.25,
+	}
+	if got := dp.GetProperties(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties %v, want %v", got, want)
+	}
+}
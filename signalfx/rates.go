@@ -0,0 +1,133 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// rateTotal - The running total reconstructed from a rate
+type rateTotal struct {
+	total float64   // Sum of the reconstructed increments
+	last  time.Time // When the rate was last seen
+}
+
+// setRateToCounter will set the namespaces whose per-second rates are sent
+// as cumulative counters
+func (s *SignalFx) setRateToCounter(cfg plugin.Config) {
+	value, err := s.getString(cfg, "rate_to_counter")
+	if err != nil {
+		// No rate_to_counter defined, moving on
+		return
+	}
+	s.rateNamespaces = splitList(value)
+	s.rateTotals = make(map[string]*rateTotal)
+
+	s.logf("Sending rates of %v as cumulative counters", s.rateNamespaces)
+}
+
+// sendRateCounter adds the rate times the seconds since the series was last
+// seen to its running total and sends the total as a cumulative counter.
+// The first rate of a series only starts the clock.
+func (s *SignalFx) sendRateCounter(rate float64, at time.Time) {
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	r, ok := s.rateTotals[key]
+	if !ok {
+		// Forget everything rather than grow without bound
+		if len(s.rateTotals) >= maxTrackedSeries {
+			s.logf("Tracking over %d series, resetting rate totals", maxTrackedSeries)
+			s.rateTotals = make(map[string]*rateTotal)
+		}
+		s.rateTotals[key] = &rateTotal{last: at}
+		s.mu.Unlock()
+
+		s.logf("Recorded first rate for %s", s.namespace)
+		return
+	}
+
+	if elapsed := at.Sub(r.last).Seconds(); elapsed > 0 {
+		r.total += rate * elapsed
+		r.last = at
+	}
+	total := r.total
+	s.mu.Unlock()
+
+	s.debugf("Sending [rate] %s -> %v", s.namespace, total)
+	s.send(sfxclient.CumulativeF(s.namespace, s.dimensions, total))
+}
+
+// observation - A counter value and when it was seen
+type observation struct {
+	value float64   // Counter value
+	at    time.Time // When it was seen
+}
+
+// setEmitRate will set the counter namespaces that are also sent as a
+// per-second <name>.rate gauge
+func (s *SignalFx) setEmitRate(cfg plugin.Config) {
+	value, err := s.getString(cfg, "emit_rate")
+	if err != nil {
+		// No emit_rate defined, moving on
+		return
+	}
+	s.emitRateNamespaces = splitList(value)
+	s.observations = make(map[string]*observation)
+
+	s.logf("Sending the rates of %v as gauges", s.emitRateNamespaces)
+}
+
+// sendRateGauge sends the per-second rate of the counter since its previous
+// observation as the <name>.rate gauge. The first observation, and the
+// first after the counter is reset, only record the value.
+func (s *SignalFx) sendRateGauge(value float64, at time.Time) {
+	key := seriesKey(s.namespace, s.dimensions)
+
+	s.mu.Lock()
+	previous, ok := s.observations[key]
+	if !ok && len(s.observations) >= maxTrackedSeries {
+		// Forget everything rather than grow without bound
+		s.logf("Tracking over %d series, resetting emitted rates", maxTrackedSeries)
+		s.observations = make(map[string]*observation)
+	}
+	s.observations[key] = &observation{value: value, at: at}
+	s.mu.Unlock()
+
+	if !ok {
+		s.logf("Recorded first value for the rate of %s", s.namespace)
+		return
+	}
+	if value < previous.value {
+		s.logf("Counter %s was reset, skipping its rate", s.namespace)
+		return
+	}
+	elapsed := at.Sub(previous.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	rate := (value - previous.value) / elapsed
+	s.debugf("Sending [rate] %s.rate -> %v", s.namespace, rate)
+	s.send(sfxclient.GaugeF(s.namespace+".rate", s.dimensions, rate))
+}
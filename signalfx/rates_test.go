@@ -0,0 +1,205 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// rateMetric returns a network rate metric seen at the offset in seconds
+func rateMetric(rate float64, offset int) plugin.Metric {
+	m := newMetric(rate, "intel", "net", "bytes_per_sec")
+	m.Timestamp = time.Unix(1500000000+int64(offset), 0)
+	return m
+}
+
+func TestRateToCounter(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		rates    []float64
+		offsets  []int
+		totals   []string // Sent per publish, "" when nothing was sent
+	}{
+		{"steady rate", plugin.Config{"rate_to_counter": "/intel/net"},
+			[]float64{100, 100, 100}, []int{0, 10, 20}, []string{"", "1000", "2000"}},
+		{"changing rate", plugin.Config{"rate_to_counter": "/intel/net"},
+			[]float64{100, 50, 0.5}, []int{0, 10, 14}, []string{"", "500", "502"}},
+		{"same timestamp", plugin.Config{"rate_to_counter": "/intel/net"},
+			[]float64{100, 100, 100}, []int{0, 10, 10}, []string{"", "1000", "1000"}},
+		{"other namespace", plugin.Config{"rate_to_counter": "/intel/cpu"},
+			[]float64{100, 100}, []int{0, 10}, []string{"100", "100"}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for i, rate := range tt.rates {
+			cycles = append(cycles, []plugin.Metric{rateMetric(rate, tt.offsets[i])})
+		}
+
+		var totals []string
+		for _, dps := range publishEach(t, tt.settings, cycles...) {
+			var total string
+			if dp, ok := dps["snap.intel.net.bytes_per_sec"]; ok {
+				total = fmt.Sprint(dp.Value)
+			}
+			totals = append(totals, total)
+		}
+		if !reflect.DeepEqual(totals, tt.totals) {
+			t.Errorf("%s: sent %q, want %q", tt.name, totals, tt.totals)
+		}
+	}
+}
+
+func TestRateToCounterType(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{"rate_to_counter": "/intel/net"})
+	for _, offset := range []int{0, 10} {
+		if err := s.Publish([]plugin.Metric{rateMetric(2.5, offset)}, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+	}
+
+	dp, ok := is.received()["snap.intel.net.bytes_per_sec"]
+	if !ok {
+		t.Fatal("No counter was sent")
+	}
+	if dp.Type != "cumulative_counter" || dp.Value != float64(25) {
+		t.Errorf("Sent %s %v, want cumulative_counter 25", dp.Type, dp.Value)
+	}
+}
+
+// counterMetric returns a network counter metric seen at the offset in seconds
+func counterMetric(value int64, offset int) plugin.Metric {
+	m := newMetric(value, "intel", "net", "bytes")
+	m.Timestamp = time.Unix(1500000000+int64(offset), 0)
+	return m
+}
+
+func TestEmitRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		values   []int64
+		offsets  []int
+		rates    []string // Sent per publish, "" when nothing was sent
+	}{
+		{"two observations", plugin.Config{"emit_rate": "/intel/net"},
+			[]int64{100, 200}, []int{0, 10}, []string{"", "10"}},
+		{"changing rate", plugin.Config{"emit_rate": "/intel/net"},
+			[]int64{100, 200, 210}, []int{0, 10, 12}, []string{"", "10", "5"}},
+		{"reset", plugin.Config{"emit_rate": "/intel/net"},
+			[]int64{100, 200, 50, 80}, []int{0, 10, 20, 30}, []string{"", "10", "", "3"}},
+		{"same timestamp", plugin.Config{"emit_rate": "/intel/net"},
+			[]int64{100, 200}, []int{0, 0}, []string{"", ""}},
+		{"other namespace", plugin.Config{"emit_rate": "/intel/cpu"},
+			[]int64{100, 200}, []int{0, 10}, []string{"", ""}},
+		{"absent", nil,
+			[]int64{100, 200}, []int{0, 10}, []string{"", ""}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for i, value := range tt.values {
+			cycles = append(cycles, []plugin.Metric{counterMetric(value, tt.offsets[i])})
+		}
+
+		var rates []string
+		for i, dps := range publishEach(t, tt.settings, cycles...) {
+			// The counter itself is always sent
+			if _, ok := dps["snap.intel.net.bytes"]; !ok {
+				t.Errorf("%s: publish %d did not send the counter", tt.name, i)
+			}
+
+			var rate string
+			if dp, ok := dps["snap.intel.net.bytes.rate"]; ok {
+				rate = fmt.Sprint(dp.Value)
+			}
+			rates = append(rates, rate)
+		}
+		if !reflect.DeepEqual(rates, tt.rates) {
+			t.Errorf("%s: sent %q, want %q", tt.name, rates, tt.rates)
+		}
+	}
+}
+
+func TestEmitRateSeries(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	// Take the interface from the namespace
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{
+		"emit_rate":   "/intel/net",
+		"alias_rules": "/intel/net/{interface}/bytes=intel.net.bytes",
+	})
+	for i, values := range [][]int64{{100, 1000}, {150, 1100}} {
+		var mts []plugin.Metric
+		for j, value := range values {
+			m := newMetric(value, "intel", "net", []string{"eth0", "eth1"}[j], "bytes")
+			m.Timestamp = time.Unix(1500000000+int64(10*i), 0)
+			mts = append(mts, m)
+		}
+
+		is.mu.Lock()
+		is.datapoints = nil
+		is.mu.Unlock()
+		if err := s.Publish(mts, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+	}
+
+	for iface, want := range map[string]string{"eth0": "5", "eth1": "10"} {
+		dp, ok := is.find("snap.intel.net.bytes.rate", "interface", iface)
+		if !ok || fmt.Sprint(dp.Value) != want {
+			t.Errorf("%s: rate %v (%v), want %s", iface, dp.Value, ok, want)
+		}
+	}
+}
+
+func TestEmitRateType(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{"emit_rate": "/intel/net"})
+	for i, value := range []int64{100, 200} {
+		if err := s.Publish([]plugin.Metric{counterMetric(value, 10*i)}, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+	}
+
+	dp, ok := is.received()["snap.intel.net.bytes.rate"]
+	if !ok {
+		t.Fatal("No rate was sent")
+	}
+	if dp.Type != "gauge" || fmt.Sprint(dp.Value) != "10" {
+		t.Errorf("Sent %s %v, want gauge 10", dp.Type, dp.Value)
+	}
+}
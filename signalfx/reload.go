@@ -0,0 +1,82 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// reloadConfig will recreate the targets using a token or endpoint setting
+// that changed since the plugin was initialized, noting what changed for
+// the config_reloaded self metric
+func (s *SignalFx) reloadConfig(cfg plugin.Config) {
+	if s.dryRun {
+		return
+	}
+
+	var changed []string
+
+	token, err := getString(cfg, "token")
+	if err == nil && token != s.token {
+		changed = append(changed, "token")
+		s.token = token
+	}
+
+	// An invalid endpoint was already reported when initializing
+	endpoint, _ := getString(cfg, "endpoint")
+	if validateEndpoint(endpoint) != nil {
+		endpoint = ""
+	}
+	if endpoint != s.endpoint {
+		changed = append(changed, "endpoint")
+		s.endpoint = endpoint
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	s.reloaded = strings.Join(changed, ",")
+
+	// The default target uses both settings, the others the token setting
+	// unless they have their own
+	for i, t := range s.targets {
+		if i > 0 && t.token != "" {
+			continue
+		}
+
+		endpoint := t.sink.Endpoint
+		if i == 0 {
+			endpoint = s.endpoint
+		}
+
+		s.logf("Config changed (%s), recreating the %s target", s.reloaded, t.name)
+		t.sink = s.newSink(endpoint, s.tokenFor(t))
+		if t.fallback != nil {
+			t.fallback = s.newSink(t.fallback.Endpoint, s.tokenFor(t))
+		}
+	}
+
+	// Targets from metric config are recreated as they are next used
+	s.mu.Lock()
+	s.overrides = nil
+	s.mu.Unlock()
+}
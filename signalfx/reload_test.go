@@ -0,0 +1,86 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestReloadToken(t *testing.T) {
+	primary := newIngestServer()
+	defer primary.Close()
+	shared := newIngestServer()
+	defer shared.Close()
+	own := newIngestServer()
+	defer own.Close()
+	override := newIngestServer()
+	defer override.Close()
+
+	s := newTestPlugin()
+	metric := newMetric(1, "intel", "cpu", "idle")
+	overridden := newMetric(2, "intel", "cpu", "user")
+	overridden.Config = plugin.Config{"endpoint": override.URL}
+
+	for _, token := range []string{"OLD", "NEW"} {
+		cfg := testConfig(primary.URL, plugin.Config{
+			"token":        token,
+			"targets":      "shared=" + shared.URL + ",own=" + own.URL + ";OWN",
+			"self_metrics": true,
+		})
+		if err := s.Publish([]plugin.Metric{metric, overridden}, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		server *ingestServer
+		metric string
+		token  string
+	}{
+		{"default", primary, "snap.intel.cpu.idle", "NEW"},
+		{"shared token", shared, "snap.intel.cpu.idle", "NEW"},
+		{"own token", own, "snap.intel.cpu.idle", "OWN"},
+		{"metric config", override, "snap.intel.cpu.user", "NEW"},
+	}
+	for _, tt := range tests {
+		dp, ok := tt.server.received()[tt.metric]
+		if !ok {
+			t.Errorf("%s: %s was not sent", tt.name, tt.metric)
+			continue
+		}
+		if dp.Token != tt.token {
+			t.Errorf("%s: sent with token %s, want %s", tt.name, dp.Token, tt.token)
+		}
+	}
+
+	dp, ok := primary.received()[selfMetricPrefix+"config_reloaded"]
+	if !ok {
+		t.Fatal("config_reloaded was not sent")
+	}
+	if dp.Dimensions["changed"] != "token" {
+		t.Errorf("changed = %q, want token", dp.Dimensions["changed"])
+	}
+}
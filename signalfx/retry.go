@@ -0,0 +1,108 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// Constants
+const (
+	defaultRetryBackoff = 100 * time.Millisecond // Delay before the first retry
+	maxRetryBackoff     = 30 * time.Second       // Longest delay between retries
+)
+
+// setRetries will set the number of retries from the max_retries setting,
+// the delay before the first retry from retry_backoff in milliseconds,
+// and whether the delays are jittered from retry_jitter
+func (s *SignalFx) setRetries(cfg plugin.Config) {
+	n, err := s.getInt(cfg, "max_retries")
+	if err != nil || n <= 0 {
+		// No max_retries defined, moving on
+		return
+	}
+	s.maxRetries = int(n)
+
+	s.retryBackoff = defaultRetryBackoff
+	if ms, err := s.getInt(cfg, "retry_backoff"); err == nil && ms > 0 {
+		s.retryBackoff = time.Duration(ms) * time.Millisecond
+	}
+
+	if jitter, err := s.getBool(cfg, "retry_jitter"); err == nil {
+		s.retryJitter = jitter
+	}
+
+	s.logf("Retrying failed sends %d times after %v", s.maxRetries, s.retryBackoff)
+}
+
+// addDatapoints sends the datapoints to the sink, retrying failures that
+// may be temporary up to max_retries times, but never past the context's
+// deadline, and returning the last error
+func (s *SignalFx) addDatapoints(ctx context.Context, sink *sfxclient.HTTPDatapointSink, dps []*datapoint.Datapoint) error {
+	for attempt := 0; ; attempt++ {
+		var err error
+		if s.jsonPayload {
+			err = s.postJSON(ctx, sink, dps)
+		} else {
+			err = s.addProtobuf(ctx, sink, dps)
+		}
+		if err == nil || attempt >= s.maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		// Give up rather than retry past the publish's deadline
+		delay := s.retryDelay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && s.now().Add(delay).After(deadline) {
+			s.logf("Not retrying %s past the deadline: %v", sink.Endpoint, err)
+			return err
+		}
+		s.logf("Retrying %s in %v: %v", sink.Endpoint, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// retryDelay returns the delay before the retry following the attempt,
+// doubling from retry_backoff up to a limit. With retry_jitter, the delay
+// is instead a random duration up to that, so hosts retry at different
+// times.
+func (s *SignalFx) retryDelay(attempt int) time.Duration {
+	delay := s.retryBackoff
+	for i := 0; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+
+	if s.retryJitter {
+		return s.jitter(delay + 1)
+	}
+	return delay
+}
@@ -0,0 +1,144 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// retryPlugin returns a plugin configured with the retry settings
+func retryPlugin(settings plugin.Config) *SignalFx {
+	s := newTestPlugin()
+	s.hostname = "web-01"
+	s.setRandom()
+	s.setRetries(settings)
+	return s
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := retryPlugin(plugin.Config{"max_retries": int64(20), "retry_backoff": int64(100)})
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{8, 25600 * time.Millisecond},
+		{9, maxRetryBackoff},
+		{20, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := s.retryDelay(tt.attempt); got != tt.delay {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempt, got, tt.delay)
+		}
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	plain := retryPlugin(plugin.Config{"max_retries": int64(20), "retry_backoff": int64(100)})
+	jittered := retryPlugin(plugin.Config{
+		"max_retries":   int64(20),
+		"retry_backoff": int64(100),
+		"retry_jitter":  true,
+	})
+
+	for attempt := 0; attempt < 12; attempt++ {
+		computed := plain.retryDelay(attempt)
+
+		varied := false
+		for i := 0; i < 100; i++ {
+			delay := jittered.retryDelay(attempt)
+			if delay < 0 || delay > computed {
+				t.Fatalf("Attempt %d: jittered delay %v outside [0, %v]", attempt, delay, computed)
+			}
+			if delay != computed {
+				varied = true
+			}
+		}
+		if !varied {
+			t.Errorf("Attempt %d: the delay was never jittered", attempt)
+		}
+	}
+}
+
+func TestSetRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		retries  int
+		backoff  time.Duration
+	}{
+		{"both", plugin.Config{"max_retries": int64(3), "retry_backoff": int64(250)}, 3, 250 * time.Millisecond},
+		{"default backoff", plugin.Config{"max_retries": int64(3)}, 3, defaultRetryBackoff},
+		{"invalid backoff", plugin.Config{"max_retries": int64(3), "retry_backoff": int64(-5)}, 3, defaultRetryBackoff},
+		{"no retries", plugin.Config{"max_retries": int64(0), "retry_backoff": int64(250)}, 0, 0},
+		{"absent", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		s := retryPlugin(tt.settings)
+		if s.maxRetries != tt.retries || s.retryBackoff != tt.backoff {
+			t.Errorf("%s: %d retries after %v, want %d after %v",
+				tt.name, s.maxRetries, s.retryBackoff, tt.retries, tt.backoff)
+		}
+	}
+}
+
+func TestRetryOnlyTransient(t *testing.T) {
+	tests := []struct {
+		status   int
+		requests int
+	}{
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusInternalServerError, 3},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusUnauthorized, 1},
+		{http.StatusForbidden, 1},
+		{http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		status := tt.status
+		is.status = func(int) int { return status }
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(is.URL, plugin.Config{
+			"payload_format": formatProtobuf,
+			"max_retries":    int64(2),
+			"retry_backoff":  int64(1),
+		}))
+		is.Close()
+
+		if err == nil {
+			t.Errorf("%d: Publish returned no error", tt.status)
+		}
+		if got := is.requestCount(); got != tt.requests {
+			t.Errorf("%d: %d requests, want %d", tt.status, got, tt.requests)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// Prefix of the metrics describing the plugin itself
+const selfMetricPrefix = "snap.signalfx."
+
+// Upper bounds of the latency_bucket dimension, the last bucket being
+// everything beyond
+var latencyBuckets = []struct {
+	below time.Duration
+	name  string
+}{
+	{10 * time.Millisecond, "<10ms"},
+	{100 * time.Millisecond, "<100ms"},
+	{time.Second, "<1s"},
+}
+
+// Self metrics counting the datapoints sent by type
+var typeCountMetrics = []struct {
+	name       string
+	metricType datapoint.MetricType
+}{
+	{"gauges", datapoint.Gauge},
+	{"counters", datapoint.Count},
+	{"cumulatives", datapoint.Counter},
+}
+
+// setSelfMetrics will enable the plugin's own metrics if the self_metrics
+// config setting is present in the task file
+func (s *SignalFx) setSelfMetrics(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "self_metrics")
+	if err != nil || !enabled {
+		return
+	}
+	s.selfMetrics = true
+
+	s.logln("Sending self metrics")
+}
+
+// setLatencyBucket will send the self metrics with the latency_bucket of
+// the previous publish if the latency_bucket config setting is present in
+// the task file
+func (s *SignalFx) setLatencyBucket(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "latency_bucket")
+	if err != nil || !enabled {
+		return
+	}
+	s.latencyBucket = true
+
+	s.logln("Sending self metrics with the publish latency bucket")
+}
+
+// latencyBucketFor returns the latency_bucket the publish latency falls in
+func latencyBucketFor(latency time.Duration) string {
+	for _, bucket := range latencyBuckets {
+		if latency < bucket.below {
+			return bucket.name
+		}
+	}
+	return ">=1s"
+}
+
+// selfDimensions returns the dimensions of the self metrics: the base
+// dimensions and, once there is a previous publish, its latency_bucket
+func (s *SignalFx) selfDimensions() map[string]string {
+	dims := s.baseDimensions()
+	if s.latencyBucket && s.lastLatency > 0 {
+		dims["latency_bucket"] = latencyBucketFor(s.lastLatency)
+	}
+	return dims
+}
+
+// setRuntimeMetrics will enable the plugin's Go runtime metrics if the
+// runtime_metrics config setting is present in the task file
+func (s *SignalFx) setRuntimeMetrics(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "runtime_metrics")
+	if err != nil || !enabled {
+		return
+	}
+	s.runtimeMetrics = true
+
+	s.logln("Sending Go runtime metrics")
+}
+
+// sendRuntimeMetrics will send the plugin's Go runtime metrics, such as
+// goroutines, GC, and heap, with the base dimensions
+func (s *SignalFx) sendRuntimeMetrics() {
+	dps := sfxclient.GoMetricsSource.Datapoints()
+	for _, dp := range dps {
+		dims := s.baseDimensions()
+		for k, v := range dp.Dimensions {
+			dims[k] = v
+		}
+		dp.Dimensions = dims
+	}
+
+	s.send(dps...)
+}
+
+// sendSelfMetrics will send the metrics describing the last publish
+func (s *SignalFx) sendSelfMetrics() {
+	var dps []*datapoint.Datapoint
+
+	// The last error, categorized
+	if s.lastErr != nil {
+		dims := s.selfDimensions()
+		dims["error_category"] = classifyError(s.lastErr)
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"last_error", dims, 1))
+	} else {
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"last_error", s.selfDimensions(), 0))
+	}
+
+	// The metrics received, before any are filtered
+	dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"metrics_received", s.selfDimensions(),
+		int64(s.received)))
+
+	// The datapoints sent by type
+	for _, m := range typeCountMetrics {
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+m.name, s.selfDimensions(),
+			s.typeCounts[m.metricType]))
+	}
+
+	// The metrics or datapoints dropped by each filter
+	for _, f := range filters {
+		dims := s.selfDimensions()
+		dims["filter"] = f
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"filtered", dims, s.filtered[f]))
+	}
+
+	// The build, only once
+	s.buildInfo.Do(func() {
+		dims := s.selfDimensions()
+		dims["version"] = strconv.Itoa(pluginVersion)
+		dims["go_version"] = runtime.Version()
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"build_info", dims, 1))
+	})
+
+	// The config change, once per change
+	if s.reloaded != "" {
+		dims := s.selfDimensions()
+		dims["changed"] = s.reloaded
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"config_reloaded", dims, 1))
+		s.reloaded = ""
+	}
+
+	// The circuit breaker state
+	if s.breaker.threshold > 0 {
+		dps = append(dps, sfxclient.Gauge(selfMetricPrefix+"circuit_state", s.selfDimensions(),
+			int64(s.breaker.currentState())))
+	}
+
+	// The metrics dropped for future timestamps
+	if s.rejectFuture {
+		dps = append(dps, sfxclient.Cumulative(selfMetricPrefix+"future_dropped", s.selfDimensions(),
+			s.futureDropped))
+	}
+
+	// The datapoints dropped over max_inflight_bytes
+	if s.inflight.drop {
+		dps = append(dps, sfxclient.Cumulative(selfMetricPrefix+"inflight_dropped", s.selfDimensions(),
+			atomic.LoadInt64(&s.inflight.dropped)))
+	}
+
+	// The values dropped by transforms producing NaN or Inf
+	if len(s.transforms) > 0 {
+		dps = append(dps, sfxclient.Cumulative(selfMetricPrefix+"transform_dropped", s.selfDimensions(),
+			s.transformNaN))
+	}
+
+	// The dimensions stripped over their cardinality limit
+	if len(s.dimLimits) > 0 {
+		dps = append(dps, sfxclient.Cumulative(selfMetricPrefix+"dimensions_stripped", s.selfDimensions(),
+			s.dimStripped))
+	}
+
+	s.send(dps...)
+}
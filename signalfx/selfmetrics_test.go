@@ -0,0 +1,293 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestLastErrorCategory(t *testing.T) {
+	closed := statusServer(http.StatusOK)
+	closed.Close()
+	slow := slowServer()
+	defer slow.Close()
+	unauthorized := statusServer(http.StatusUnauthorized)
+	defer unauthorized.Close()
+	is := newIngestServer()
+	defer is.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		category string
+	}{
+		{"sent", is.URL, ""},
+		{"connection refused", closed.URL, errorNetwork},
+		{"timeout", slow.URL, errorTimeout},
+		{"unauthorized", unauthorized.URL, errorAuth},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.setSinks(plugin.Config{"endpoint": tt.endpoint})
+		s.targets[0].sink.Client.Timeout = 50 * time.Millisecond
+		s.send(sfxclient.Gauge("snap.intel.cpu.idle", nil, 1))
+		s.flushBatches()
+
+		var got string
+		if s.lastErr != nil {
+			got = classifyError(s.lastErr)
+		}
+		if got != tt.category {
+			t.Errorf("%s: last_error category = %q, want %q", tt.name, got, tt.category)
+		}
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	mts := []plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     []bool
+	}{
+		{"once", plugin.Config{"self_metrics": true}, []bool{true, false, false}},
+		{"without self_metrics", nil, []bool{false, false}},
+	}
+	for _, tt := range tests {
+		var cycles [][]plugin.Metric
+		for range tt.sent {
+			cycles = append(cycles, mts)
+		}
+
+		for i, dps := range publishEach(t, tt.settings, cycles...) {
+			dp, ok := dps[selfMetricPrefix+"build_info"]
+			if ok != tt.sent[i] {
+				t.Errorf("%s: publish %d sent build_info = %v, want %v", tt.name, i, ok, tt.sent[i])
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if dp.Value != int64(1) {
+				t.Errorf("%s: build_info = %v, want 1", tt.name, dp.Value)
+			}
+			if got, want := dp.Dimensions["version"], strconv.Itoa(pluginVersion); got != want {
+				t.Errorf("%s: version = %q, want %q", tt.name, got, want)
+			}
+			if got, want := dp.Dimensions["go_version"], runtime.Version(); got != want {
+				t.Errorf("%s: go_version = %q, want %q", tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestTypeCounts(t *testing.T) {
+	cycle := []plugin.Metric{
+		newMetric(int64(1), "intel", "cpu", "idle"),
+		newMetric(int64(2), "intel", "net", "bytes"),
+		newMetric(int64(3), "intel", "net", "packets"),
+	}
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		counts   map[string]string // Counts sent by the second publish
+	}{
+		{"gauges only", nil,
+			map[string]string{"gauges": "3", "counters": "0", "cumulatives": "0"}},
+		{"deltas", plugin.Config{"delta_counters": "/intel/net"},
+			map[string]string{"gauges": "1", "counters": "2", "cumulatives": "0"}},
+		{"all counters", plugin.Config{"all_counters": true},
+			map[string]string{"gauges": "0", "counters": "0", "cumulatives": "3"}},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"self_metrics": true}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		dps := publishEach(t, settings, cycle, cycle)[1]
+
+		for name, want := range tt.counts {
+			dp, ok := dps[selfMetricPrefix+name]
+			if !ok {
+				t.Errorf("%s: %s was not sent", tt.name, name)
+				continue
+			}
+			if fmt.Sprint(dp.Value) != want {
+				t.Errorf("%s: %s = %v, want %s", tt.name, name, dp.Value, want)
+			}
+		}
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		sent     bool
+	}{
+		{"enabled", plugin.Config{"runtime_metrics": true, "source_type": "snap"}, true},
+		{"disabled", plugin.Config{"runtime_metrics": false}, false},
+		{"absent", nil, false},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings, newMetric(int64(1), "intel", "cpu", "idle"))
+
+		for _, metric := range []string{"Alloc", "NumGC", "num_goroutine"} {
+			dp, ok := dps[metric]
+			if ok != tt.sent {
+				t.Errorf("%s: %s sent = %v, want %v", tt.name, metric, ok, tt.sent)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if dp.Dimensions["host"] == "" || dp.Dimensions["sf_source"] != "snap" {
+				t.Errorf("%s: %s sent without the base dimensions: %v", tt.name, metric, dp.Dimensions)
+			}
+			if dp.Dimensions["instance"] != "global_stats" {
+				t.Errorf("%s: %s sent without its own dimensions: %v", tt.name, metric, dp.Dimensions)
+			}
+		}
+	}
+}
+
+func TestMetricsReceived(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  []plugin.Metric
+		received string
+	}{
+		{"one", []plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, "1"},
+		{"several", []plugin.Metric{
+			newMetric(int64(1), "intel", "cpu", "idle"),
+			newMetric(int64(2), "intel", "cpu", "user"),
+			newMetric(int64(3), "intel", "cpu", "system"),
+		}, "3"},
+		{"filtered counted", []plugin.Metric{
+			newMetric(int64(1), "intel", "cpu", "idle"),
+			newMetric(int64(2)),
+			newMetric(nil, "intel", "cpu", "user"),
+		}, "3"},
+	}
+	for _, tt := range tests {
+		dp, ok := publish(t, plugin.Config{"self_metrics": true}, tt.metrics...)[selfMetricPrefix+"metrics_received"]
+		if !ok {
+			t.Errorf("%s: metrics_received was not sent", tt.name)
+			continue
+		}
+		if fmt.Sprint(dp.Value) != tt.received {
+			t.Errorf("%s: metrics_received = %v, want %s", tt.name, dp.Value, tt.received)
+		}
+	}
+}
+
+func TestLatencyBucketFor(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		bucket  string
+	}{
+		{time.Millisecond, "<10ms"},
+		{9 * time.Millisecond, "<10ms"},
+		{10 * time.Millisecond, "<100ms"},
+		{99 * time.Millisecond, "<100ms"},
+		{250 * time.Millisecond, "<1s"},
+		{time.Second, ">=1s"},
+		{time.Minute, ">=1s"},
+	}
+	for _, tt := range tests {
+		if got := latencyBucketFor(tt.latency); got != tt.bucket {
+			t.Errorf("latencyBucketFor(%v) = %q, want %q", tt.latency, got, tt.bucket)
+		}
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		previous time.Duration // The simulated latency of the previous publish
+		bucket   string        // "" when the dimension is absent
+	}{
+		{"slow previous publish", plugin.Config{"latency_bucket": true}, 250 * time.Millisecond, "<1s"},
+		{"very slow previous publish", plugin.Config{"latency_bucket": true}, 2 * time.Second, ">=1s"},
+		{"first publish", plugin.Config{"latency_bucket": true}, 0, ""},
+		{"disabled", plugin.Config{"latency_bucket": false}, 250 * time.Millisecond, ""},
+		{"absent", nil, 250 * time.Millisecond, ""},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		s.lastLatency = tt.previous
+
+		settings := plugin.Config{"self_metrics": true}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, testConfig(is.URL, settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+		dps := is.received()
+
+		dp, ok := dps[selfMetricPrefix+"metrics_received"]
+		if !ok {
+			t.Errorf("%s: metrics_received was not sent", tt.name)
+			continue
+		}
+		if got := dp.Dimensions["latency_bucket"]; got != tt.bucket {
+			t.Errorf("%s: latency_bucket %q, want %q", tt.name, got, tt.bucket)
+		}
+
+		// Only the plugin's own metrics carry the bucket
+		if dps["snap.intel.cpu.idle"].Dimensions["latency_bucket"] != "" {
+			t.Errorf("%s: the collected metric was sent with a latency_bucket", tt.name)
+		}
+	}
+}
+
+func TestLatencyBucketSelfMetrics(t *testing.T) {
+	// Without self metrics, there is nothing to carry the bucket
+	cycles := publishEach(t, plugin.Config{"latency_bucket": true},
+		[]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")},
+		[]plugin.Metric{newMetric(int64(2), "intel", "cpu", "idle")},
+	)
+	for i, dps := range cycles {
+		for metric, dp := range dps {
+			if _, ok := dp.Dimensions["latency_bucket"]; ok {
+				t.Errorf("Publish %d: %s sent with a latency_bucket", i, metric)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"golang.org/x/net/context"
+)
+
+// Constants
+const (
+	defaultShutdownFlushTimeout = 5 * time.Second       // Wait for sends on Close
+	shutdownPollInterval        = 10 * time.Millisecond // Check for sends this often
+)
+
+// setShutdownFlushTimeout will set how long Close waits for sends from
+// the shutdown_flush_timeout setting, in milliseconds
+func (s *SignalFx) setShutdownFlushTimeout(cfg plugin.Config) {
+	s.flushTimeout = defaultShutdownFlushTimeout
+
+	ms, err := s.getInt(cfg, "shutdown_flush_timeout")
+	if err != nil || ms <= 0 {
+		// No shutdown_flush_timeout defined, moving on
+		return
+	}
+	s.flushTimeout = time.Duration(ms) * time.Millisecond
+}
+
+// Close - Sends any accumulated datapoints, then waits up to the shutdown
+// flush timeout for datapoints and metric metadata still being sent and
+// abandons any left. A publish still running at the timeout is cancelled,
+// and what it would have accumulated is dropped.
+func (s *SignalFx) Close() error {
+	// Wait out a configuration in progress, even if a publish is not
+	s.initMu.Lock()
+	timeout := s.flushTimeout
+	s.initMu.Unlock()
+	if timeout <= 0 {
+		timeout = defaultShutdownFlushTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	// Send what was accumulated once a publish in progress ends, all within
+	// the timeout
+	if s.lockPublish(timeout) {
+		s.flushAccumulated(deadline.Sub(time.Now()))
+		s.publishMu.Unlock()
+	} else {
+		s.warnf("A publish was still running after %v, cancelling it", timeout)
+	}
+
+	pending := atomic.LoadInt64(&s.pending)
+	for s.sending() && time.Now().Before(deadline) {
+		time.Sleep(shutdownPollInterval)
+	}
+
+	// Abandon whatever is left, and let the metadata worker end
+	dropped := atomic.LoadInt64(&s.pending)
+	s.cancel()
+	s.mu.Lock()
+	done := s.metadataDone
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+
+	if s.statsd != nil {
+		s.statsd.Close()
+	}
+
+	s.logf("Closed after flushing %d datapoints, dropping %d", pending-dropped, dropped)
+	return nil
+}
+
+// sending reports whether datapoints or metric metadata are still being
+// sent
+func (s *SignalFx) sending() bool {
+	return atomic.LoadInt64(&s.pending) > 0 || atomic.LoadInt64(&s.metadataPending) > 0
+}
+
+// lockPublish waits up to the timeout for a publish in progress to end,
+// reporting whether publishMu was locked. On a timeout the lock is released
+// as soon as it is taken.
+func (s *SignalFx) lockPublish(timeout time.Duration) bool {
+	locked := make(chan struct{})
+	go func() {
+		s.publishMu.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return true
+	case <-time.After(timeout):
+		go func() {
+			<-locked
+			s.publishMu.Unlock()
+		}()
+		return false
+	}
+}
+
+// SetContext closes the plugin, flushing as Close does, once the context
+// is done, so that an external lifecycle manager can stop it. The watch
+// ends with the plugin if it is closed first.
+func (s *SignalFx) SetContext(ctx context.Context) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Log once closed, when the settings are sure to be complete
+			s.Close()
+			s.logf("Closed as the context is done: %v", ctx.Err())
+		case <-s.ctx.Done():
+		}
+	}()
+}
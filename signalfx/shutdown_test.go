@@ -0,0 +1,212 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"golang.org/x/net/context"
+)
+
+func TestShutdownFlushTimeout(t *testing.T) {
+	fast := newIngestServer()
+	defer fast.Close()
+	slow := slowServer()
+	defer slow.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		timeout  interface{}
+		sent     bool
+	}{
+		{"fast sink flushed", fast.URL, int64(500), true},
+		{"fast sink with the default timeout", fast.URL, nil, true},
+		{"slow sink abandoned", slow.URL, int64(100), false},
+	}
+	for _, tt := range tests {
+		fast.mu.Lock()
+		fast.datapoints = nil
+		fast.mu.Unlock()
+
+		// Hold the datapoints so that Close has something to flush
+		settings := plugin.Config{"accumulate_cycles": int64(2)}
+		if tt.timeout != nil {
+			settings["shutdown_flush_timeout"] = tt.timeout
+		}
+		s := newTestPlugin()
+		if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, testConfig(tt.endpoint, settings)); err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		start := time.Now()
+		s.Close()
+		if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+			t.Errorf("%s: Close took %v", tt.name, elapsed)
+		}
+
+		if tt.endpoint != fast.URL {
+			continue
+		}
+		if _, ok := fast.received()["snap.intel.cpu.idle"]; ok != tt.sent {
+			t.Errorf("%s: datapoint sent = %v, want %v", tt.name, ok, tt.sent)
+		}
+	}
+}
+
+func TestCloseDuringPublish(t *testing.T) {
+	slow := slowServer()
+	defer slow.Close()
+
+	tests := []struct {
+		name    string
+		context bool // Close by cancelling a context given to SetContext
+	}{
+		{"close", false},
+		{"context cancelled", true},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		cfg := testConfig(slow.URL, plugin.Config{"shutdown_flush_timeout": int64(100)})
+		ctx, cancel := context.WithCancel(context.Background())
+		s.SetContext(ctx)
+
+		published := make(chan error, 1)
+		go func() {
+			published <- s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg)
+		}()
+
+		// Let the publish reach the slow sink
+		time.Sleep(200 * time.Millisecond)
+		start := time.Now()
+		if tt.context {
+			cancel()
+			select {
+			case <-s.ctx.Done():
+			case <-time.After(900 * time.Millisecond):
+				t.Errorf("%s: the plugin was not closed", tt.name)
+			}
+		} else {
+			s.Close()
+			cancel()
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%s: Close took %v with a publish running", tt.name, elapsed)
+		}
+
+		// The cancelled publish ends before the sink answers
+		select {
+		case <-published:
+		case <-time.After(500 * time.Millisecond):
+			t.Errorf("%s: the publish was not cancelled", tt.name)
+		}
+	}
+}
+
+func TestShutdownFlushTimeoutSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		timeout time.Duration
+	}{
+		{"set", int64(250), 250 * time.Millisecond},
+		{"zero", int64(0), defaultShutdownFlushTimeout},
+		{"negative", int64(-1), defaultShutdownFlushTimeout},
+		{"absent", nil, defaultShutdownFlushTimeout},
+	}
+	for _, tt := range tests {
+		cfg := plugin.Config{}
+		if tt.value != nil {
+			cfg["shutdown_flush_timeout"] = tt.value
+		}
+		s := newTestPlugin()
+		s.setShutdownFlushTimeout(cfg)
+		if s.flushTimeout != tt.timeout {
+			t.Errorf("%s: flush timeout %v, want %v", tt.name, s.flushTimeout, tt.timeout)
+		}
+	}
+}
+
+// goroutinesAtMost waits briefly for the goroutine count to fall to n
+func goroutinesAtMost(n int) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+	return false
+}
+
+func TestSetContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel bool // Cancel the context, rather than closing the plugin
+		sent   bool
+	}{
+		{"context cancelled", true, true},
+		{"plugin closed first", false, true},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		// Hold the datapoints so that the close has something to flush
+		s := newTestPlugin()
+		cfg := testConfig(is.URL, plugin.Config{"accumulate_cycles": int64(2)})
+		if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg); err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		s.SetContext(ctx)
+
+		if tt.cancel {
+			cancel()
+		} else {
+			s.Close()
+			cancel()
+		}
+
+		// The close flushes before it finishes
+		select {
+		case <-s.ctx.Done():
+		case <-time.After(time.Second):
+			t.Errorf("%s: the plugin was not closed", tt.name)
+		}
+
+		// Close the connections the flush left open, leaving only the watch
+		is.Close()
+		if !goroutinesAtMost(before) {
+			t.Errorf("%s: %d goroutines left running, %d before", tt.name, runtime.NumGoroutine(), before)
+		}
+		if _, ok := is.received()["snap.intel.cpu.idle"]; ok != tt.sent {
+			t.Errorf("%s: held datapoint sent = %v, want %v", tt.name, ok, tt.sent)
+		}
+	}
+}
@@ -20,11 +20,18 @@ package signalfx
 
 // Imports
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"net"
+	"net/http"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 	"github.com/signalfx/golib/datapoint"
@@ -39,72 +46,1099 @@ const (
 	pluginVersion = 1           // plugin version
 )
 
+// Returned by Publish when called without a config
+var errNoConfig = errors.New("no config, not publishing to SignalFx")
+
 // SignalFx object
 type SignalFx struct {
 	initialized bool   // Initialization flag
+	logLevel    int    // Log level
 	token       string // SignalFx API token
+	endpoint    string // SignalFx ingest endpoint
 	hostname    string // Hostname
+	sourceType  string // Source type dimension
+	pid         string // Process id dimension
+	hostID      string // Host id dimension
+	org         string // Org dimension
+	team        string // Team dimension
+	sequence    uint64 // Last debug_sequence number
+	debugSeq    bool   // Number every datapoint
 	namespace   string // Metric namespace
+
+	cloudDims map[string]string // Cloud metadata dimensions
+	hostDims  map[string]string // Dimensions from hostname parts
+
+	staticDims map[string]string // Dimensions from the dimensions setting
+
+	precedence map[string]int    // Rank of each dimension source (0 is highest)
+	dimSources map[string]string // Source of each metric dimension
+
+	dimensions map[string]string      // Metric dimensions
+	timeout    time.Duration          // Metric send timeout
+	timestamp  time.Time              // Metric timestamp override
+	properties map[string]interface{} // Metric properties
+	lastErr    error                  // Last send failure of the publish
+	firstErr   error                  // First send failure of the publish
+	sent       int                    // Datapoints sent this publish
+	failed     int                    // Datapoints that failed to send this publish
+
+	rejectFuture    bool          // Drop metrics timestamped in the future
+	futureTolerance time.Duration // Allowed time past now
+	futureDropped   int64         // Metrics dropped for future timestamps
+
+	selfMetrics bool      // Send the plugin's own metrics
+	reloaded    string    // Config changed since the last publish
+	buildInfo   sync.Once // Sends the build_info metric
+	emitAge     bool      // Send the age of each metric
+
+	typeCounts     map[datapoint.MetricType]int64 // Datapoints sent by type this publish
+	received       int                            // Metrics received this publish
+	filtered       map[string]int64               // Drops by filter this publish
+	runtimeMetrics bool                           // Send the plugin's Go runtime metrics
+
+	latencyBucket bool          // Send self metrics with the latency_bucket
+	lastLatency   time.Duration // How long the previous publish took
+
+	statsd net.Conn // statsd mirror, if any
+
+	metadataEndpoint string              // SignalFx API metadata is pushed to
+	metadataSynced   map[string]struct{} // Metric names whose metadata was pushed
+	metadataClient   *http.Client        // Pushes the metadata
+	metadataQueue    chan metadataUpdate // Metadata waiting to be pushed
+	metadataPending  int64               // Metadata updates queued or being pushed, updated atomically
+	metadataDone     chan struct{}       // Closed once the metadata worker ends
+
+	dryRun bool // Log datapoints instead of sending them
+
+	output       string             // Where datapoints go
+	stdout       io.Writer          // Destination of the stdout output
+	targets      []*target          // Targets datapoints are sent to
+	concurrency  int                // Targets sent to at once
+	routes       []routeRule        // Namespaces sent to specific targets
+	defaultRoute *target            // Target of unrouted namespaces
+	route        []*target          // Metric targets
+	ingestPath   string             // Path of the ingest API version
+	jsonPayload  bool               // Send JSON instead of protobuf
+	compressMin  int64              // Smallest payload compressed in bytes
+	compressMu   sync.Mutex         // Serializes sends setting the compression
+	overrides    map[string]*target // Targets from metric config
+	inflight     inflightLimiter    // Limits in-flight bytes
+	maxDatapoint int64              // Largest datapoint sent in bytes (0 is unlimited)
+	breaker      circuitBreaker     // Stops publishing after failures
+
+	separateByType bool          // One request per metric type
+	groupByHost    bool          // One request per host dimension
+	healthReset    time.Duration // Endpoint health score window (0 is disabled)
+
+	maxRetries   int           // Retries of a failed send
+	retryBackoff time.Duration // Delay before the first retry
+	retryJitter  bool          // Randomize retry delays
+
+	metricPrefix string       // Prefix of metric names
+	stripPrefix  []string     // Namespace elements removed from names
+	nameTemplate nameTemplate // Template for metric names
+	aliases      []aliasRule  // Namespaces mapped to fixed metric names
+
+	inferPatterns []*regexp.Regexp // Namespace patterns dimensions are inferred from
+
+	lowercaseNames  bool     // Lowercase metric names
+	lowercaseExcept []string // Namespaces whose names keep their case
+
+	keyAliases []keyAlias // Dimension keys renamed to a canonical key
+	keyPolicy  string     // Action on invalid dimension keys ("" is no validation)
+
+	dataKeyDims  []string // Map data keys used as dimensions
+	dataValueKey string   // Map data key holding the value
+
+	propertyKeys []string    // Dimensions sent as properties
+	maxProps     int         // Properties sent per datapoint (0 is unlimited)
+	allowlists   []allowlist // Allowed dimension values
+	relabelValue string      // Value unknown dimension values become
+
+	collectd   bool     // Derive collectd-style dimensions
+	collectdNS []string // Namespaces using collectd-style dimensions
+
+	dynamicDims bool // Send dynamic namespace elements as dimensions
+
+	aggregations []aggregateRule // Namespaces aggregated over a publish
+
+	transforms    []transformRule // Namespaces whose values are transformed
+	transformNaN  int64           // Values dropped as NaN or Inf after transforming
+	absNamespaces []string        // Namespaces sent as absolute values
+	nilDefaults   []nilDefault    // Values sent in place of nil data
+
+	splits         []splitRule // Namespaces holding several readings
+	splitDelimiter string      // Delimiter between readings
+	numericPrefer  string      // Type preferred for numeric strings
+
+	precision float64               // Float scale factor, 10^places
+	round     func(float64) float64 // Rounds scaled floats (nil is no rounding)
+
+	minAbsFloat float64 // Floats closer to zero are snapped or dropped
+	dropTiny    bool    // Drop rather than snap tiny floats
+
+	boolTrue  int64 // Value sent for true
+	boolFalse int64 // Value sent for false
+
+	allCounters bool               // Send every metric as a cumulative counter
+	cumulative  bool               // Send the metric as a cumulative counter
+	monotonic   string             // Action on decreasing cumulative counters
+	cumulatives map[string]float64 // Last cumulative values by series
+
+	counterSuffixes []string // Namespace suffixes sent as cumulative counters
+
+	deltas   []deltaRule       // Namespaces sent as delta counters
+	counters map[string]uint64 // Previous counter values by series
+	minDelta uint64            // Smallest delta sent
+
+	rateNamespaces []string              // Namespaces whose rates are sent as counters
+	rateTotals     map[string]*rateTotal // Running totals by series
+
+	emitRateNamespaces []string                // Counter namespaces also sent as rates
+	observations       map[string]*observation // Previous counter values by series
+
+	sendOnChange    bool                 // Suppress unchanged values
+	changeHeartbeat int64                // Cycles between forced sends
+	changes         map[string]*lastSent // Last values sent by series
+
+	pendingValues []lastSentUpdate                          // Values of the current metric, remembered once sent
+	awaiting      map[*datapoint.Datapoint][]lastSentUpdate // Values remembered once their datapoint is sent
+
+	deadbands         []deadbandRule       // Namespaces suppressed within a band
+	deadbandHeartbeat int64                // Cycles between forced sends
+	deadbandLast      map[string]*lastSent // Last values sent by series
+
+	series seriesGuard // Limits distinct series
+
+	rateLimit rateLimiter // Limits datapoints per metric name
+
+	dimLimits      map[string]*dimensionLimit // Distinct values allowed by dimension key
+	dimLimitWindow time.Duration              // How long dimension values are remembered
+	dimLimitStart  time.Time                  // Start of the current window
+	dimStripped    int64                      // Dimensions stripped over their limit
+
+	publishJitter time.Duration // Maximum delay before a publish
+
+	ctx          context.Context    // Cancelled once closed
+	cancel       context.CancelFunc // Cancels ctx
+	pending      int64              // Datapoints being sent
+	flushTimeout time.Duration      // Wait for sends on Close
+
+	publishTimeout time.Duration   // Bounds each request and publish (0 is none)
+	publishCtx     context.Context // Cancelled once the publish times out
+
+	batches []batch // Datapoints of this publish by route and timeout
+	workers int     // Batches sent at once
+
+	accumulateCycles  int64         // Publishes datapoints are held across
+	accumulateMaxAge  time.Duration // Longest datapoints are held
+	accumulated       []accumulated // Datapoints held by route
+	accumulatedAt     time.Time     // When the first datapoint was held
+	accumulatedCycles int64         // Publishes since the last flush
+
+	logger      *log.Logger // Log output, if not the standard logger
+	loggerSet   bool        // Logger set by SetLogger
+	errorLogger *log.Logger // Error output, if not the log output
+
+	now func() time.Time // Clock
+	rng *rand.Rand       // Random numbers, seeded from the hostname
+
+	publishMu sync.Mutex // Serializes publishes, guarding the state of the one in progress
+	initMu    sync.Mutex // Held while configuring, so that Close reads complete settings
+
+	mu sync.Mutex // Guards sent, failed, firstErr, lastErr, accumulated, counters, cumulatives, rateTotals, observations, metadataSynced, metadataDone, changes, deadbandLast, awaiting, series, rateLimit, dimLimits, overrides, and rng
 }
 
 // New - Constructor
 func New() *SignalFx {
-	return new(SignalFx)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SignalFx{
+		now:    time.Now,
+		stdout: os.Stdout,
+		ctx:    ctx,
+		cancel: cancel,
+	}
 }
 
-func (s *SignalFx) init(cfg plugin.Config) {
+// init - Configures the plugin, and creates the sinks reused across
+// publishes, on the first publish; an invalid config is retried on the
+// next publish
+func (s *SignalFx) init(cfg plugin.Config) error {
 	if s.initialized {
-		return
+		return nil
 	}
 
 	// Enable debugging
 	s.configDebugging(cfg)
+	s.setLogLevel(cfg)
+	s.setErrorLog(cfg)
+
+	// Check the settings that cannot be ignored
+	if err := s.setAPIVersion(cfg); err != nil {
+		s.errorf("%v", err)
+		return err
+	}
+	if err := s.setNameTemplate(cfg); err != nil {
+		s.errorf("%v", err)
+		return err
+	}
 
-	// Set our SignalFx API token
+	// Set our SignalFx API token, unless only logging datapoints
+	s.setDryRun(cfg)
 	s.setToken(cfg)
 
 	// Set the hostname
 	s.setHostname(cfg)
+	s.setHostnameDimensions(cfg)
 
-	log.Println("SignalFx Plugin Initialized")
+	// Seed the random numbers and delay publishes
+	s.setRandom()
+	s.setPublishJitter(cfg)
+
+	// Set the source type and pid dimensions
+	s.setSourceType(cfg)
+	s.setStaticDimensions(cfg)
+	s.setOwnership(cfg)
+	s.setIncludePid(cfg)
+	s.setIncludeHostID(cfg)
+	s.setCloudMetadata(cfg)
+	s.setSyncMetricMetadata(cfg)
+	s.setDimensionPrecedence(cfg)
+	s.setDebugSequence(cfg)
+
+	// Create the sinks
+	s.setOutput(cfg)
+	s.setStatsdMirror(cfg)
+	s.setPayloadFormat(cfg)
+	s.setCompressionMinBytes(cfg)
+	s.setPublishTimeout(cfg)
+	if !s.dryRun {
+		s.setSinks(cfg)
+		s.setTargets(cfg)
+		s.setRouteRules(cfg)
+	}
+	s.setSeparateByType(cfg)
+	s.setGroupByHost(cfg)
+	s.setEndpointHealth(cfg)
+	s.setWorkerPoolSize(cfg)
+	s.setInflightLimit(cfg)
+	s.setMaxDatapointBytes(cfg)
+	s.setCircuitBreaker(cfg)
+	s.setRetries(cfg)
+	s.setShutdownFlushTimeout(cfg)
+	s.setAccumulateCycles(cfg)
+
+	// Enable the plugin's own metrics
+	s.setSelfMetrics(cfg)
+	s.setRuntimeMetrics(cfg)
+	s.setLatencyBucket(cfg)
+	s.setEmitAge(cfg)
+	s.setRejectFuture(cfg)
+
+	// Set the namespaces sent as counters
+	s.setAllCounters(cfg)
+	s.setCounterSuffixes(cfg)
+	s.setMonotonicCheck(cfg)
+	s.setDeltaCounters(cfg)
+	s.setRateToCounter(cfg)
+	s.setEmitRate(cfg)
+
+	// Set the namespaces mapped to fixed metric names
+	s.setMetricPrefix(cfg)
+	s.setStripPrefix(cfg)
+	s.setLowercaseNames(cfg)
+	s.setAliasRules(cfg)
+	s.setInferDimensions(cfg)
+
+	// Enable collectd-style dimensions
+	s.setCollectdCompat(cfg)
+	s.setDynamicDimensions(cfg)
+
+	// Enable suppressing unchanged values
+	s.setSendOnChange(cfg)
+	s.setDeadband(cfg)
+
+	// Limit distinct series
+	s.setMaxSeries(cfg)
+	s.setPerMetricRateLimit(cfg)
+	s.setDimensionCardinalityLimit(cfg)
+
+	// Set the namespaces aggregated over a publish
+	s.setAggregateNamespaces(cfg)
+
+	// Set the values sent for booleans
+	s.setBoolMapping(cfg)
+	s.setFloatPrecision(cfg)
+	s.setMinAbsFloat(cfg)
+
+	// Compile the value transforms
+	s.setTransformRules(cfg)
+	s.setAbsNamespaces(cfg)
+	s.setNilDefaults(cfg)
+
+	// Set the namespaces holding several readings
+	s.setSplitValue(cfg)
+	s.setNumericCoercion(cfg)
+
+	// Set the map data keys used as dimensions
+	s.setDataKeyDimensions(cfg)
+	s.setDimensionKeyAliases(cfg)
+	s.setValidateDimensions(cfg)
+
+	// Set the allowed dimension values
+	s.setDimensionAllowlist(cfg)
+	s.setDimensionsToProperties(cfg)
+	s.setMaxProperties(cfg)
+
+	s.logln("SignalFx Plugin Initialized")
 	s.initialized = true
+	return nil
 }
 
-// GetConfigPolicy - Returns the configPolicy for the plugin
+// GetConfigPolicy - Returns the configPolicy for the plugin; it does not
+// depend on the plugin's state, so may be called before the first Publish
 func (s *SignalFx) GetConfigPolicy() (plugin.ConfigPolicy, error) {
 	policy := plugin.NewConfigPolicy()
 
-	// The SignalFx token
+	// The SignalFx token, required unless dry_run is set
 	policy.AddNewStringRule([]string{pluginVendor, pluginName},
 		"token",
-		true)
+		false)
+
+	// Log datapoints instead of sending them, without needing a token
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"dry_run",
+		false)
 
 	// The hostname to use (defaults to local hostname)
 	policy.AddNewStringRule([]string{pluginVendor, pluginName},
 		"hostname",
 		false)
 
-	// The file name to use when debugging
+	// Names of the hostname parts sent as dimensions, e.g. role-env-index
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"hostname_dimensions",
+		false)
+
+	// Delimiter between hostname parts (defaults to -)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"hostname_delimiter",
+		false)
+
+	// The source type sent with every datapoint as sf_source
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"source_type",
+		false)
+
+	// The dimensions sent with every datapoint (key=value,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimensions",
+		false)
+
+	// The org and team sent with every datapoint, for cost attribution
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"org",
+		false)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"team",
+		false)
+
+	// Where datapoints go (signalfx, stdout, or both)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"output",
+		false)
+
+	// The timeout of each request and publish, e.g. 10s, or in seconds
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"timeout",
+		false)
+
+	// A UDP statsd address every datapoint is also sent to
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"statsd_mirror_addr",
+		false)
+
+	// Send the plugin's process id as the pid dimension
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"include_pid",
+		false)
+
+	// Send a hash of the hostname and machine id as the host_id dimension
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"include_host_id",
+		false)
+
+	// The cloud provider whose instance metadata is sent as dimensions
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"cloud_metadata",
+		false)
+
+	// Push the description and unit of new metric names to SignalFx
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"sync_metric_metadata",
+		false)
+
+	// The SignalFx API metric metadata is pushed to
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"metadata_endpoint",
+		false)
+
+	// The dimension sources, from the highest precedence to the lowest
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimension_precedence",
+		false)
+
+	// Number every datapoint in a seq dimension, for debugging
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"debug_sequence",
+		false)
+
+	// The SignalFx ingest endpoint (defaults to the library default)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"endpoint",
+		false)
+
+	// The ingest API version, e.g. v2
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"api_version",
+		false)
+
+	// The payload format (protobuf or json)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"payload_format",
+		false)
+
+	// The smallest payload in bytes that is compressed
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"compression_min_bytes",
+		false)
+
+	// The endpoint to use when the primary endpoint fails
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"fallback_endpoint",
+		false)
+
+	// Additional targets to send to (name=endpoint[;token],...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"targets",
+		false)
+
+	// The namespaces sent to specific targets (prefix=target,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"route_rules",
+		false)
+
+	// The target of namespaces not matching a route rule
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"route_default",
+		false)
+
+	// The number of targets to send to at once
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"fanout_concurrency",
+		false)
+
+	// Prefer the healthier of the endpoint and fallback_endpoint
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"endpoint_health",
+		false)
+
+	// The seconds between resets of the endpoint health scores
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"endpoint_health_reset",
+		false)
+
+	// Send each metric type as its own request
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"separate_by_type",
+		false)
+
+	// Send each host's datapoints as its own request
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"group_by_host",
+		false)
+
+	// The number of batches sent at once
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"worker_pool_size",
+		false)
+
+	// The maximum approximate bytes being sent at once
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"max_inflight_bytes",
+		false)
+
+	// The approximate bytes above which a single datapoint is dropped
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"max_datapoint_bytes",
+		false)
+
+	// What to do when max_inflight_bytes is exceeded (block or drop)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"buffer_full_policy",
+		false)
+
+	// Only send values that changed since they were last sent
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"send_on_change",
+		false)
+
+	// The cycles after which unchanged values are sent anyway
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"change_heartbeat",
+		false)
+
+	// The namespaces suppressed within a band of the last value (prefix=band[%],...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"deadband",
+		false)
+
+	// The maximum distinct series sent within the series window
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"max_series",
+		false)
+
+	// The seconds after which the series seen are forgotten
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"series_window",
+		false)
+
+	// The datapoints sent per metric name within the rate window
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"per_metric_rate_limit",
+		false)
+
+	// The seconds per_metric_rate_limit applies to
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"per_metric_rate_window",
+		false)
+
+	// The distinct values allowed by dimension key (key=max,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimension_cardinality_limit",
+		false)
+
+	// Send the plugin's own metrics
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"self_metrics",
+		false)
+
+	// Send the plugin's Go runtime metrics
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"runtime_metrics",
+		false)
+
+	// Send self metrics with the previous publish's latency_bucket
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"latency_bucket",
+		false)
+
+	// The retries of a failed send
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"max_retries",
+		false)
+
+	// The milliseconds before the first retry, doubling with each retry
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"retry_backoff",
+		false)
+
+	// Randomize retry delays
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"retry_jitter",
+		false)
+
+	// The consecutive failed publishes that open the circuit
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"circuit_failure_threshold",
+		false)
+
+	// The seconds the circuit stays open
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"circuit_cooldown",
+		false)
+
+	// Send the age of each metric as <name>.age_seconds
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"emit_age",
+		false)
+
+	// Drop metrics timestamped in the future
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"reject_future_timestamps",
+		false)
+
+	// The milliseconds past now a timestamp may be
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"future_tolerance",
+		false)
+
+	// The maximum milliseconds to delay each publish by
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"publish_jitter",
+		false)
+
+	// The milliseconds Close waits for datapoints being sent
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"shutdown_flush_timeout",
+		false)
+
+	// The publishes datapoints are held across and sent together
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"accumulate_cycles",
+		false)
+
+	// The maximum seconds datapoints are held
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"accumulate_max_age",
+		false)
+
+	// The file the log is written to (defaults to stderr)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"log_file",
+		false)
+
+	// The older name of log_file
 	policy.AddNewStringRule([]string{pluginVendor, pluginName},
 		"debug_file",
 		false)
 
+	// The file name error messages are sent to
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"error_log_path",
+		false)
+
+	// The namespaces aggregated over a publish (prefix:function,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"aggregate_namespaces",
+		false)
+
+	// The value transforms (prefix=expression,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"transform_rules",
+		false)
+
+	// The namespaces sent as absolute values
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"abs_namespaces",
+		false)
+
+	// The values sent in place of nil data (prefix=value,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"nil_default",
+		false)
+
+	// The namespaces holding several readings (prefix=dimension,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"split_value",
+		false)
+
+	// The delimiter between readings (defaults to a comma)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"split_delimiter",
+		false)
+
+	// The type preferred for numeric strings (int or float)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"numeric_coercion_prefer",
+		false)
+
+	// The values sent for booleans (inverted or true=<int>,false=<int>)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"bool_mapping",
+		false)
+
+	// The decimal places floats are rounded to
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"float_precision",
+		false)
+
+	// How floats are rounded (nearest, floor, ceil, or truncate)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"rounding_mode",
+		false)
+
+	// Floats closer to zero than this are snapped to zero or dropped
+	policy.AddNewFloatRule([]string{pluginVendor, pluginName},
+		"min_abs_float",
+		false)
+
+	// What happens to tiny floats (zero or drop)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"min_abs_float_action",
+		false)
+
+	// Send every metric as a cumulative counter
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"all_counters",
+		false)
+
+	// Namespace suffixes sent as cumulative counters
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"counter_suffixes",
+		false)
+
+	// The action on decreasing cumulative counters (skip or mark)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"monotonic_check",
+		false)
+
+	// The namespaces to send as delta counters (prefix[:width],...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"delta_counters",
+		false)
+
+	// The dimension keys renamed to a canonical key (from=to,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimension_key_aliases",
+		false)
+
+	// Check dimension keys against the SignalFx rules
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"validate_dimensions",
+		false)
+
+	// The action on invalid dimension keys (warn, strip, or drop)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"validate_dimensions_policy",
+		false)
+
+	// The map data keys to send as dimensions
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"data_key_dimensions",
+		false)
+
+	// The map data key holding the value (defaults to value)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"data_value_key",
+		false)
+
+	// The allowed dimension values (key=value|value:action,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimension_value_allowlist",
+		false)
+
+	// The value unknown dimension values are relabeled to
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimension_value_default",
+		false)
+
+	// The dimensions sent as datapoint properties instead
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"dimensions_to_properties",
+		false)
+
+	// The maximum properties sent per datapoint
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"max_properties",
+		false)
+
+	// The log level (debug, info, warn, error)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"log_level",
+		false)
+
+	// The prefix of metric names (defaults to snap, empty for none)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"metric_prefix",
+		false)
+
+	// The leading namespace elements removed from metric names
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"strip_prefix",
+		false)
+
+	// The template for metric names, e.g. {prefix}.{namespace}
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"name_template",
+		false)
+
+	// Lowercase metric names
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"lowercase_names",
+		false)
+
+	// The namespaces whose names keep their case
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"lowercase_exceptions",
+		false)
+
+	// The namespace patterns mapped to fixed metric names (pattern=name,...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"alias_rules",
+		false)
+
+	// The namespace patterns dimensions are inferred from (regexp;...)
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"infer_dimensions",
+		false)
+
+	// The smallest delta counter value sent
+	policy.AddNewIntRule([]string{pluginVendor, pluginName},
+		"min_delta",
+		false)
+
+	// The namespaces whose per-second rates are sent as cumulative counters
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"rate_to_counter",
+		false)
+
+	// The counter namespaces also sent as <name>.rate gauges
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"emit_rate",
+		false)
+
+	// Send dynamic namespace elements as dimensions
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"dynamic_dimensions",
+		false)
+
+	// Derive collectd-style dimensions from the namespace
+	policy.AddNewBoolRule([]string{pluginVendor, pluginName},
+		"collectd_compat",
+		false)
+
+	// The namespaces to derive collectd-style dimensions for
+	policy.AddNewStringRule([]string{pluginVendor, pluginName},
+		"collectd_namespaces",
+		false)
+
 	return *policy, nil
 }
 
-// Publish - Publishes metrics to SignalFx using the TOKEN found in the config
+// Publish - Publishes metrics to SignalFx using the TOKEN found in the config;
+// concurrent publishes run one at a time
 func (s *SignalFx) Publish(mts []plugin.Metric, cfg plugin.Config) error {
-	if len(mts) > 0 {
-		s.init(cfg)
+	if len(mts) == 0 {
+		return nil
+	}
+	if len(cfg) == 0 {
+		s.errorf("%v", errNoConfig)
+		return errNoConfig
+	}
+
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	start := time.Now()
+	s.initMu.Lock()
+	err := s.init(cfg)
+	s.initMu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.reloadConfig(cfg)
+	s.lastErr = nil
+	s.firstErr = nil
+	s.sent, s.failed = 0, 0
+	s.typeCounts = make(map[datapoint.MetricType]int64)
+	s.filtered = make(map[string]int64)
+	s.received = len(mts)
+
+	// Forget the values of datapoints never sent, e.g. dropped or held
+	s.mu.Lock()
+	s.awaiting = make(map[*datapoint.Datapoint][]lastSentUpdate)
+	s.mu.Unlock()
+
+	// Fail fast while SignalFx is unavailable
+	if !s.breaker.allow() {
+		return errCircuitOpen
+	}
+
+	// Bound the publish's sends by the timeout, if any
+	end := s.startPublish()
+	defer end()
+
+	// Spread publishes from many hosts out over time, within the timeout
+	if err := s.delayPublish(); err != nil {
+		return err
 	}
 
+	// Values aggregated over the publish
+	aggregates := make(map[string]*aggregate)
+
 	// Iterate over the supplied metrics
 	for _, m := range mts {
-		var buffer bytes.Buffer
+		// Skip metrics that would only be named by the prefix
+		if emptyNamespace(m.Namespace.Strings()) {
+			s.debugf("Skipping metric with an empty namespace")
+			s.countFiltered(filterEmptyNamespace)
+			continue
+		}
+
+		// Map aliased namespaces to their fixed metric name, ahead of the
+		// other naming settings
+		elements, dynamicDims := s.dynamicElements(m.Namespace)
+		alias, aliasDims, aliased := s.aliasFor(m.Namespace.Strings())
+		if aliased {
+			s.namespace = s.prefixName(alias)
+		} else {
+			// Convert the namespace to dot notation
+			ns := s.stripNamespace(elements)
+			s.namespace = s.prefixName(strings.Join(ns, "."))
+
+			// Name the metric using the template, if any
+			if s.nameTemplate != nil {
+				s.namespace = s.nameTemplate.render(s.metricPrefix, ns, m.Unit)
+			}
+			s.namespace = s.lowercaseName(m.Namespace.String(), s.namespace)
+		}
+
+		// Pick the targets for the metric
+		if !s.dryRun {
+			s.route = s.metricRoute(m)
+		}
+
+		// Use the metric's own timeout and type, if any
+		s.cumulative = s.isCumulative(m)
+		s.timeout = s.timeoutFromTags(m.Tags)
+		s.timestamp = s.timestampFromTags(m.Tags)
+		if s.futureTimestamp(m) {
+			continue
+		}
+
+		// Build the dimensions, starting with the metric's tags
+		s.resetDimensions()
+		s.addDimensions(sourceTag, s.tagDimensions(m.Tags))
+		s.addDimensions(sourceInferred, dynamicDims)
+		if s.collectdCompatFor(m.Namespace.String()) {
+			s.addDimensions(sourceInferred, collectdDimensions(m.Namespace.Strings()))
+		}
+
+		// Send the dimensions extracted by the alias rule, if any
+		s.addDimensions(sourceInferred, aliasDims)
+
+		// Extract dimensions, and the name, from matching namespaces
+		if name, dims, ok := s.inferDimensions(m.Namespace.String()); ok {
+			if name != "" {
+				s.namespace = name
+			}
+			s.addDimensions(sourceInferred, dims)
+		}
+
+		// Skip metrics the naming settings left without a name
+		if s.emptyName(s.namespace) {
+			s.debugf("Skipping %s, its metric name %q is empty", m.Namespace.String(), s.namespace)
+			s.countFiltered(filterEmptyName)
+			continue
+		}
+
+		// Describe new metric names to SignalFx
+		s.syncMetricMetadata(m)
+
+		// Split map data into its value and dimensions
+		if data, ok := m.Data.(map[string]interface{}); ok && len(s.dataKeyDims) > 0 {
+			m.Data = s.unpackData(data)
+		}
+
+		// Send a default in place of nil data, if configured
+		if m.Data == nil {
+			if value, ok := s.nilDefaultFor(m.Namespace.String()); ok {
+				m.Data = value
+			}
+		}
+
+		// Transform configured namespaces
+		if rule, ok := s.transformRuleFor(m.Namespace.String()); ok {
+			if value, ok := toFloat64(m.Data); ok {
+				result := rule.expr.eval(value)
+				if s.invalidTransform(rule, result) {
+					continue
+				}
+				m.Data = result
+			}
+		}
+
+		// Send the magnitude of configured namespaces
+		if hasAnyPrefix(m.Namespace.String(), s.absNamespaces) {
+			m.Data = absValue(m.Data)
+		}
+
+		// Merge dimension keys differing only by casing
+		s.aliasDimensionKeys()
+
+		// Check the dimension keys against the SignalFx rules
+		if !s.validateDimensionKeys() {
+			continue
+		}
+
+		// Enforce the allowed dimension values
+		if !s.applyAllowlists() {
+			continue
+		}
+
+		// Move metadata dimensions to properties
+		s.properties = s.extractProperties()
+
+		// Strip dimensions with too many distinct values
+		s.limitDimensions()
+
+		// Drop new series once there are too many
+		if !s.acceptSeries() {
+			continue
+		}
+
+		// Send how long ago the metric was collected
+		if s.emitAge {
+			s.sendAge(m.Timestamp)
+		}
+
+		// Split strings holding several readings
+		if rule, ok := s.splitRuleFor(m.Namespace.String()); ok {
+			if value, ok := m.Data.(string); ok {
+				s.sendSplitValue(rule, value)
+				continue
+			}
+		}
+
+		// Aggregate configured namespaces, sending them at the end
+		if rule, ok := s.aggregateRuleFor(m.Namespace.String()); ok {
+			if _, ok := toFloat64(m.Data); ok {
+				s.aggregateValue(aggregates, rule.fn, m.Data)
+				continue
+			}
+		}
+
+		// The time the metric was seen, for rates
+		at := m.Timestamp
+		if at.IsZero() {
+			at = s.now()
+		}
 
-		// Convert the namespace to dot notation
-		fmt.Fprintf(&buffer, "snap.%s", strings.Join(m.Namespace.Strings(), "."))
-		s.namespace = buffer.String()
+		// Send the rates of configured counters alongside them
+		if hasAnyPrefix(m.Namespace.String(), s.emitRateNamespaces) {
+			if value, ok := toFloat64(m.Data); ok {
+				s.sendRateGauge(value, at)
+			}
+		}
+
+		// Send configured rates as cumulative counters
+		if hasAnyPrefix(m.Namespace.String(), s.rateNamespaces) {
+			if value, ok := toFloat64(m.Data); ok {
+				s.sendRateCounter(value, at)
+				continue
+			}
+		}
+
+		// Send configured counters as deltas
+		if rule, ok := s.deltaRuleFor(m.Namespace.String()); ok {
+			if value, ok := toUint64(m.Data); ok {
+				s.sendDelta(rule, value)
+				continue
+			}
+		}
+
+		// Values of the metric are only remembered as sent once they are
+		s.pendingValues = nil
+
+		// Suppress values within the namespace's deadband
+		if rule, ok := s.deadbandRuleFor(m.Namespace.String()); ok {
+			if value, ok := toFloat64(m.Data); ok && s.withinDeadband(rule, value) {
+				s.debugf("Skipping %s within its deadband", s.namespace)
+				s.countFiltered(filterDeadband)
+				continue
+			}
+		}
 
 		// Do some type conversion and send the data
 		switch v := m.Data.(type) {
@@ -124,43 +1158,90 @@ func (s *SignalFx) Publish(mts []plugin.Metric, cfg plugin.Config) error {
 			s.sendFloatValue(float64(v))
 		case float64:
 			s.sendFloatValue(float64(v))
+		case bool:
+			s.sendIntValue(s.boolValue(v))
+		case string:
+			s.sendStringValue(v)
 		default:
-			log.Printf("Ignoring %T: %v\n", v, v)
-			log.Printf("Contact the plugin author if you think this is an error")
+			s.logf("Ignoring %T: %v\n", v, v)
+			s.logf("Contact the plugin author if you think this is an error")
 		}
 	}
 
-	return nil
+	// Send the aggregated values
+	s.pendingValues = nil
+	s.sendAggregates(aggregates)
+
+	// Send the publish's datapoints together
+	s.flushBatches()
+
+	// Report on the publish, routed like unmatched namespaces
+	s.timeout = 0
+	s.timestamp = time.Time{}
+	s.properties = nil
+	s.route = s.routeFor("")
+	s.breaker.record(s.lastErr)
+	if s.selfMetrics {
+		s.sendSelfMetrics()
+	}
+	if s.runtimeMetrics {
+		s.sendRuntimeMetrics()
+	}
+	s.flushBatches()
+
+	// Send what was accumulated, once due
+	s.endCycle()
+
+	s.lastLatency = time.Since(start)
+	return s.publishErr()
+}
+
+// publishErr returns the first send failure of the publish, if any,
+// saying whether any datapoints were sent
+func (s *SignalFx) publishErr() error {
+	if s.firstErr == nil {
+		return nil
+	}
+	return &publishError{sent: s.sent, failed: s.failed, err: s.firstErr}
 }
 
-// configDebugging will configure logging if the debug_file config
-// setting is present in the task file
+// configDebugging will send the log to a file, once, if the log_file (or
+// the older debug_file) config setting is present in the task file;
+// otherwise the log goes to stderr
 func (s *SignalFx) configDebugging(cfg plugin.Config) {
-	fileName, err := cfg.GetString("debug_file")
+	fileName, err := s.getString(cfg, "log_file")
 	if err != nil {
-		// No debug_file defined, moving on
+		fileName, err = s.getString(cfg, "debug_file")
+	}
+	if err != nil || fileName == "" || s.loggerSet || s.logger != nil {
+		// No log_file defined, or a logger was set, moving on
 		return
 	}
 
-	// Open the output file
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	// Open the output file, readable only by us since it may be shared
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s", err.Error())
+		fmt.Fprintf(os.Stderr, "Unable to open log_file, logging to stderr: %v\n", err)
 		return
 	}
 
 	// Set logging output for debugging
-	log.SetOutput(f)
+	s.logger = log.New(f, "", log.LstdFlags)
 }
 
 // setToken will set the token required by the SignalFx API
 func (s *SignalFx) setToken(cfg plugin.Config) {
-	log.Println("Setting token from config file")
+	s.logln("Setting token from config file")
 
 	// Fetch the token
-	token, err := cfg.GetString("token")
+	token, err := getString(cfg, "token")
+	if err != nil && s.dryRun {
+		// No token needed for a dry run, moving on
+		return
+	}
 	if err != nil {
-		log.Panic(err)
+		s.errorf("%v", err)
+		panic(err)
 	}
 	s.token = token
 }
@@ -169,9 +1250,9 @@ func (s *SignalFx) setToken(cfg plugin.Config) {
 // will attempt to figure out the hostname. As a last resort, we default
 // to using localhost.
 func (s *SignalFx) setHostname(cfg plugin.Config) {
-	log.Println("Determining hostname")
+	s.logln("Determining hostname")
 
-	hostname, err := cfg.GetString("hostname")
+	hostname, err := s.getString(cfg, "hostname")
 	if err != nil {
 		hostname, err = os.Hostname()
 		if err != nil {
@@ -180,33 +1261,84 @@ func (s *SignalFx) setHostname(cfg plugin.Config) {
 	}
 	s.hostname = hostname
 
-	log.Printf("Using %s\n", hostname)
+	s.logf("Using %s\n", hostname)
 }
 
 // sendIntValue - Method for sending int64 values to SignalFx
 func (s *SignalFx) sendIntValue(value int64) {
-	log.Printf("Sending [int64] %s -> %v", s.namespace, value)
-
-	client := sfxclient.NewHTTPDatapointSink()
-	client.AuthToken = s.token
-	ctx := context.Background()
-	client.AddDatapoints(ctx, []*datapoint.Datapoint{
-		sfxclient.Gauge(s.namespace, map[string]string{
-			"host": s.hostname,
-		}, value),
-	})
+	if s.unchanged(value) {
+		s.debugf("Skipping unchanged %s", s.namespace)
+		s.countFiltered(filterUnchanged)
+		return
+	}
+
+	s.logf("Sending [int64] %s -> %v", s.namespace, value)
+
+	if s.cumulative {
+		dp := sfxclient.Cumulative(s.namespace, s.dimensions, value)
+		if s.checkMonotonic(dp, float64(value)) {
+			s.send(dp)
+		}
+		return
+	}
+	s.send(sfxclient.Gauge(s.namespace, s.dimensions, value))
 }
 
 // sendFloatValue - Method for sending float64 values to SignalFx
 func (s *SignalFx) sendFloatValue(value float64) {
-	log.Printf("Sending [float64] %s -> %v", s.namespace, value)
-
-	client := sfxclient.NewHTTPDatapointSink()
-	client.AuthToken = s.token
-	ctx := context.Background()
-	client.AddDatapoints(ctx, []*datapoint.Datapoint{
-		sfxclient.GaugeF(s.namespace, map[string]string{
-			"host": s.hostname,
-		}, value),
-	})
+	value, ok := s.snapTinyFloat(value)
+	if !ok {
+		s.debugf("Dropping %s, %v is below min_abs_float", s.namespace, value)
+		s.countFiltered(filterTinyFloat)
+		return
+	}
+	value = s.roundFloat(value)
+
+	if s.unchanged(value) {
+		s.debugf("Skipping unchanged %s", s.namespace)
+		s.countFiltered(filterUnchanged)
+		return
+	}
+
+	s.logf("Sending [float64] %s -> %v", s.namespace, value)
+
+	if s.cumulative {
+		dp := sfxclient.CumulativeF(s.namespace, s.dimensions, value)
+		if s.checkMonotonic(dp, value) {
+			s.send(dp)
+		}
+		return
+	}
+	s.send(sfxclient.GaugeF(s.namespace, s.dimensions, value))
+}
+
+// sendStringValue - Method for sending string values to SignalFx; numeric
+// strings are sent as numbers, per numeric_coercion_prefer
+func (s *SignalFx) sendStringValue(value string) {
+	if n, ok := s.parseNumeric(strings.TrimSpace(value)); ok {
+		switch v := n.(type) {
+		case int64:
+			s.sendIntValue(v)
+		case float64:
+			s.sendFloatValue(v)
+		}
+		return
+	}
+
+	if s.unchanged(value) {
+		s.debugf("Skipping unchanged %s", s.namespace)
+		s.countFiltered(filterUnchanged)
+		return
+	}
+
+	s.logf("Sending [string] %s -> %v", s.namespace, value)
+
+	s.send(datapoint.New(s.namespace, s.dimensions, datapoint.NewStringValue(value), datapoint.Gauge, time.Time{}))
+}
+
+// sendCounterValue - Method for sending int64 deltas to SignalFx
+func (s *SignalFx) sendCounterValue(value int64) {
+	s.logf("Sending [counter] %s -> %v", s.namespace, value)
+
+	s.send(sfxclient.Counter(s.namespace, s.dimensions, value))
 }
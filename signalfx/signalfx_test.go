@@ -0,0 +1,559 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	sfxproto "github.com/signalfx/com_signalfx_metrics_protobuf"
+)
+
+// received - A datapoint received by the ingest server
+type received struct {
+	Metric     string
+	Dimensions map[string]string
+	Properties map[string]interface{} // Only sent in JSON payloads
+	Value      interface{}            // int64, float64, or string
+	Timestamp  int64                  // Milliseconds since the epoch, if any
+	Type       string                 // Metric type, e.g. gauge
+	Token      string                 // X-SF-Token of the request
+}
+
+// ingestServer - A mock SignalFx ingest endpoint recording the datapoints
+// of protobuf and JSON payloads
+type ingestServer struct {
+	*httptest.Server
+
+	status func(request int) int // Status answering a request, if set
+
+	mu         sync.Mutex
+	requests   int
+	datapoints []received
+}
+
+// newIngestServer starts an ingest server accepting every request, unless
+// a status is set
+func newIngestServer() *ingestServer {
+	is := &ingestServer{}
+	is.Server = httptest.NewServer(http.HandlerFunc(is.handle))
+	return is
+}
+
+// handle - Records the request and its datapoints
+func (is *ingestServer) handle(w http.ResponseWriter, r *http.Request) {
+	is.mu.Lock()
+	is.requests++
+	request := is.requests
+	is.mu.Unlock()
+
+	if is.status != nil {
+		if status := is.status(request); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+
+	token := r.Header.Get("X-SF-Token")
+	if r.Header.Get("Content-Type") == "application/json" {
+		var payload map[string][]jsonDatapoint
+		decoder := json.NewDecoder(body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		is.mu.Lock()
+		for key, dps := range payload {
+			for _, dp := range dps {
+				is.datapoints = append(is.datapoints, jsonReceived(dp, key, token))
+			}
+		}
+		is.mu.Unlock()
+
+		io.WriteString(w, `"OK"`)
+		return
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var msg sfxproto.DataPointUploadMessage
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	is.mu.Lock()
+	for _, dp := range msg.GetDatapoints() {
+		is.datapoints = append(is.datapoints, protobufDatapoint(dp, token))
+	}
+	is.mu.Unlock()
+
+	io.WriteString(w, `"OK"`)
+}
+
+// protobufDatapoint converts a datapoint of a protobuf payload
+func protobufDatapoint(dp *sfxproto.DataPoint, token string) received {
+	rdp := received{
+		Metric:     dp.GetMetric(),
+		Dimensions: make(map[string]string),
+		Timestamp:  dp.GetTimestamp(),
+		Type:       strings.ToLower(dp.GetMetricType().String()),
+		Token:      token,
+	}
+	for _, dim := range dp.GetDimensions() {
+		rdp.Dimensions[dim.GetKey()] = dim.GetValue()
+	}
+
+	// A value carrying both an integer and a double is received as neither,
+	// failing the tests checking it
+	switch v := dp.GetValue(); {
+	case v.IntValue != nil && v.DoubleValue != nil:
+		rdp.Value = fmt.Sprintf("int %d and double %v", v.GetIntValue(), v.GetDoubleValue())
+	case v.IntValue != nil:
+		rdp.Value = v.GetIntValue()
+	case v.DoubleValue != nil:
+		rdp.Value = v.GetDoubleValue()
+	default:
+		rdp.Value = v.GetStrValue()
+	}
+	return rdp
+}
+
+// jsonReceived converts a datapoint of a JSON payload sent under the type
+// key, decoding numbers as protobuf values would be
+func jsonReceived(dp jsonDatapoint, key, token string) received {
+	rdp := received{
+		Metric:     dp.Metric,
+		Dimensions: dp.Dimensions,
+		Properties: dp.Properties,
+		Value:      dp.Value,
+		Timestamp:  dp.Timestamp,
+		Type:       key,
+		Token:      token,
+	}
+	if rdp.Dimensions == nil {
+		rdp.Dimensions = make(map[string]string)
+	}
+
+	if n, ok := dp.Value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			rdp.Value = i
+		} else if f, err := n.Float64(); err == nil {
+			rdp.Value = f
+		}
+	}
+	return rdp
+}
+
+// requestCount returns the number of requests received
+func (is *ingestServer) requestCount() int {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return is.requests
+}
+
+// received returns the datapoints received by metric name
+func (is *ingestServer) received() map[string]received {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	dps := make(map[string]received)
+	for _, dp := range is.datapoints {
+		dps[dp.Metric] = dp
+	}
+	return dps
+}
+
+// find returns the first datapoint received with the metric name and, if
+// given, dimension key and value
+func (is *ingestServer) find(metric string, dim ...string) (received, bool) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	for _, dp := range is.datapoints {
+		if dp.Metric != metric {
+			continue
+		}
+		if len(dim) == 2 && dp.Dimensions[dim[0]] != dim[1] {
+			continue
+		}
+		return dp, true
+	}
+	return received{}, false
+}
+
+// statusServer starts a server answering every request with the status
+func statusServer(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+// bodyServer starts a server answering every request with the status and
+// body
+func bodyServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+}
+
+// slowServer starts a server answering after a second, or once the
+// request is cancelled
+func slowServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+// newTestPlugin returns a plugin for testing
+func newTestPlugin() *SignalFx {
+	return New()
+}
+
+// testConfig returns a config sending to the endpoint, with the settings
+// added
+func testConfig(endpoint string, settings plugin.Config) plugin.Config {
+	cfg := plugin.Config{
+		"token":    "ABCD1234",
+		"endpoint": endpoint,
+	}
+	for k, v := range settings {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+// newMetric returns a metric with the data and namespace
+func newMetric(data interface{}, ns ...string) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace(ns...),
+		Data:      data,
+	}
+}
+
+// publish publishes the metrics with the settings to a new ingest server,
+// failing the test on an error, and returns the datapoints received
+func publish(t *testing.T, settings plugin.Config, mts ...plugin.Metric) map[string]received {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	if err := s.Publish(mts, testConfig(is.URL, settings)); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	return is.received()
+}
+
+// publishEach publishes each set of metrics in turn with the same plugin
+// and settings to a new ingest server, failing the test on an error, and
+// returns the datapoints received for each publish
+func publishEach(t *testing.T, settings plugin.Config, cycles ...[]plugin.Metric) []map[string]received {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, settings)
+
+	var dps []map[string]received
+	for _, mts := range cycles {
+		is.mu.Lock()
+		is.datapoints = nil
+		is.mu.Unlock()
+
+		if err := s.Publish(mts, cfg); err != nil {
+			t.Fatalf("Publish returned %v", err)
+		}
+		dps = append(dps, is.received())
+	}
+	return dps
+}
+
+func TestPublishSendsGauges(t *testing.T) {
+	dps := publish(t, nil,
+		newMetric(int64(42), "intel", "cpu", "idle"),
+		newMetric(3.5, "intel", "cpu", "load"),
+	)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"snap.intel.cpu.idle", "42"},
+		{"snap.intel.cpu.load", "3.5"},
+	}
+	for _, tt := range tests {
+		dp, ok := dps[tt.name]
+		if !ok {
+			t.Errorf("%s was not sent", tt.name)
+			continue
+		}
+		if dp.Type != "gauge" {
+			t.Errorf("%s sent as %s, want gauge", tt.name, dp.Type)
+		}
+		if got := fmt.Sprint(dp.Value); got != tt.value {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.value)
+		}
+		if dp.Token != "ABCD1234" {
+			t.Errorf("%s sent with token %q", tt.name, dp.Token)
+		}
+	}
+}
+
+func TestPublishWithoutMetrics(t *testing.T) {
+	s := newTestPlugin()
+	if err := s.Publish(nil, plugin.Config{}); err != nil {
+		t.Errorf("Publish returned %v for no metrics", err)
+	}
+}
+
+func TestPublishWithoutConfig(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	tests := []struct {
+		name string
+		cfg  plugin.Config
+	}{
+		{"nil", nil},
+		{"empty", plugin.Config{}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, tt.cfg); err != errNoConfig {
+			t.Errorf("%s: Publish returned %v, want %v", tt.name, err, errNoConfig)
+		}
+		if s.initialized {
+			t.Errorf("%s: the plugin was configured", tt.name)
+		}
+
+		// A later publish with a config goes ahead
+		if err := s.Publish([]plugin.Metric{newMetric(int64(2), "intel", "cpu", "idle")}, testConfig(is.URL, nil)); err != nil {
+			t.Errorf("%s: Publish with a config returned %v", tt.name, err)
+		}
+	}
+
+	dp, ok := is.received()["snap.intel.cpu.idle"]
+	if !ok {
+		t.Fatal("Nothing was sent")
+	}
+	if dp.Value != int64(2) {
+		t.Errorf("Sent %v, want only the datapoints published with a config", dp.Value)
+	}
+}
+
+func TestGetConfigPolicyBeforeInit(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *SignalFx
+	}{
+		{"New", New()},
+		{"zero value", &SignalFx{}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.s.GetConfigPolicy(); err != nil {
+			t.Errorf("%s: GetConfigPolicy returned %v", tt.name, err)
+		}
+		if tt.s.initialized {
+			t.Errorf("%s: GetConfigPolicy configured the plugin", tt.name)
+		}
+	}
+}
+
+func TestPublishBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  int
+		requests int
+	}{
+		{"one metric", 1, 1},
+		{"several metrics", 10, 1},
+		{"a collection cycle", 300, 1},
+		{"no metrics", 0, 0},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		var mts []plugin.Metric
+		for i := 0; i < tt.metrics; i++ {
+			mts = append(mts, newMetric(int64(i), "intel", "disk", strconv.Itoa(i)))
+		}
+		s := newTestPlugin()
+		if err := s.Publish(mts, testConfig(is.URL, nil)); err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+		is.Close()
+
+		if got := is.requestCount(); got != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.requests)
+		}
+		is.mu.Lock()
+		if got := len(is.datapoints); got != tt.metrics {
+			t.Errorf("%s: %d datapoints sent, want %d", tt.name, got, tt.metrics)
+		}
+		is.mu.Unlock()
+	}
+}
+
+func TestPublishReusesSink(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, nil)
+	if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	sink := s.targets[0].sink
+
+	if err := s.Publish([]plugin.Metric{newMetric(int64(2), "intel", "cpu", "idle")}, cfg); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	if s.targets[0].sink != sink {
+		t.Error("A new sink was created for the second publish")
+	}
+	if got := is.requestCount(); got != 2 {
+		t.Errorf("%d requests, want one per publish", got)
+	}
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	// Run with -race to catch unguarded state
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{
+		"self_metrics":   true,
+		"send_on_change": true,
+		"delta_counters": "/intel/net",
+		"max_series":     int64(1000),
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				mts := []plugin.Metric{
+					newMetric(int64(i), "intel", "cpu", "idle"),
+					newMetric(uint64(g*100+i), "intel", "net", "bytes"),
+				}
+				if err := s.Publish(mts, cfg); err != nil {
+					t.Errorf("Publish returned %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if is.requestCount() == 0 {
+		t.Error("No datapoints were sent")
+	}
+}
+
+func TestConcurrentFirstPublish(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	// Run with -race to catch the sinks being created more than once
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, nil)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			<-start
+			if err := s.Publish([]plugin.Metric{newMetric(int64(g), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("Publish returned %v", err)
+			}
+		}(g)
+	}
+	close(start)
+	wg.Wait()
+
+	sink := s.targets[0].sink
+	if err := s.Publish([]plugin.Metric{newMetric(int64(8), "intel", "cpu", "idle")}, cfg); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	if len(s.targets) != 1 || s.targets[0].sink != sink {
+		t.Error("The sinks were recreated")
+	}
+	if got := is.requestCount(); got != 9 {
+		t.Errorf("%d requests, want one per publish", got)
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{"payload_format": formatProtobuf})
+	mts := make([]plugin.Metric, 100)
+	for i := range mts {
+		mts[i] = newMetric(int64(i), "intel", "cpu", strconv.Itoa(i), "idle")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Publish(mts, cfg); err != nil {
+			b.Fatalf("Publish returned %v", err)
+		}
+	}
+}
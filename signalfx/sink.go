@@ -0,0 +1,392 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	"golang.org/x/net/context"
+)
+
+// target - A SignalFx endpoint datapoints are sent to
+type target struct {
+	name     string                       // Target name
+	token    string                       // Target's own token ("" uses the token setting)
+	sink     *sfxclient.HTTPDatapointSink // Sink for the endpoint
+	fallback *sfxclient.HTTPDatapointSink // Sink for the fallback endpoint
+	health   endpointHealth               // Scores of the endpoints
+}
+
+// setSinks will create the default target for the endpoint and, if
+// configured, the fallback_endpoint; absent an endpoint the library
+// default is used
+func (s *SignalFx) setSinks(cfg plugin.Config) {
+	endpoint, _ := getString(cfg, "endpoint")
+	endpoint = s.checkEndpoint("endpoint", endpoint)
+	s.endpoint = endpoint
+	s.targets = []*target{{
+		name: defaultTarget,
+		sink: s.newSink(endpoint, s.token),
+	}}
+	s.route = s.targets
+
+	fallback, err := s.getString(cfg, "fallback_endpoint")
+	if err != nil {
+		// No fallback_endpoint defined, moving on
+		return
+	}
+	if err := validateEndpoint(fallback); err != nil {
+		s.errorf("Ignoring fallback_endpoint: %v", err)
+		return
+	}
+	s.targets[0].fallback = s.newSink(fallback, s.token)
+
+	s.logf("Using fallback endpoint %s", fallback)
+}
+
+// setSeparateByType will send each metric type as its own request if the
+// separate_by_type config setting is present in the task file
+func (s *SignalFx) setSeparateByType(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "separate_by_type")
+	if err != nil || !enabled {
+		return
+	}
+	s.separateByType = true
+
+	s.logln("Sending each metric type as its own request")
+}
+
+// setGroupByHost will send each host's datapoints as its own request if
+// the group_by_host config setting is present in the task file
+func (s *SignalFx) setGroupByHost(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "group_by_host")
+	if err != nil || !enabled {
+		return
+	}
+	s.groupByHost = true
+
+	s.logln("Sending each host's datapoints as its own request")
+}
+
+// send - Sends the datapoints to the targets of the current route and/or
+// stdout
+func (s *SignalFx) send(dps ...*datapoint.Datapoint) {
+	// Use the metric's own timestamp and properties, if any
+	for _, dp := range dps {
+		if dp.Timestamp.IsZero() {
+			dp.Timestamp = s.timestamp
+		}
+		s.setProperties(dp)
+		s.limitProperties(dp)
+
+		// Number the datapoint when debugging ingest ordering
+		if s.debugSeq {
+			s.addSequence(dp)
+		}
+	}
+
+	// Remember the metric's values as sent once the datapoints are
+	s.awaitSend(dps)
+
+	// Drop datapoints too large to send or over their rate limit
+	if dps = s.dropOversized(dps); len(dps) == 0 {
+		return
+	}
+	if dps = s.dropThrottled(dps); len(dps) == 0 {
+		return
+	}
+
+	// Count the datapoints by type for the self metrics
+	if s.selfMetrics {
+		for _, dp := range dps {
+			s.typeCounts[dp.MetricType]++
+		}
+	}
+
+	// Only log the datapoints on a dry run
+	if s.dryRun {
+		s.logDatapoints(dps)
+		s.sentElsewhere(dps)
+		return
+	}
+
+	// Mirror the datapoints to statsd
+	if s.statsd != nil {
+		s.mirrorStatsd(dps)
+	}
+
+	// Write the datapoints to stdout
+	if s.output != outputSignalFx {
+		s.writeLines(dps)
+	}
+	if s.output == outputStdout {
+		s.sentElsewhere(dps)
+		return
+	}
+
+	// Hold the datapoints for a later publish, if accumulating, or until
+	// the end of this one
+	if s.accumulate(dps) {
+		return
+	}
+	s.addToBatch(dps)
+}
+
+// sentElsewhere remembers the values of the datapoints as sent when they
+// are only logged or written to stdout
+func (s *SignalFx) sentElsewhere(dps []*datapoint.Datapoint) {
+	s.mu.Lock()
+	s.rememberSent(dps, true)
+	s.mu.Unlock()
+}
+
+// deliver - Sends the datapoints to the targets of the route, within the
+// timeout, if any. Batches may be delivered at once, by worker_pool_size.
+func (s *SignalFx) deliver(route []*target, timeout time.Duration, dps []*datapoint.Datapoint) {
+	size := approximateSize(dps)
+	if !s.inflight.acquire(size) {
+		s.warnf("Dropping %d datapoints, %d in-flight bytes exceeded", len(dps), s.inflight.max)
+		atomic.AddInt64(&s.inflight.dropped, int64(len(dps)))
+		s.recordSend(dps, []error{errInflightFull})
+		return
+	}
+	defer s.inflight.release(size)
+
+	atomic.AddInt64(&s.pending, int64(len(dps)))
+	defer atomic.AddInt64(&s.pending, -int64(len(dps)))
+
+	ctx := s.sendContext()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Send each host and metric type as its own request, if configured
+	batches := [][]*datapoint.Datapoint{dps}
+	if s.groupByHost {
+		batches = groupByHost(dps)
+	}
+	if s.separateByType {
+		var byType [][]*datapoint.Datapoint
+		for _, batch := range batches {
+			byType = append(byType, groupByType(batch)...)
+		}
+		batches = byType
+	}
+
+	for _, batch := range batches {
+		s.recordSend(batch, s.fanOut(ctx, route, batch))
+	}
+}
+
+// recordSend counts the datapoints as sent, or as failed if any target
+// failed them, keeping the first and last failures of the publish
+func (s *SignalFx) recordSend(dps []*datapoint.Datapoint, errs []error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := false
+	for _, err := range errs {
+		if err != nil {
+			if s.firstErr == nil {
+				s.firstErr = err
+			}
+			s.lastErr = err
+			failed = true
+		}
+	}
+
+	if failed {
+		s.failed += len(dps)
+	} else {
+		s.sent += len(dps)
+	}
+	s.rememberSent(dps, !failed)
+}
+
+// groupByType splits the datapoints into one batch per metric type, in
+// the order the types first appear
+func groupByType(dps []*datapoint.Datapoint) [][]*datapoint.Datapoint {
+	return groupBy(dps, func(dp *datapoint.Datapoint) interface{} {
+		return dp.MetricType
+	})
+}
+
+// groupByHost splits the datapoints into one batch per host dimension, in
+// the order the hosts first appear
+func groupByHost(dps []*datapoint.Datapoint) [][]*datapoint.Datapoint {
+	return groupBy(dps, func(dp *datapoint.Datapoint) interface{} {
+		return dp.Dimensions["host"]
+	})
+}
+
+// groupBy splits the datapoints into one batch per key, in the order the
+// keys first appear
+func groupBy(dps []*datapoint.Datapoint, key func(*datapoint.Datapoint) interface{}) [][]*datapoint.Datapoint {
+	var batches [][]*datapoint.Datapoint
+	index := make(map[interface{}]int)
+
+	for _, dp := range dps {
+		k := key(dp)
+		i, ok := index[k]
+		if !ok {
+			i = len(batches)
+			index[k] = i
+			batches = append(batches, nil)
+		}
+		batches[i] = append(batches[i], dp)
+	}
+	return batches
+}
+
+// sendTo - Sends the datapoints to the target, retrying against its
+// fallback endpoint when the primary cannot be reached or returns a
+// server error. When tracking endpoint health, the healthier endpoint is
+// tried first.
+func (s *SignalFx) sendTo(ctx context.Context, t *target, dps []*datapoint.Datapoint) error {
+	first, second := t.sink, t.fallback
+	if second != nil && s.healthReset > 0 && t.health.preferFallback(s.now(), s.healthReset) {
+		s.debugf("Preferring %s, which has been healthier than %s", second.Endpoint, first.Endpoint)
+		first, second = second, first
+	}
+
+	err := s.sendToSink(ctx, t, first, dps)
+	if err == nil {
+		return nil
+	}
+
+	if second == nil || !isServerError(err) {
+		s.errorf("Failed to send datapoints to %s: %v", first.Endpoint, err)
+		return err
+	}
+
+	s.logf("Failed to send datapoints to %s, trying %s: %v", first.Endpoint, second.Endpoint, err)
+	if err := s.sendToSink(ctx, t, second, dps); err != nil {
+		s.errorf("Failed to send datapoints to %s: %v", second.Endpoint, err)
+		return err
+	}
+	return nil
+}
+
+// sendToSink - Sends the datapoints to one of the target's endpoints,
+// scoring its health when tracked
+func (s *SignalFx) sendToSink(ctx context.Context, t *target, sink *sfxclient.HTTPDatapointSink, dps []*datapoint.Datapoint) error {
+	err := s.addDatapoints(ctx, sink, dps)
+	if err != nil {
+		if rejected, ok := partialSuccess(err); ok {
+			s.warnf("%s rejected %d of %d datapoints", sink.Endpoint, rejected, len(dps))
+			err = nil
+		}
+	}
+
+	if t.fallback != nil && s.healthReset > 0 {
+		t.health.record(sink == t.fallback, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if sink == t.fallback {
+		s.logf("Sent %d datapoints to %s", len(dps), sink.Endpoint)
+	} else {
+		s.debugf("Sent %d datapoints to %s", len(dps), sink.Endpoint)
+	}
+	return nil
+}
+
+// newSink creates a SignalFx sink using the token; an empty endpoint keeps
+// the library default, and one without a path gets the api_version path
+func (s *SignalFx) newSink(endpoint, token string) *sfxclient.HTTPDatapointSink {
+	client := sfxclient.NewHTTPDatapointSink()
+	client.AuthToken = token
+	if s.publishTimeout > 0 {
+		client.Client.Timeout = s.publishTimeout
+	}
+	if endpoint != "" {
+		client.Endpoint = s.ingestURL(endpoint)
+	}
+
+	// Log the payload size of each request when debugging
+	client.Client.Transport = &payloadSizeLogger{
+		s:    s,
+		next: client.Client.Transport,
+	}
+
+	return client
+}
+
+// payloadSizeLogger - Logs the size of the serialized payload, before any
+// compression, as it is handed to the underlying transport, when the log
+// level is debug
+type payloadSizeLogger struct {
+	s    *SignalFx         // Plugin used for logging
+	next http.RoundTripper // Transport doing the actual work
+}
+
+// RoundTrip - Implements http.RoundTripper
+func (p *payloadSizeLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := p.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if p.s.logLevel > levelDebug || req.Body == nil {
+		return next.RoundTrip(req)
+	}
+
+	// Read the body to measure it, sending a copy of the request with it
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	size, err := payloadSize(body, req.Header.Get("Content-Encoding") == "gzip")
+	if err != nil {
+		p.s.debugf("Sending %d byte payload to %s, unable to measure it: %v", len(body), req.URL, err)
+	} else {
+		p.s.debugf("Sending %d byte payload (%d bytes on the wire) to %s", size, len(body), req.URL)
+	}
+	return next.RoundTrip(&r)
+}
+
+// payloadSize returns the size of the body once decompressed, if gzipped
+func payloadSize(body []byte, gzipped bool) (int64, error) {
+	if !gzipped {
+		return int64(len(body)), nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(ioutil.Discard, r)
+}
@@ -0,0 +1,298 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestFallbackEndpoint(t *testing.T) {
+	closed := statusServer(http.StatusOK)
+	closed.Close()
+	slow := slowServer()
+	defer slow.Close()
+	unavailable := statusServer(http.StatusServiceUnavailable)
+	defer unavailable.Close()
+	rejecting := statusServer(http.StatusBadRequest)
+	defer rejecting.Close()
+
+	tests := []struct {
+		name     string
+		primary  string
+		timeout  time.Duration
+		fallback bool
+	}{
+		{"connection refused", closed.URL, 0, true},
+		{"timeout", slow.URL, 50 * time.Millisecond, true},
+		{"server error", unavailable.URL, 0, true},
+		{"client error", rejecting.URL, 0, false},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		// Time out the primary's requests only
+		s := newTestPlugin()
+		s.setSinks(plugin.Config{
+			"endpoint":          tt.primary,
+			"fallback_endpoint": is.URL,
+		})
+		if tt.timeout > 0 {
+			s.targets[0].sink.Client.Timeout = tt.timeout
+		}
+		s.send(sfxclient.Gauge("snap.intel.cpu.idle", nil, 1))
+		s.flushBatches()
+		is.Close()
+
+		if got := is.requestCount() > 0; got != tt.fallback {
+			t.Errorf("%s: fallback endpoint used = %v, want %v", tt.name, got, tt.fallback)
+		}
+	}
+}
+
+func TestPayloadSize(t *testing.T) {
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	w.Write([]byte("hello, world"))
+	w.Close()
+
+	tests := []struct {
+		name    string
+		body    []byte
+		gzipped bool
+		want    int64
+		fails   bool
+	}{
+		{"plain", []byte("hello"), false, 5, false},
+		{"gzipped", gzipped.Bytes(), true, 12, false},
+		{"bad gzip", []byte("hello"), true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := payloadSize(tt.body, tt.gzipped)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: payloadSize returned %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: payloadSize = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPayloadSizeLogged(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   int
+		gzipped bool
+		logged  bool
+	}{
+		{"plain", levelDebug, false, true},
+		{"gzipped", levelDebug, true, true},
+		{"info", levelInfo, false, false},
+	}
+	for _, tt := range tests {
+		// Measure the payloads received
+		var sizes []int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				body, _ = gzip.NewReader(r.Body)
+			}
+			b, _ := ioutil.ReadAll(body)
+			sizes = append(sizes, len(b))
+		}))
+
+		payload := []byte(strings.Repeat("datapoint", 100))
+		var body bytes.Buffer
+		if tt.gzipped {
+			w := gzip.NewWriter(&body)
+			w.Write(payload)
+			w.Close()
+		} else {
+			body.Write(payload)
+		}
+		req, _ := http.NewRequest("POST", ts.URL, &body)
+		if tt.gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		var out bytes.Buffer
+		s := newTestPlugin()
+		s.SetLogger(log.New(&out, "", 0))
+		s.logLevel = tt.level
+		client := s.newSink("", "")
+		resp, err := client.Client.Transport.RoundTrip(req)
+		ts.Close()
+		if err != nil {
+			t.Errorf("%s: RoundTrip returned %v", tt.name, err)
+			continue
+		}
+		resp.Body.Close()
+
+		var logged []int
+		for _, l := range strings.Split(out.String(), "\n") {
+			var size int
+			if _, err := fmt.Sscanf(l, "DEBUG Sending %d byte payload", &size); err == nil {
+				logged = append(logged, size)
+			}
+		}
+
+		if !tt.logged {
+			if len(logged) > 0 {
+				t.Errorf("%s: logged payload sizes %v", tt.name, logged)
+			}
+			continue
+		}
+		if len(logged) != 1 || len(sizes) != 1 || logged[0] != sizes[0] {
+			t.Errorf("%s: logged %v for payloads of %v bytes", tt.name, logged, sizes)
+		}
+	}
+}
+
+func TestPayloadSizeFollowsLogLevel(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	var out bytes.Buffer
+	s := newTestPlugin()
+	s.SetLogger(log.New(&out, "", 0))
+	cfg := testConfig(is.URL, nil)
+	mts := []plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}
+
+	if err := s.Publish(mts, cfg); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	if strings.Contains(out.String(), "byte payload") {
+		t.Fatal("Payload size logged at info")
+	}
+
+	s.logLevel = levelDebug
+	if err := s.Publish(mts, cfg); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+	if !strings.Contains(out.String(), "byte payload") {
+		t.Error("Payload size not logged once the level is debug")
+	}
+}
+
+func TestSeparateByType(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		counter  bool
+		requests int
+	}{
+		{"mixed types", plugin.Config{"separate_by_type": true}, true, 2},
+		{"one type", plugin.Config{"separate_by_type": true}, false, 1},
+		{"disabled", plugin.Config{"separate_by_type": false}, true, 1},
+		{"absent", nil, true, 1},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		dps := []*datapoint.Datapoint{
+			sfxclient.Gauge("cpu.idle", nil, 1),
+			sfxclient.Gauge("cpu.user", nil, 3),
+		}
+		if tt.counter {
+			dps = append(dps, sfxclient.Cumulative("net.bytes", nil, 2))
+		} else {
+			dps = append(dps, sfxclient.Gauge("net.bytes", nil, 2))
+		}
+		s := newTestPlugin()
+		s.init(testConfig(is.URL, tt.settings))
+		s.send(dps...)
+		s.flushBatches()
+		is.Close()
+		if s.lastErr != nil {
+			t.Errorf("%s: send failed with %v", tt.name, s.lastErr)
+			continue
+		}
+
+		if n := is.requestCount(); n != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, n, tt.requests)
+		}
+		if n := len(is.received()); n != 3 {
+			t.Errorf("%s: %d datapoints sent, want 3", tt.name, n)
+		}
+	}
+}
+
+func TestGroupByHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		hosts    []string
+		requests int
+	}{
+		{"two hosts", plugin.Config{"group_by_host": true}, []string{"web1", "web2", "web1"}, 2},
+		{"one host", plugin.Config{"group_by_host": true}, []string{"web1", "web1"}, 1},
+		{"by host and type", plugin.Config{"group_by_host": true, "separate_by_type": true}, []string{"web1", "web2"}, 4},
+		{"disabled", plugin.Config{"group_by_host": false}, []string{"web1", "web2"}, 1},
+		{"absent", nil, []string{"web1", "web2"}, 1},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		var dps []*datapoint.Datapoint
+		for i, host := range tt.hosts {
+			dims := map[string]string{"host": host, "cpu": strconv.Itoa(i)}
+			dps = append(dps,
+				sfxclient.Gauge("cpu.idle", dims, int64(i)),
+				sfxclient.Cumulative("net.bytes", dims, int64(i)))
+		}
+		s := newTestPlugin()
+		s.init(testConfig(is.URL, tt.settings))
+		s.send(dps...)
+		s.flushBatches()
+		is.Close()
+		if s.lastErr != nil {
+			t.Errorf("%s: send failed with %v", tt.name, s.lastErr)
+			continue
+		}
+
+		if n := is.requestCount(); n != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, n, tt.requests)
+		}
+		is.mu.Lock()
+		n := len(is.datapoints)
+		is.mu.Unlock()
+		if n != len(dps) {
+			t.Errorf("%s: %d datapoints sent, want %d", tt.name, n, len(dps))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Default delimiter between the parts of split values
+const defaultSplitDelimiter = ","
+
+// splitRule - A namespace prefix whose string values hold several readings
+type splitRule struct {
+	prefix    string // Snap namespace prefix
+	dimension string // Dimension holding the index of each reading
+}
+
+// setSplitValue will parse the split_value setting; each entry is a
+// namespace prefix and the dimension holding the index of each reading,
+// e.g. "/intel/sensors/fans=fan". The readings are separated by
+// split_delimiter.
+func (s *SignalFx) setSplitValue(cfg plugin.Config) {
+	value, err := s.getString(cfg, "split_value")
+	if err != nil {
+		// No split_value defined, moving on
+		return
+	}
+
+	s.splitDelimiter = defaultSplitDelimiter
+	if delimiter, err := s.getString(cfg, "split_delimiter"); err == nil && delimiter != "" {
+		s.splitDelimiter = delimiter
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			s.logf("Ignoring split_value %q, expected prefix=dimension", entry)
+			continue
+		}
+
+		s.logf("Splitting %s values on %q by %s", parts[0], s.splitDelimiter, parts[1])
+		s.splits = append(s.splits, splitRule{prefix: parts[0], dimension: parts[1]})
+	}
+}
+
+// splitRuleFor returns the split rule matching the namespace, if any
+func (s *SignalFx) splitRuleFor(namespace string) (splitRule, bool) {
+	for _, rule := range s.splits {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule, true
+		}
+	}
+	return splitRule{}, false
+}
+
+// sendSplitValue sends each numeric reading of the value as its own
+// datapoint, with its index as a dimension; other readings are skipped
+func (s *SignalFx) sendSplitValue(rule splitRule, value string) {
+	dims := s.dimensions
+
+	for i, part := range strings.Split(value, s.splitDelimiter) {
+		part = strings.TrimSpace(part)
+
+		s.dimensions = make(map[string]string, len(dims)+1)
+		for k, v := range dims {
+			s.dimensions[k] = v
+		}
+		s.dimensions[rule.dimension] = strconv.Itoa(i)
+
+		n, _ := s.parseNumeric(part)
+		switch v := n.(type) {
+		case int64:
+			s.sendIntValue(v)
+		case float64:
+			s.sendFloatValue(v)
+		default:
+			s.logf("Skipping non-numeric reading %d of %s: %q", i, s.namespace, part)
+		}
+	}
+}
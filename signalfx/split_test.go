@@ -0,0 +1,70 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestSplitValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		value    string
+		readings map[string]string
+	}{
+		{"comma delimited", plugin.Config{"split_value": "/intel/sensors/fans=fan"},
+			"42,7,99", map[string]string{"0": "42", "1": "7", "2": "99"}},
+		{"floats and spaces", plugin.Config{"split_value": "/intel/sensors/fans=fan"},
+			"1.5, 2 ,3.25", map[string]string{"0": "1.5", "1": "2", "2": "3.25"}},
+		{"custom delimiter", plugin.Config{"split_value": "/intel/sensors/fans=fan", "split_delimiter": "|"},
+			"42|7", map[string]string{"0": "42", "1": "7"}},
+		{"non-numeric readings skipped", plugin.Config{"split_value": "/intel/sensors/fans=fan"},
+			"42,off,,99", map[string]string{"0": "42", "3": "99"}},
+		{"other namespace", plugin.Config{"split_value": "/intel/sensors/temps=probe"},
+			"42,7,99", map[string]string{}},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		mts := []plugin.Metric{newMetric(tt.value, "intel", "sensors", "fans", "rpm")}
+		if err := s.Publish(mts, testConfig(is.URL, tt.settings)); err != nil {
+			t.Fatalf("%s: Publish returned %v", tt.name, err)
+		}
+		is.Close()
+
+		readings := make(map[string]string)
+		for _, dp := range is.datapoints {
+			if fan, ok := dp.Dimensions["fan"]; ok && dp.Metric == "snap.intel.sensors.fans.rpm" {
+				readings[fan] = fmt.Sprint(dp.Value)
+			}
+		}
+		if !reflect.DeepEqual(readings, tt.readings) {
+			t.Errorf("%s: sent %v, want %v", tt.name, readings, tt.readings)
+		}
+	}
+}
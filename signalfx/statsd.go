@@ -0,0 +1,71 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Characters statsd reserves in metric names
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+
+// setStatsdMirror will also send every datapoint to the UDP statsd
+// address of the statsd_mirror_addr setting, e.g. a local aggregator
+func (s *SignalFx) setStatsdMirror(cfg plugin.Config) {
+	addr, err := s.getString(cfg, "statsd_mirror_addr")
+	if err != nil || addr == "" {
+		// No statsd_mirror_addr defined, moving on
+		return
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		s.warnf("Not mirroring to statsd: %v", err)
+		return
+	}
+	s.statsd = conn
+
+	s.logf("Mirroring datapoints to statsd at %s", addr)
+}
+
+// mirrorStatsd sends the datapoints to the statsd mirror, one packet
+// each; failures are only logged so they never affect the publish
+func (s *SignalFx) mirrorStatsd(dps []*datapoint.Datapoint) {
+	for _, dp := range dps {
+		if _, err := s.statsd.Write([]byte(formatStatsd(dp))); err != nil {
+			s.debugf("Failed to mirror %s to statsd: %v", dp.Metric, err)
+		}
+	}
+}
+
+// formatStatsd formats the datapoint as a statsd line, <metric>:<value>|<type>,
+// sending deltas as counters and everything else as gauges
+func formatStatsd(dp *datapoint.Datapoint) string {
+	kind := "g"
+	if dp.MetricType == datapoint.Count {
+		kind = "c"
+	}
+	return fmt.Sprintf("%s:%s|%s", statsdReplacer.Replace(dp.Metric), dp.Value, kind)
+}
@@ -0,0 +1,131 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestFormatStatsd(t *testing.T) {
+	tests := []struct {
+		name string
+		dp   *datapoint.Datapoint
+		line string
+	}{
+		{"gauge", datapoint.New("snap.intel.cpu.idle", nil, datapoint.NewFloatValue(97.5), datapoint.Gauge, time.Time{}),
+			"snap.intel.cpu.idle:97.5|g"},
+		{"delta counter", datapoint.New("snap.intel.net.bytes", nil, datapoint.NewIntValue(10), datapoint.Count, time.Time{}),
+			"snap.intel.net.bytes:10|c"},
+		{"cumulative counter", datapoint.New("snap.intel.net.total", nil, datapoint.NewIntValue(100), datapoint.Counter, time.Time{}),
+			"snap.intel.net.total:100|g"},
+		{"reserved characters", datapoint.New("snap.a:b|c@d", nil, datapoint.NewIntValue(1), datapoint.Gauge, time.Time{}),
+			"snap.a_b_c_d:1|g"},
+	}
+	for _, tt := range tests {
+		if got := formatStatsd(tt.dp); got != tt.line {
+			t.Errorf("%s: formatStatsd = %q, want %q", tt.name, got, tt.line)
+		}
+	}
+}
+
+func TestStatsdMirror(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	err = s.Publish([]plugin.Metric{
+		newMetric(int64(1), "intel", "cpu", "idle"),
+		newMetric(2.5, "intel", "load", "load1"),
+	}, testConfig(is.URL, plugin.Config{"statsd_mirror_addr": pc.LocalAddr().String()}))
+	if err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	var packets []string
+	buf := make([]byte, 1024)
+	for len(packets) < 2 {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Received %q, then %v", packets, err)
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+	sort.Strings(packets)
+
+	want := []string{"snap.intel.cpu.idle:1|g", "snap.intel.load.load1:2.5|g"}
+	for i := range want {
+		if packets[i] != want[i] {
+			t.Errorf("Mirrored %q, want %q", packets, want)
+			break
+		}
+	}
+	if len(is.received()) != 2 {
+		t.Errorf("%d datapoints sent to SignalFx, want 2", len(is.received()))
+	}
+}
+
+func TestStatsdMirrorFailure(t *testing.T) {
+	// Nothing listens on the closed port, so the mirror's writes fail
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	tests := []struct {
+		name string
+		addr string
+	}{
+		{"unreachable", addr},
+		{"unresolvable", "no-such-host.invalid:8125"},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		cfg := testConfig(is.URL, plugin.Config{"statsd_mirror_addr": tt.addr})
+		for i := 0; i < 2; i++ {
+			if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+		}
+		is.Close()
+
+		if n := is.requestCount(); n != 2 {
+			t.Errorf("%s: %d requests to SignalFx, want 2", tt.name, n)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Outputs
+const (
+	outputSignalFx = "signalfx" // Send to SignalFx
+	outputStdout   = "stdout"   // Write lines to stdout
+	outputBoth     = "both"     // Both of the above
+)
+
+// setOutput will set where datapoints go from the output setting,
+// defaulting to SignalFx
+func (s *SignalFx) setOutput(cfg plugin.Config) {
+	s.output = outputSignalFx
+
+	output, err := s.getString(cfg, "output")
+	if err != nil {
+		// No output defined, moving on
+		return
+	}
+
+	switch output {
+	case outputSignalFx, outputStdout, outputBoth:
+		s.output = output
+	default:
+		s.logf("Unknown output %q, using %s", output, outputSignalFx)
+		return
+	}
+
+	s.logf("Sending datapoints to %s", s.output)
+}
+
+// writeLines writes the datapoints to stdout, one per line, as
+// <metric> <key>=<value>,... <value> <timestamp in ms>
+func (s *SignalFx) writeLines(dps []*datapoint.Datapoint) {
+	for _, dp := range dps {
+		fmt.Fprintln(s.stdout, formatLine(dp, s.now()))
+	}
+}
+
+// formatLine formats the datapoint as a line, using now for datapoints
+// without a timestamp
+func formatLine(dp *datapoint.Datapoint, now time.Time) string {
+	keys := make([]string, 0, len(dp.Dimensions))
+	for k := range dp.Dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dims := make([]string, 0, len(keys))
+	for _, k := range keys {
+		dims = append(dims, k+"="+dp.Dimensions[k])
+	}
+
+	ts := dp.Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+
+	return fmt.Sprintf("%s %s %s %d", dp.Metric, strings.Join(dims, ","), dp.Value,
+		ts.UnixNano()/int64(time.Millisecond))
+}
@@ -0,0 +1,91 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestFormatLine(t *testing.T) {
+	now := time.Unix(1496318400, 0)
+	at := time.Unix(1496318000, 500*int64(time.Millisecond))
+
+	tests := []struct {
+		dp   *datapoint.Datapoint
+		line string
+	}{
+		{datapoint.New("snap.intel.cpu.idle", map[string]string{"host": "h1", "cpu": "0"},
+			datapoint.NewIntValue(42), datapoint.Gauge, at),
+			"snap.intel.cpu.idle cpu=0,host=h1 42 1496318000500"},
+		{datapoint.New("snap.intel.cpu.load", nil, datapoint.NewFloatValue(1.5), datapoint.Gauge, time.Time{}),
+			"snap.intel.cpu.load  1.5 1496318400000"},
+	}
+	for _, tt := range tests {
+		if got := formatLine(tt.dp, now); got != tt.line {
+			t.Errorf("formatLine(%v) = %q, want %q", tt.dp, got, tt.line)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	now := time.Unix(1496318400, 0)
+
+	tests := []struct {
+		output string
+		line   string
+		sent   bool
+	}{
+		{outputStdout, "snap.intel.cpu.idle host=h1 42 1496318400000\n", false},
+		{outputBoth, "snap.intel.cpu.idle host=h1 42 1496318400000\n", true},
+		{outputSignalFx, "", true},
+		{"pipe", "", true},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		var out bytes.Buffer
+		s := newTestPlugin()
+		s.stdout = &out
+		s.now = func() time.Time { return now }
+		err := s.Publish([]plugin.Metric{newMetric(int64(42), "intel", "cpu", "idle")}, testConfig(is.URL, plugin.Config{
+			"output":   tt.output,
+			"hostname": "h1",
+		}))
+		is.Close()
+
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.output, err)
+		}
+		if out.String() != tt.line {
+			t.Errorf("%s: wrote %q, want %q", tt.output, out.String(), tt.line)
+		}
+		if sent := is.requestCount() > 0; sent != tt.sent {
+			t.Errorf("%s: sent to SignalFx = %v, want %v", tt.output, sent, tt.sent)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Earliest timestamp accepted from the sfx_timestamp tag
+var minTagTimestamp = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Latest timestamp accepted from the sfx_timestamp tag, relative to now
+const maxTagTimestampSkew = 24 * time.Hour
+
+// Metric tags controlling how a metric is sent
+const (
+	tagTimeout   = "sfx_timeout"   // Send timeout in milliseconds
+	tagTimestamp = "sfx_timestamp" // Datapoint time in epoch milliseconds
+)
+
+// Metric tag setting the metric type: gauge, or counter (or
+// cumulative_counter) for a cumulative counter
+const tagMetricType = "sfx_metric_type"
+
+// timeoutFromTags returns the send timeout from the sfx_timeout tag, or zero
+// when the tag is absent or invalid
+func (s *SignalFx) timeoutFromTags(tags map[string]string) time.Duration {
+	value, ok := tags[tagTimeout]
+	if !ok {
+		return 0
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		s.logf("Ignoring invalid %s tag %q", tagTimeout, value)
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// timestampFromTags returns the datapoint time from the sfx_timestamp tag,
+// or the zero time when the tag is absent or not a plausible timestamp
+func (s *SignalFx) timestampFromTags(tags map[string]string) time.Time {
+	value, ok := tags[tagTimestamp]
+	if !ok {
+		return time.Time{}
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		s.logf("Ignoring invalid %s tag %q", tagTimestamp, value)
+		return time.Time{}
+	}
+
+	t := time.Unix(0, ms*int64(time.Millisecond))
+	if t.Before(minTagTimestamp) || t.After(s.now().Add(maxTagTimestampSkew)) {
+		s.logf("Ignoring implausible %s tag %q", tagTimestamp, value)
+		return time.Time{}
+	}
+	return t
+}
+
+// tagDimensions returns the metric tags to send as dimensions, leaving out
+// the tags controlling how the metric is sent, the host tag, since the
+// explicit host always wins, and tags with an empty key or value. Like the
+// sink, runes SignalFx does not allow in keys are replaced with '_'.
+func (s *SignalFx) tagDimensions(tags map[string]string) map[string]string {
+	dims := make(map[string]string, len(tags))
+	for k, v := range tags {
+		switch k {
+		case tagTimeout, tagTimestamp, tagMetricType, "host":
+			continue
+		}
+		if k == "" || v == "" {
+			s.debugf("Not sending tag %q=%q as a dimension", k, v)
+			continue
+		}
+		dims[strings.Map(dimensionKeyRune, k)] = v
+	}
+	return dims
+}
+
+// dimensionKeyRune returns the rune, or '_' for runes SignalFx does not
+// allow in dimension keys
+func dimensionKeyRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+	case r == '_', r == '-':
+	default:
+		return '_'
+	}
+	return r
+}
+
+// setRejectFuture will drop metrics timestamped in the future if the
+// reject_future_timestamps config setting is present in the task file
+func (s *SignalFx) setRejectFuture(cfg plugin.Config) {
+	enabled, err := s.getBool(cfg, "reject_future_timestamps")
+	if err != nil || !enabled {
+		return
+	}
+	s.rejectFuture = true
+
+	if ms, err := s.getInt(cfg, "future_tolerance"); err == nil && ms > 0 {
+		s.futureTolerance = time.Duration(ms) * time.Millisecond
+	}
+
+	s.logf("Dropping metrics timestamped more than %v in the future", s.futureTolerance)
+}
+
+// futureTimestamp reports whether the metric is timestamped after now plus
+// the tolerance, by its sfx_timestamp tag or else its own timestamp,
+// counting it as dropped when it is
+func (s *SignalFx) futureTimestamp(m plugin.Metric) bool {
+	if !s.rejectFuture {
+		return false
+	}
+
+	// Check the tag even when too far ahead to be used as the timestamp
+	at := m.Timestamp
+	if value, ok := m.Tags[tagTimestamp]; ok {
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			at = time.Unix(0, ms*int64(time.Millisecond))
+		}
+	}
+	if at.IsZero() || !at.After(s.now().Add(s.futureTolerance)) {
+		return false
+	}
+
+	s.futureDropped++
+	s.countFiltered(filterFutureTimestamp)
+	s.debugf("Dropping %s timestamped in the future at %v", s.namespace, at)
+	return true
+}
@@ -0,0 +1,257 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// epochMillis returns the time as a sfx_timestamp tag value
+func epochMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+func TestTimeoutFromTags(t *testing.T) {
+	tests := []struct {
+		tags    map[string]string
+		timeout time.Duration
+	}{
+		{nil, 0},
+		{map[string]string{tagTimeout: "250"}, 250 * time.Millisecond},
+		{map[string]string{tagTimeout: "0"}, 0},
+		{map[string]string{tagTimeout: "-5"}, 0},
+		{map[string]string{tagTimeout: "1s"}, 0},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		if got := s.timeoutFromTags(tt.tags); got != tt.timeout {
+			t.Errorf("timeoutFromTags(%v) = %v, want %v", tt.tags, got, tt.timeout)
+		}
+	}
+}
+
+func TestTimeoutTagApplied(t *testing.T) {
+	slow := slowServer()
+	defer slow.Close()
+
+	m := newMetric(int64(1), "intel", "cpu", "idle")
+	m.Tags = map[string]string{tagTimeout: "50"}
+
+	s := newTestPlugin()
+	start := time.Now()
+	if err := s.Publish([]plugin.Metric{m}, testConfig(slow.URL, nil)); err == nil {
+		t.Error("Publish to a slow server succeeded")
+	}
+
+	if s.lastErr == nil || classifyError(s.lastErr) != errorTimeout {
+		t.Errorf("Last error %v, want a timeout", s.lastErr)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Publish took %v with a 50ms sfx_timeout", elapsed)
+	}
+}
+
+func TestTimeoutTagBatches(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	metric := func(name, timeout string) plugin.Metric {
+		m := newMetric(int64(1), "intel", "cpu", name)
+		if timeout != "" {
+			m.Tags = map[string]string{tagTimeout: timeout}
+		}
+		return m
+	}
+
+	s := newTestPlugin()
+	err := s.Publish([]plugin.Metric{
+		metric("idle", ""),
+		metric("user", "100"),
+		metric("system", ""),
+		metric("nice", "100"),
+		metric("steal", "200"),
+	}, testConfig(is.URL, nil))
+	if err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	if n := is.requestCount(); n != 3 {
+		t.Errorf("%d requests, want one per timeout", n)
+	}
+	if n := len(is.received()); n != 5 {
+		t.Errorf("%d metrics sent, want 5", n)
+	}
+}
+
+func TestTimestampFromTags(t *testing.T) {
+	at := time.Date(2017, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		timestamp time.Time
+	}{
+		{"valid", map[string]string{tagTimestamp: epochMillis(at)}, at},
+		{"absent", nil, time.Time{}},
+		{"malformed", map[string]string{tagTimestamp: "yesterday"}, time.Time{}},
+		{"seconds", map[string]string{tagTimestamp: "1496318400"}, time.Time{}},
+		{"far future", map[string]string{tagTimestamp: epochMillis(at.Add(48 * time.Hour))}, time.Time{}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		s.now = func() time.Time { return at }
+		if got := s.timestampFromTags(tt.tags); !got.Equal(tt.timestamp) {
+			t.Errorf("%s: timestampFromTags(%v) = %v, want %v", tt.name, tt.tags, got, tt.timestamp)
+		}
+	}
+}
+
+func TestTimestampTag(t *testing.T) {
+	at := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	collected := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	tests := []struct {
+		name      string
+		tag       string
+		timestamp time.Time
+		want      time.Time
+	}{
+		{"overrides the metric time", epochMillis(at), collected, at},
+		{"overrides now", epochMillis(at), time.Time{}, at},
+		{"malformed", "soon", collected, time.Time{}},
+	}
+	for _, tt := range tests {
+		m := newMetric(int64(1), "intel", "cpu", "idle")
+		m.Timestamp = tt.timestamp
+		m.Tags = map[string]string{tagTimestamp: tt.tag}
+
+		dp, ok := publish(t, nil, m)["snap.intel.cpu.idle"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		// Without the tag, SignalFx timestamps the datapoint on receipt
+		var want int64
+		if !tt.want.IsZero() {
+			want = tt.want.UnixNano() / int64(time.Millisecond)
+		}
+		if dp.Timestamp != want {
+			t.Errorf("%s: sent at %d, want %d", tt.name, dp.Timestamp, want)
+		}
+		if _, ok := dp.Dimensions[tagTimestamp]; ok {
+			t.Errorf("%s: sent the tag as a dimension", tt.name)
+		}
+	}
+}
+
+func TestRejectFutureTimestamps(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		tag       string
+		settings  plugin.Config
+		dropped   bool
+	}{
+		{"future metric timestamp", now.Add(time.Hour), "", nil, true},
+		{"future tag", time.Time{}, epochMillis(now.Add(time.Hour)), nil, true},
+		{"tag beyond a day ahead", time.Time{}, epochMillis(now.Add(48 * time.Hour)), nil, true},
+		{"tag overriding the metric", now.Add(time.Hour), epochMillis(now.Add(-time.Hour)), nil, false},
+		{"within the tolerance", now.Add(time.Minute), "", plugin.Config{"future_tolerance": int64(time.Hour / time.Millisecond)}, false},
+		{"past", now.Add(-time.Hour), "", nil, false},
+		{"no timestamp", time.Time{}, "", nil, false},
+		{"disabled", now.Add(time.Hour), "", plugin.Config{"reject_future_timestamps": false}, false},
+	}
+	for _, tt := range tests {
+		m := newMetric(int64(1), "intel", "cpu", "idle")
+		m.Timestamp = tt.timestamp
+		if tt.tag != "" {
+			m.Tags = map[string]string{tagTimestamp: tt.tag}
+		}
+
+		settings := plugin.Config{"reject_future_timestamps": true}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+
+		_, sent := publish(t, settings, m)["snap.intel.cpu.idle"]
+		if sent == tt.dropped {
+			t.Errorf("%s: sent = %v, want %v", tt.name, sent, !tt.dropped)
+		}
+	}
+}
+
+func TestTagDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		dims map[string]string
+	}{
+		{"several tags", map[string]string{"plugin_running_on": "web1", "region": "us-east", "team": "ops"},
+			map[string]string{"plugin_running_on": "web1", "region": "us-east", "team": "ops"}},
+		{"invalid runes", map[string]string{"data.center": "east", "rack/row": "r1"},
+			map[string]string{"data_center": "east", "rack_row": "r1"}},
+		{"empty key or value", map[string]string{"": "x", "region": ""}, map[string]string{}},
+		{"host", map[string]string{"host": "other"}, map[string]string{}},
+		{"plugin tags", map[string]string{tagTimeout: "5s", tagTimestamp: "1500000000000", tagMetricType: "counter"},
+			map[string]string{}},
+		{"no tags", nil, map[string]string{}},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		if got := s.tagDimensions(tt.tags); !reflect.DeepEqual(got, tt.dims) {
+			t.Errorf("%s: tagDimensions(%v) = %v, want %v", tt.name, tt.tags, got, tt.dims)
+		}
+	}
+}
+
+func TestTagDimensionsSent(t *testing.T) {
+	m := newMetric(int64(1), "intel", "cpu", "idle")
+	m.Tags = map[string]string{
+		"plugin_running_on": "web1",
+		"region":            "us-east",
+		"data.center":       "east",
+		"host":              "other",
+	}
+	dp, ok := publish(t, plugin.Config{"hostname": "web2"}, m)["snap.intel.cpu.idle"]
+	if !ok {
+		t.Fatal("Nothing was sent")
+	}
+	want := map[string]string{
+		"plugin_running_on": "web1",
+		"region":            "us-east",
+		"data_center":       "east",
+		"host":              "web2", // The explicit host wins
+	}
+	for k, v := range want {
+		if dp.Dimensions[k] != v {
+			t.Errorf("Dimension %s = %q, want %q", k, dp.Dimensions[k], v)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+	"sync"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+	"golang.org/x/net/context"
+)
+
+// Name of the target built from the endpoint setting
+const defaultTarget = "default"
+
+// Most targets built from metric config, beyond which metrics use the
+// publish config
+const maxOverrideTargets = 100
+
+// setTargets will add the targets from the targets setting; each entry is
+// a name, endpoint, and optional token, e.g.
+// "eu=https://ingest.eu0.signalfx.com/v2/datapoint;ABCD1234". Targets
+// without a token use the token setting. Unless route_rules are defined,
+// datapoints are sent to every target.
+func (s *SignalFx) setTargets(cfg plugin.Config) {
+	value, err := s.getString(cfg, "targets")
+	if err == nil {
+		for _, entry := range splitList(value) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				s.logf("Ignoring target %q, expected name=endpoint[;token]", entry)
+				continue
+			}
+
+			endpoint, token := parts[1], ""
+			if i := strings.Index(endpoint, ";"); i >= 0 {
+				endpoint, token = endpoint[:i], endpoint[i+1:]
+			}
+			if err := validateEndpoint(endpoint); err != nil {
+				s.errorf("Ignoring target %s: %v", parts[0], err)
+				continue
+			}
+
+			s.logf("Adding target %s at %s", parts[0], endpoint)
+			t := &target{name: parts[0], token: token}
+			t.sink = s.newSink(endpoint, s.tokenFor(t))
+			s.targets = append(s.targets, t)
+		}
+	}
+
+	// Never run more sends at once than there are targets
+	s.concurrency = len(s.targets)
+	if n, err := s.getInt(cfg, "fanout_concurrency"); err == nil && n > 0 && int(n) < s.concurrency {
+		s.concurrency = int(n)
+	}
+}
+
+// tokenFor returns the token the target sends with
+func (s *SignalFx) tokenFor(t *target) string {
+	if t.token != "" {
+		return t.token
+	}
+	return s.token
+}
+
+// routeRule - A namespace prefix whose datapoints go to a target
+type routeRule struct {
+	prefix string  // Snap namespace prefix
+	target *target // Target datapoints go to
+}
+
+// setRouteRules will parse the route_rules setting; each entry is a
+// namespace prefix and the name of a target, e.g.
+// "/intel/procfs=eu,/intel/psutil=default". Unmatched metrics go to the
+// route_default target, or the default target when absent.
+func (s *SignalFx) setRouteRules(cfg plugin.Config) {
+	value, err := s.getString(cfg, "route_rules")
+	if err != nil {
+		// No route_rules defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring route %q, expected prefix=target", entry)
+			continue
+		}
+
+		t := s.targetNamed(parts[1])
+		if t == nil {
+			s.logf("Ignoring route %q, unknown target %q", entry, parts[1])
+			continue
+		}
+
+		s.logf("Routing %s to %s", parts[0], t.name)
+		s.routes = append(s.routes, routeRule{prefix: parts[0], target: t})
+	}
+
+	s.defaultRoute = s.targets[0]
+	if name, err := s.getString(cfg, "route_default"); err == nil {
+		if t := s.targetNamed(name); t != nil {
+			s.defaultRoute = t
+		} else {
+			s.logf("Unknown route_default %q, using %s", name, defaultTarget)
+		}
+	}
+}
+
+// targetNamed returns the target with the name, if any
+func (s *SignalFx) targetNamed(name string) *target {
+	for _, t := range s.targets {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// routeFor returns the targets for the namespace; without route rules,
+// that is every target
+func (s *SignalFx) routeFor(namespace string) []*target {
+	if len(s.routes) == 0 {
+		return s.targets
+	}
+
+	for _, rule := range s.routes {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return []*target{rule.target}
+		}
+	}
+	return []*target{s.defaultRoute}
+}
+
+// metricRoute returns the targets for the metric. A metric carrying its
+// own token or endpoint config is sent to a target built from it, falling
+// back to the publish config for whichever is absent, and for all of it
+// when the endpoint is invalid or there are too many such targets.
+func (s *SignalFx) metricRoute(m plugin.Metric) []*target {
+	token, tokenErr := getString(m.Config, "token")
+	endpoint, endpointErr := getString(m.Config, "endpoint")
+	if tokenErr != nil && endpointErr != nil {
+		return s.routeFor(m.Namespace.String())
+	}
+
+	if tokenErr != nil {
+		token = s.token
+	}
+	if endpointErr != nil {
+		endpoint = s.targets[0].sink.Endpoint
+	}
+
+	t := s.overrideTarget(endpoint, token)
+	if t == nil {
+		return s.routeFor(m.Namespace.String())
+	}
+	return []*target{t}
+}
+
+// overrideTarget returns the target for a metric's own endpoint and token,
+// creating it the first time with the fallback_endpoint, if any, as its
+// fallback. It returns nil for an invalid endpoint, logged the first time,
+// and for new targets once there are maxOverrideTargets.
+func (s *SignalFx) overrideTarget(endpoint, token string) *target {
+	key := endpoint + "\x00" + token
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.overrides == nil {
+		s.overrides = make(map[string]*target)
+	}
+
+	if t, ok := s.overrides[key]; ok {
+		return t
+	}
+	if len(s.overrides) >= maxOverrideTargets {
+		s.debugf("Over %d targets for metric config, using the publish config for %s", maxOverrideTargets, endpoint)
+		return nil
+	}
+
+	// Remember invalid endpoints too, so they are only logged once
+	var t *target
+	if err := validateEndpoint(endpoint); err != nil {
+		s.errorf("Ignoring metric config, using the publish config: %v", err)
+	} else {
+		s.logf("Adding target for metric config at %s", endpoint)
+		t = &target{name: endpoint, sink: s.newSink(endpoint, token)}
+		if fallback := s.targets[0].fallback; fallback != nil {
+			t.fallback = s.newSink(fallback.Endpoint, token)
+		}
+	}
+	s.overrides[key] = t
+
+	if len(s.overrides) == maxOverrideTargets {
+		s.warnf("Reached %d targets for metric config, further ones use the publish config", maxOverrideTargets)
+	}
+	return t
+}
+
+// fanOut sends the datapoints to the targets concurrently, at most
+// concurrency at a time, returning the error of each target
+func (s *SignalFx) fanOut(ctx context.Context, targets []*target, dps []*datapoint.Datapoint) []error {
+	errs := make([]error, len(targets))
+	if len(targets) == 1 {
+		errs[0] = s.sendTo(ctx, targets[0], dps)
+		return errs
+	}
+
+	concurrency := s.concurrency
+	if concurrency <= 0 || concurrency > len(targets) {
+		concurrency = len(targets)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, t *target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = s.sendTo(ctx, t, dps)
+		}(i, t)
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,370 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// concurrencyServer - A server recording the most requests it handled at
+// once, each taking a while
+type concurrencyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	active   int
+	max      int
+	requests int
+}
+
+// newConcurrencyServer starts a concurrency server
+func newConcurrencyServer() *concurrencyServer {
+	cs := &concurrencyServer{}
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		cs.active++
+		cs.requests++
+		if cs.active > cs.max {
+			cs.max = cs.active
+		}
+		cs.mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		cs.mu.Lock()
+		cs.active--
+		cs.mu.Unlock()
+		io.WriteString(w, `"OK"`)
+	}))
+	return cs
+}
+
+func TestFanOutConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency interface{}
+		max         int
+	}{
+		{"default", nil, 3},
+		{"bounded", int64(1), 1},
+		{"capped at the targets", int64(5), 3},
+	}
+	for _, tt := range tests {
+		cs := newConcurrencyServer()
+
+		settings := plugin.Config{"targets": "a=" + cs.URL + ",b=" + cs.URL}
+		if tt.concurrency != nil {
+			settings["fanout_concurrency"] = tt.concurrency
+		}
+		s := newTestPlugin()
+		if err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, testConfig(cs.URL, settings)); err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+		cs.Close()
+
+		if cs.requests != 3 {
+			t.Errorf("%s: %d requests, want one per target", tt.name, cs.requests)
+		}
+		if cs.max != tt.max {
+			t.Errorf("%s: %d sends at once, want %d", tt.name, cs.max, tt.max)
+		}
+	}
+}
+
+func TestFanOutSlowTarget(t *testing.T) {
+	fast := newIngestServer()
+	defer fast.Close()
+	slow := slowServer()
+	defer slow.Close()
+
+	// Time out the slow target's requests only
+	s := newTestPlugin()
+	cfg := testConfig(fast.URL, plugin.Config{"targets": "slow=" + slow.URL})
+	s.init(cfg)
+	s.targets[1].sink.Client.Timeout = 200 * time.Millisecond
+
+	start := time.Now()
+	err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg)
+
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("Publish took %v, held up by the slow target", elapsed)
+	}
+	if err == nil {
+		t.Error("Publish returned no error for the slow target")
+	}
+	if fast.requestCount() == 0 {
+		t.Error("The fast target was not sent to")
+	}
+}
+
+func TestRouteRules(t *testing.T) {
+	main := newIngestServer()
+	defer main.Close()
+	eu := newIngestServer()
+	defer eu.Close()
+	us := newIngestServer()
+	defer us.Close()
+
+	servers := map[string]*ingestServer{"default": main, "eu": eu, "us": us}
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		routes   map[string]string // Metric name to the target it reaches
+	}{
+		{"every target without rules", nil, map[string]string{}},
+		{"two namespaces", plugin.Config{
+			"route_rules": "/intel/procfs=eu,/intel/psutil=us",
+		}, map[string]string{
+			"snap.intel.procfs.load": "eu",
+			"snap.intel.psutil.load": "us",
+			"snap.intel.cpu.idle":    "default",
+		}},
+		{"route_default", plugin.Config{
+			"route_rules":   "/intel/procfs=eu",
+			"route_default": "us",
+		}, map[string]string{
+			"snap.intel.procfs.load": "eu",
+			"snap.intel.psutil.load": "us",
+			"snap.intel.cpu.idle":    "us",
+		}},
+		{"unknown target ignored", plugin.Config{
+			"route_rules": "/intel/procfs=ap,/intel/psutil=us",
+		}, map[string]string{
+			"snap.intel.procfs.load": "default",
+			"snap.intel.psutil.load": "us",
+			"snap.intel.cpu.idle":    "default",
+		}},
+	}
+	for _, tt := range tests {
+		for _, is := range servers {
+			is.mu.Lock()
+			is.datapoints = nil
+			is.mu.Unlock()
+		}
+
+		settings := plugin.Config{"targets": "eu=" + eu.URL + ";EU1234,us=" + us.URL}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{
+			newMetric(int64(1), "intel", "procfs", "load"),
+			newMetric(int64(2), "intel", "psutil", "load"),
+			newMetric(int64(3), "intel", "cpu", "idle"),
+		}, testConfig(main.URL, settings))
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		for target, is := range servers {
+			received := is.received()
+			for _, metric := range []string{"snap.intel.procfs.load", "snap.intel.psutil.load", "snap.intel.cpu.idle"} {
+				want, routed := tt.routes[metric]
+				dp, ok := received[metric]
+				if ok != (!routed || want == target) {
+					t.Errorf("%s: %s sent to %s = %v", tt.name, metric, target, ok)
+				}
+				if ok && target == "eu" && dp.Token != "EU1234" {
+					t.Errorf("%s: %s sent to eu with token %q", tt.name, metric, dp.Token)
+				}
+			}
+		}
+	}
+}
+
+func TestMetricConfig(t *testing.T) {
+	main := newIngestServer()
+	defer main.Close()
+	override := newIngestServer()
+	defer override.Close()
+
+	tests := []struct {
+		name   string
+		config plugin.Config
+		server *ingestServer
+		token  string
+	}{
+		{"no config", nil, main, "ABCD1234"},
+		{"unrelated config", plugin.Config{"interval": "10s"}, main, "ABCD1234"},
+		{"endpoint", plugin.Config{"endpoint": override.URL}, override, "ABCD1234"},
+		{"token", plugin.Config{"token": "OWN"}, main, "OWN"},
+		{"endpoint and token", plugin.Config{"endpoint": override.URL, "token": "OWN"}, override, "OWN"},
+		{"invalid endpoint", plugin.Config{"endpoint": "ftp://ingest", "token": "OWN"}, main, "ABCD1234"},
+	}
+	for _, tt := range tests {
+		for _, is := range []*ingestServer{main, override} {
+			is.mu.Lock()
+			is.datapoints = nil
+			is.mu.Unlock()
+		}
+
+		overridden := newMetric(int64(1), "intel", "cpu", "user")
+		overridden.Config = tt.config
+
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{
+			newMetric(int64(2), "intel", "cpu", "idle"),
+			overridden,
+		}, testConfig(main.URL, nil))
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		if _, ok := main.received()["snap.intel.cpu.idle"]; !ok {
+			t.Errorf("%s: the metric without config was not sent to the publish endpoint", tt.name)
+		}
+		dp, ok := tt.server.received()["snap.intel.cpu.user"]
+		if !ok {
+			t.Errorf("%s: the metric with config was not sent to its endpoint", tt.name)
+			continue
+		}
+		if dp.Token != tt.token {
+			t.Errorf("%s: sent with token %s, want %s", tt.name, dp.Token, tt.token)
+		}
+		if tt.server != override {
+			if _, ok := override.received()["snap.intel.cpu.user"]; ok {
+				t.Errorf("%s: sent to the override endpoint", tt.name)
+			}
+		}
+	}
+}
+
+func TestMetricConfigFallback(t *testing.T) {
+	fallback := newIngestServer()
+	defer fallback.Close()
+	failing := statusServer(http.StatusServiceUnavailable)
+	defer failing.Close()
+
+	m := newMetric(int64(1), "intel", "cpu", "idle")
+	m.Config = plugin.Config{"endpoint": failing.URL, "token": "OWN"}
+
+	s := newTestPlugin()
+	if err := s.Publish([]plugin.Metric{m}, testConfig(failing.URL, plugin.Config{
+		"fallback_endpoint": fallback.URL,
+	})); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	dp, ok := fallback.received()["snap.intel.cpu.idle"]
+	if !ok {
+		t.Fatal("The metric was not sent to the fallback_endpoint")
+	}
+	if dp.Token != "OWN" {
+		t.Errorf("Sent with token %s, want the metric's own", dp.Token)
+	}
+}
+
+func TestMetricConfigBounded(t *testing.T) {
+	main := newIngestServer()
+	defer main.Close()
+	override := newIngestServer()
+	defer override.Close()
+
+	s := newTestPlugin()
+	s.overrides = make(map[string]*target)
+	for i := 0; i < maxOverrideTargets; i++ {
+		s.overrides[strconv.Itoa(i)] = nil
+	}
+
+	m := newMetric(int64(1), "intel", "cpu", "idle")
+	m.Config = plugin.Config{"endpoint": override.URL}
+	if err := s.Publish([]plugin.Metric{m}, testConfig(main.URL, nil)); err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	if _, ok := main.received()["snap.intel.cpu.idle"]; !ok {
+		t.Error("The metric was not sent with the publish config")
+	}
+	if len(s.overrides) != maxOverrideTargets {
+		t.Errorf("%d targets for metric config, want at most %d", len(s.overrides), maxOverrideTargets)
+	}
+}
+
+func TestFanOutGoroutines(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+	}{
+		{"unbounded", nil},
+		{"bounded", plugin.Config{"fanout_concurrency": int64(2)}},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+
+		settings := plugin.Config{"targets": "a=" + is.URL + ",b=" + is.URL + ",c=" + is.URL + ",d=" + is.URL}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		cfg := testConfig(is.URL, settings)
+
+		// Warm up the connections so that only the sends are measured
+		s := newTestPlugin()
+		if err := s.Publish([]plugin.Metric{newMetric(int64(0), "intel", "cpu", "idle")}, cfg); err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+		}
+		before := idleGoroutines(is)
+
+		for i := 1; i <= 50; i++ {
+			if err := s.Publish([]plugin.Metric{newMetric(int64(i), "intel", "cpu", "idle")}, cfg); err != nil {
+				t.Errorf("%s: Publish returned %v", tt.name, err)
+			}
+		}
+		after := idleGoroutines(is)
+		is.Close()
+
+		// Publish waits for its sends, leaving no goroutines behind
+		if after > before+5 {
+			t.Errorf("%s: %d goroutines after 50 publishes, %d before", tt.name, after, before)
+		}
+	}
+}
+
+// idleGoroutines returns the number of goroutines once the server's
+// connections, and the goroutines reading them, are gone; connections are
+// kept alive in varying numbers
+func idleGoroutines(is *ingestServer) int {
+	is.CloseClientConnections()
+
+	n := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		m := runtime.NumGoroutine()
+		if m == n {
+			break
+		}
+		n = m
+	}
+	return n
+}
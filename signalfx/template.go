@@ -0,0 +1,126 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// Prefix of metric names, unless metric_prefix is set
+const defaultMetricPrefix = "snap"
+
+// nameTemplate - A compiled metric name template
+type nameTemplate []templatePart
+
+// templatePart - Literal text or a placeholder of a name template
+type templatePart struct {
+	literal string // Literal text
+	field   string // Placeholder name (prefix, namespace, ns, or unit)
+	index   int    // Element index of the ns placeholder
+}
+
+// setNameTemplate will compile the name_template setting, returning an
+// error when it is invalid
+func (s *SignalFx) setNameTemplate(cfg plugin.Config) error {
+	value, err := s.getString(cfg, "name_template")
+	if err != nil {
+		// No name_template defined, moving on
+		return nil
+	}
+
+	t, err := compileNameTemplate(value)
+	if err != nil {
+		return fmt.Errorf("invalid name_template %q: %v", value, err)
+	}
+	s.nameTemplate = t
+
+	s.logf("Naming metrics using %s", value)
+	return nil
+}
+
+// compileNameTemplate compiles a template made of literal text and the
+// {prefix}, {namespace}, {ns[N]}, and {unit} placeholders
+func compileNameTemplate(source string) (nameTemplate, error) {
+	var t nameTemplate
+
+	for rest := source; rest != ""; {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			t = append(t, templatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			t = append(t, templatePart{literal: rest[:open]})
+		}
+
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder %q", rest[open:])
+		}
+		name := rest[open+1 : open+end]
+		rest = rest[open+end+1:]
+
+		switch {
+		case name == "prefix" || name == "namespace" || name == "unit":
+			t = append(t, templatePart{field: name})
+		case strings.HasPrefix(name, "ns[") && strings.HasSuffix(name, "]"):
+			i, err := strconv.Atoi(name[3 : len(name)-1])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index in {%s}", name)
+			}
+			t = append(t, templatePart{field: "ns", index: i})
+		default:
+			return nil, fmt.Errorf("unknown placeholder {%s}", name)
+		}
+	}
+
+	if len(t) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	return t, nil
+}
+
+// render returns the metric name for the prefix, namespace elements, and
+// unit; elements beyond the end of the namespace render as nothing
+func (t nameTemplate) render(prefix string, ns []string, unit string) string {
+	var b bytes.Buffer
+	for _, part := range t {
+		switch part.field {
+		case "":
+			b.WriteString(part.literal)
+		case "prefix":
+			b.WriteString(prefix)
+		case "namespace":
+			b.WriteString(strings.Join(ns, "."))
+		case "ns":
+			if part.index < len(ns) {
+				b.WriteString(ns[part.index])
+			}
+		case "unit":
+			b.WriteString(unit)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestRenderNameTemplate(t *testing.T) {
+	ns := []string{"intel", "procfs", "iface", "bytes"}
+
+	tests := []struct {
+		source string
+		name   string
+	}{
+		{"{prefix}.{namespace}", "snap.intel.procfs.iface.bytes"},
+		{"{ns[1]}.{ns[3]}_{unit}", "procfs.bytes_B"},
+		{"custom.{ns[2]}", "custom.iface"},
+		{"{prefix}.{ns[9]}x", "snap.x"},
+		{"static", "static"},
+	}
+	for _, tt := range tests {
+		tmpl, err := compileNameTemplate(tt.source)
+		if err != nil {
+			t.Errorf("compileNameTemplate(%q) returned %v", tt.source, err)
+			continue
+		}
+		if got := tmpl.render("snap", ns, "B"); got != tt.name {
+			t.Errorf("%q rendered %q, want %q", tt.source, got, tt.name)
+		}
+	}
+}
+
+func TestCompileInvalidNameTemplate(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"{prefix",
+		"{host}.{namespace}",
+		"{ns[-1]}",
+		"{ns[x]}",
+		"{ns}",
+	} {
+		if _, err := compileNameTemplate(source); err == nil {
+			t.Errorf("compileNameTemplate(%q) succeeded", source)
+		}
+	}
+}
+
+func TestNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		metric   string
+	}{
+		{"template", plugin.Config{"name_template": "{prefix}.{ns[1]}.{ns[3]}.{unit}"}, "snap.procfs.bytes.B"},
+		{"with metric_prefix", plugin.Config{
+			"name_template": "{prefix}.{namespace}",
+			"metric_prefix": "host",
+		}, "host.intel.procfs.iface.bytes"},
+		{"after strip_prefix", plugin.Config{
+			"name_template": "{ns[0]}_{ns[1]}",
+			"strip_prefix":  "/intel/procfs",
+		}, "iface_bytes"},
+	}
+	for _, tt := range tests {
+		m := newMetric(int64(1), "intel", "procfs", "iface", "bytes")
+		m.Unit = "B"
+
+		dps := publish(t, tt.settings, m)
+		if _, ok := dps[tt.metric]; !ok {
+			t.Errorf("%s: %s was not sent, got %v", tt.name, tt.metric, dps)
+		}
+	}
+}
+
+func TestInvalidNameTemplate(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	s := newTestPlugin()
+	cfg := testConfig(is.URL, plugin.Config{"name_template": "{host}"})
+	for i := 0; i < 2; i++ {
+		err := s.Publish([]plugin.Metric{newMetric(int64(1), "intel", "cpu", "idle")}, cfg)
+		if err == nil || !strings.Contains(err.Error(), "name_template") {
+			t.Errorf("Publish %d returned %v, want the invalid name_template", i, err)
+		}
+	}
+	if n := is.requestCount(); n != 0 {
+		t.Errorf("%d requests sent with an invalid name_template", n)
+	}
+}
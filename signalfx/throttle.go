@@ -0,0 +1,116 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Default seconds per_metric_rate_limit applies to
+const defaultRateWindow = 60
+
+// rateBucket - Token bucket of the datapoints a metric name may send
+type rateBucket struct {
+	tokens float64   // Datapoints that may be sent now
+	last   time.Time // When tokens was last refilled
+}
+
+// rateLimiter - Limits the datapoints sent per metric name
+type rateLimiter struct {
+	limit   float64                // Datapoints per window (0 is unlimited)
+	window  time.Duration          // Window the limit applies to
+	buckets map[string]*rateBucket // Buckets by metric name
+}
+
+// setPerMetricRateLimit will limit the datapoints sent for each metric name
+// to per_metric_rate_limit every per_metric_rate_window seconds
+func (s *SignalFx) setPerMetricRateLimit(cfg plugin.Config) {
+	limit, err := s.getInt(cfg, "per_metric_rate_limit")
+	if err != nil || limit <= 0 {
+		// No per_metric_rate_limit defined, moving on
+		return
+	}
+
+	window := int64(defaultRateWindow)
+	if n, err := s.getInt(cfg, "per_metric_rate_window"); err == nil && n > 0 {
+		window = n
+	}
+
+	s.rateLimit = rateLimiter{
+		limit:   float64(limit),
+		window:  time.Duration(window) * time.Second,
+		buckets: make(map[string]*rateBucket),
+	}
+
+	s.logf("Limiting each metric name to %d datapoints every %d seconds", limit, window)
+}
+
+// dropThrottled returns the datapoints within their metric name's rate
+// limit; the plugin's own metrics are never throttled
+func (s *SignalFx) dropThrottled(dps []*datapoint.Datapoint) []*datapoint.Datapoint {
+	if s.rateLimit.limit <= 0 {
+		return dps
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	kept := dps[:0]
+	for _, dp := range dps {
+		if strings.HasPrefix(dp.Metric, selfMetricPrefix) || s.rateLimit.take(dp.Metric, now) {
+			kept = append(kept, dp)
+			continue
+		}
+		s.debugf("Dropping %s, over per_metric_rate_limit", dp.Metric)
+		s.countFiltered(filterRateLimit)
+	}
+	return kept
+}
+
+// take reports whether the metric name may send a datapoint, refilling its
+// bucket for the time passed since it last sent
+func (r *rateLimiter) take(name string, now time.Time) bool {
+	bucket, ok := r.buckets[name]
+	if !ok {
+		// Bound memory, forgetting every name once too many are tracked
+		if len(r.buckets) >= maxTrackedSeries {
+			r.buckets = make(map[string]*rateBucket)
+		}
+		bucket = &rateBucket{tokens: r.limit, last: now}
+		r.buckets[name] = bucket
+	}
+
+	bucket.tokens += r.limit * float64(now.Sub(bucket.last)) / float64(r.window)
+	if bucket.tokens > r.limit {
+		bucket.tokens = r.limit
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
@@ -0,0 +1,132 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		name    string
+		offsets []time.Duration // When each datapoint is taken
+		taken   []bool
+	}{
+		{"burst up to the limit", []time.Duration{0, 0, 0, 0},
+			[]bool{true, true, true, false}},
+		{"refilled over the window", []time.Duration{0, 0, 0, 0, 20 * time.Second, 20 * time.Second},
+			[]bool{true, true, true, false, true, false}},
+		{"full after a window", []time.Duration{0, 0, 0, time.Minute, time.Minute, time.Minute, time.Minute},
+			[]bool{true, true, true, true, true, true, false}},
+	}
+	for _, tt := range tests {
+		r := rateLimiter{limit: 3, window: time.Minute, buckets: make(map[string]*rateBucket)}
+
+		var taken []bool
+		for _, offset := range tt.offsets {
+			taken = append(taken, r.take("snap.intel.cpu.idle", start.Add(offset)))
+		}
+		if !reflect.DeepEqual(taken, tt.taken) {
+			t.Errorf("%s: taken %v, want %v", tt.name, taken, tt.taken)
+		}
+	}
+}
+
+func TestRateLimiterBounded(t *testing.T) {
+	r := rateLimiter{limit: 1, window: time.Minute, buckets: make(map[string]*rateBucket)}
+	for i := 0; i < maxTrackedSeries; i++ {
+		r.buckets[strconv.Itoa(i)] = &rateBucket{}
+	}
+
+	if !r.take("snap.intel.cpu.idle", time.Now()) {
+		t.Error("A new name was throttled")
+	}
+	if len(r.buckets) != 1 {
+		t.Errorf("Tracking %d names, want the new one only", len(r.buckets))
+	}
+}
+
+func TestPerMetricRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		runaway  int
+		other    int
+		filtered string
+	}{
+		{"over the limit", plugin.Config{"per_metric_rate_limit": int64(2)}, 2, 2, "3"},
+		{"within the limit", plugin.Config{"per_metric_rate_limit": int64(5)}, 5, 2, "0"},
+		{"absent", nil, 5, 2, "0"},
+	}
+	for _, tt := range tests {
+		var mts []plugin.Metric
+		for i := 0; i < 5; i++ {
+			m := newMetric(int64(i), "intel", "cpu", "idle")
+			m.Tags = map[string]string{"cpu": strconv.Itoa(i)}
+			mts = append(mts, m)
+		}
+		for i := 0; i < 2; i++ {
+			m := newMetric(int64(i), "intel", "load", "load1")
+			m.Tags = map[string]string{"cpu": strconv.Itoa(i)}
+			mts = append(mts, m)
+		}
+
+		settings := plugin.Config{"self_metrics": true}
+		for k, v := range tt.settings {
+			settings[k] = v
+		}
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish(mts, testConfig(is.URL, settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, dp := range is.datapoints {
+			counts[dp.Metric]++
+		}
+		if counts["snap.intel.cpu.idle"] != tt.runaway {
+			t.Errorf("%s: sent %d of the runaway name, want %d", tt.name, counts["snap.intel.cpu.idle"], tt.runaway)
+		}
+		if counts["snap.intel.load.load1"] != tt.other {
+			t.Errorf("%s: sent %d of the other name, want %d", tt.name, counts["snap.intel.load.load1"], tt.other)
+		}
+
+		// The plugin's own metrics are never throttled
+		dp, ok := is.find(selfMetricPrefix+"filtered", "filter", filterRateLimit)
+		if !ok || fmt.Sprint(dp.Value) != tt.filtered {
+			t.Errorf("%s: filtered %v (%v), want %s", tt.name, dp.Value, ok, tt.filtered)
+		}
+	}
+}
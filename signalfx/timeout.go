@@ -0,0 +1,79 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"strconv"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"golang.org/x/net/context"
+)
+
+// setPublishTimeout will bound each request, and each publish's sends as a
+// whole, by the timeout setting: a Go duration such as "10s", or seconds
+func (s *SignalFx) setPublishTimeout(cfg plugin.Config) {
+	value, err := s.getString(cfg, "timeout")
+	if err != nil {
+		// No timeout defined, moving on
+		return
+	}
+
+	timeout, err := parseTimeout(value)
+	if err != nil || timeout <= 0 {
+		s.logf("Ignoring invalid timeout %q", value)
+		return
+	}
+	s.publishTimeout = timeout
+
+	s.logf("Timing out publishes after %v", timeout)
+}
+
+// parseTimeout parses a Go duration, or a number of seconds
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// startPublish returns the context the publish's sends are made with,
+// cancelled after the timeout setting, and the function ending it
+func (s *SignalFx) startPublish() func() {
+	if s.publishTimeout <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.publishTimeout)
+	s.publishCtx = ctx
+	return func() {
+		cancel()
+		s.publishCtx = nil
+	}
+}
+
+// sendContext returns the context sends are made with: the publish's, when
+// publishing with a timeout, otherwise the plugin's
+func (s *SignalFx) sendContext() context.Context {
+	if s.publishCtx != nil {
+		return s.publishCtx
+	}
+	return s.ctx
+}
@@ -0,0 +1,152 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		timeout time.Duration
+		ok      bool
+	}{
+		{"10s", 10 * time.Second, true},
+		{"250ms", 250 * time.Millisecond, true},
+		{"5", 5 * time.Second, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		timeout, err := parseTimeout(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseTimeout(%q) returned %v", tt.value, err)
+			continue
+		}
+		if timeout != tt.timeout {
+			t.Errorf("parseTimeout(%q) = %v, want %v", tt.value, timeout, tt.timeout)
+		}
+	}
+}
+
+func TestPublishTimeout(t *testing.T) {
+	slow := slowServer()
+	defer slow.Close()
+
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{"protobuf", formatProtobuf},
+		{"json", formatJSON},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		start := time.Now()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(slow.URL, plugin.Config{
+			"payload_format": tt.format,
+			"timeout":        "50ms",
+		}))
+
+		if err == nil {
+			t.Errorf("%s: Publish returned no error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "(timeout)") {
+			t.Errorf("%s: Publish returned %q, want a timeout", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%s: Publish took %v with a 50ms timeout", tt.name, elapsed)
+		}
+	}
+}
+
+func TestPublishTimeoutSetting(t *testing.T) {
+	def := sfxclient.NewHTTPDatapointSink().Client.Timeout
+
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		timeout  time.Duration // 0 when publishes are unbounded
+		client   time.Duration
+	}{
+		{"duration", plugin.Config{"timeout": "2s"}, 2 * time.Second, 2 * time.Second},
+		{"seconds", plugin.Config{"timeout": "3"}, 3 * time.Second, 3 * time.Second},
+		{"invalid", plugin.Config{"timeout": "soon"}, 0, def},
+		{"negative", plugin.Config{"timeout": "-1s"}, 0, def},
+		{"absent", nil, 0, def},
+	}
+	for _, tt := range tests {
+		is := newIngestServer()
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(is.URL, tt.settings))
+		is.Close()
+		if err != nil {
+			t.Errorf("%s: Publish returned %v", tt.name, err)
+			continue
+		}
+
+		if s.publishTimeout != tt.timeout {
+			t.Errorf("%s: publish timeout %v, want %v", tt.name, s.publishTimeout, tt.timeout)
+		}
+		if got := s.targets[0].sink.Client.Timeout; got != tt.client {
+			t.Errorf("%s: client timeout %v, want %v", tt.name, got, tt.client)
+		}
+	}
+}
+
+func TestPublishTimeoutOrAuth(t *testing.T) {
+	slow := slowServer()
+	defer slow.Close()
+	unauthorized := statusServer(http.StatusUnauthorized)
+	defer unauthorized.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		category string
+	}{
+		{"timeout", slow.URL, errorTimeout},
+		{"auth failure", unauthorized.URL, errorAuth},
+	}
+	for _, tt := range tests {
+		s := newTestPlugin()
+		err := s.Publish([]plugin.Metric{newMetric(1, "intel", "cpu", "idle")}, testConfig(tt.endpoint, plugin.Config{
+			"timeout": "50ms",
+		}))
+		if err == nil {
+			t.Errorf("%s: Publish returned no error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "("+tt.category+")") {
+			t.Errorf("%s: Publish returned %q, want %s", tt.name, err, tt.category)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// transformRule - A namespace prefix whose values are transformed
+type transformRule struct {
+	prefix string // Snap namespace prefix
+	source string // Expression as configured
+	expr   expr   // Compiled expression
+}
+
+// expr - A compiled arithmetic expression over the metric value
+type expr interface {
+	eval(value float64) float64
+}
+
+// Expression nodes
+type (
+	constExpr float64  // A number
+	valueExpr struct{} // The metric value
+	negExpr   struct{ x expr }
+	binExpr   struct {
+		op   byte
+		l, r expr
+	}
+)
+
+func (e constExpr) eval(value float64) float64 { return float64(e) }
+func (e valueExpr) eval(value float64) float64 { return value }
+func (e negExpr) eval(value float64) float64   { return -e.x.eval(value) }
+
+func (e binExpr) eval(value float64) float64 {
+	l, r := e.l.eval(value), e.r.eval(value)
+	switch e.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	}
+	return l / r
+}
+
+// setTransformRules will parse and compile the transform_rules setting;
+// each entry is a namespace prefix and an expression of the value, e.g.
+// "/intel/procfs/iface=value * 8 / 1000"
+func (s *SignalFx) setTransformRules(cfg plugin.Config) {
+	value, err := s.getString(cfg, "transform_rules")
+	if err != nil {
+		// No transform_rules defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring transform %q, expected prefix=expression", entry)
+			continue
+		}
+
+		e, err := compileExpr(parts[1])
+		if err != nil {
+			s.logf("Ignoring transform %q: %v", entry, err)
+			continue
+		}
+
+		s.logf("Transforming %s using %s", parts[0], parts[1])
+		s.transforms = append(s.transforms, transformRule{prefix: parts[0], source: parts[1], expr: e})
+	}
+}
+
+// transformRuleFor returns the transform rule matching the namespace, if
+// any
+func (s *SignalFx) transformRuleFor(namespace string) (transformRule, bool) {
+	for _, rule := range s.transforms {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule, true
+		}
+	}
+	return transformRule{}, false
+}
+
+// compileExpr compiles an arithmetic expression of numbers, value, + - * /,
+// and parentheses. Dividing by an expression that is always zero is
+// rejected.
+func compileExpr(source string) (expr, error) {
+	p := &exprParser{src: source}
+
+	e, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+	}
+	return e, nil
+}
+
+// exprParser - A recursive descent parser for expressions
+type exprParser struct {
+	src string // Expression being parsed
+	pos int    // Position in src
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space byte, or 0 at the end
+func (p *exprParser) peek() byte {
+	if p.skipSpace(); p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// parseSum parses term (('+' | '-') term)*
+func (p *exprParser) parseSum() (expr, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = binExpr{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseProduct parses factor (('*' | '/') factor)*
+func (p *exprParser) parseProduct() (expr, error) {
+	l, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		r, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		if op == '/' && isConst(r) && r.eval(0) == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		l = binExpr{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseFactor parses a number, value, '-' factor, or '(' sum ')'
+func (p *exprParser) parseFactor() (expr, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{x: x}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return e, nil
+	case strings.HasPrefix(p.src[p.pos:], "value"):
+		p.pos += len("value")
+		return valueExpr{}, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return constExpr(n), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+	}
+}
+
+// invalidTransform reports whether the transformed value is NaN or Inf,
+// e.g. after dividing by a zero value, counting it as dropped when it is
+func (s *SignalFx) invalidTransform(rule transformRule, result float64) bool {
+	if !math.IsNaN(result) && !math.IsInf(result, 0) {
+		return false
+	}
+
+	s.transformNaN++
+	s.countFiltered(filterTransform)
+	s.logf("Dropping %s, transform %q produced %v", s.namespace, rule.source, result)
+	return true
+}
+
+// isConst reports whether the expression does not depend on the value
+func isConst(e expr) bool {
+	switch x := e.(type) {
+	case constExpr:
+		return true
+	case negExpr:
+		return isConst(x.x)
+	case binExpr:
+		return isConst(x.l) && isConst(x.r)
+	}
+	return false
+}
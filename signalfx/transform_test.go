@@ -0,0 +1,136 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestCompileExpr(t *testing.T) {
+	tests := []struct {
+		source string
+		value  float64
+		result float64
+	}{
+		{"value", 3, 3},
+		{"value * 8 / 1000", 1000, 8},
+		{"value - 273.5", 300, 26.5},
+		{"(value + 1) * 2", 4, 10},
+		{"value + 1 * 2", 4, 6},
+		{"-value", 5, -5},
+		{"100 - -value", 5, 105},
+		{" .5*value ", 8, 4},
+		{"value / (2 - 1)", 7, 7},
+	}
+	for _, tt := range tests {
+		e, err := compileExpr(tt.source)
+		if err != nil {
+			t.Errorf("compileExpr(%q) returned %v", tt.source, err)
+			continue
+		}
+		if got := e.eval(tt.value); got != tt.result {
+			t.Errorf("%q of %v = %v, want %v", tt.source, tt.value, got, tt.result)
+		}
+	}
+}
+
+func TestCompileInvalidExpr(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"value *",
+		"(value + 1",
+		"value + 1)",
+		"value ^ 2",
+		"val",
+		"1..2 * value",
+		"value / 0",
+		"value / (1 - 1)",
+		"value / -0.0",
+	} {
+		if _, err := compileExpr(source); err == nil {
+			t.Errorf("compileExpr(%q) succeeded", source)
+		}
+	}
+}
+
+func TestTransformRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		ns    []string
+		value string
+	}{
+		{"matching", "/intel/procfs/iface=value * 8 / 1000", []string{"intel", "procfs", "iface", "bytes"}, "8"},
+		{"first matching rule", "/intel/procfs=value * 2,/intel/procfs/iface=value * 8", []string{"intel", "procfs", "iface", "bytes"}, "2000"},
+		{"other namespace", "/intel/psutil=value * 8", []string{"intel", "procfs", "iface", "bytes"}, "1000"},
+		{"invalid ignored", "/intel/procfs/iface=value / 0", []string{"intel", "procfs", "iface", "bytes"}, "1000"},
+	}
+	for _, tt := range tests {
+		dps := publish(t, plugin.Config{"transform_rules": tt.rules}, newMetric(int64(1000), tt.ns...))
+
+		dp, ok := dps["snap.intel.procfs.iface.bytes"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		if fmt.Sprint(dp.Value) != tt.value {
+			t.Errorf("%s: sent %v, want %s", tt.name, dp.Value, tt.value)
+		}
+	}
+}
+
+func TestTransformNaN(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		data    interface{}
+		sent    bool
+		dropped string
+	}{
+		{"divide by zero", "/intel/procfs=1000 / value", int64(0), false, "1"},
+		{"zero over zero", "/intel/procfs=value / value", 0.0, false, "1"},
+		{"non-zero divisor", "/intel/procfs=1000 / value", int64(8), true, "0"},
+		{"NaN input", "/intel/procfs=value * 2", math.NaN(), false, ""},
+	}
+	for _, tt := range tests {
+		dps := publish(t, plugin.Config{
+			"transform_rules": tt.rules,
+			"self_metrics":    true,
+		}, newMetric(tt.data, "intel", "procfs", "iface", "bytes"))
+
+		if _, ok := dps["snap.intel.procfs.iface.bytes"]; ok != tt.sent {
+			t.Errorf("%s: sent = %v, want %v", tt.name, ok, tt.sent)
+		}
+		if tt.dropped == "" {
+			continue
+		}
+		dp, ok := dps["snap.signalfx.transform_dropped"]
+		if !ok || fmt.Sprint(dp.Value) != tt.dropped {
+			t.Errorf("%s: transform_dropped %v (%v), want %s", tt.name, dp.Value, ok, tt.dropped)
+		}
+	}
+}
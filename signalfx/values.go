@@ -0,0 +1,353 @@
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// setBoolMapping will set the values sent for booleans from the
+// bool_mapping setting, which is "inverted" or "true=<int>,false=<int>";
+// by default true is sent as 1 and false as 0
+func (s *SignalFx) setBoolMapping(cfg plugin.Config) {
+	s.boolTrue, s.boolFalse = 1, 0
+
+	value, err := s.getString(cfg, "bool_mapping")
+	if err != nil {
+		// No bool_mapping defined, moving on
+		return
+	}
+
+	if strings.TrimSpace(value) == "inverted" {
+		s.boolTrue, s.boolFalse = 0, 1
+		s.logln("Sending true as 0 and false as 1")
+		return
+	}
+
+	boolTrue, boolFalse := s.boolTrue, s.boolFalse
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			s.logf("Ignoring bool_mapping %q, expected inverted or true=<int>,false=<int>", value)
+			return
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			s.logf("Ignoring bool_mapping %q: %v", value, err)
+			return
+		}
+
+		switch strings.TrimSpace(parts[0]) {
+		case "true":
+			boolTrue = n
+		case "false":
+			boolFalse = n
+		default:
+			s.logf("Ignoring bool_mapping %q, expected inverted or true=<int>,false=<int>", value)
+			return
+		}
+	}
+	s.boolTrue, s.boolFalse = boolTrue, boolFalse
+
+	s.logf("Sending true as %d and false as %d", boolTrue, boolFalse)
+}
+
+// boolValue returns the value sent for the boolean
+func (s *SignalFx) boolValue(v bool) int64 {
+	if v {
+		return s.boolTrue
+	}
+	return s.boolFalse
+}
+
+// toInt64 converts integer metric data to an int64 value
+func toInt64(data interface{}) (int64, bool) {
+	switch v := data.(type) {
+	case uint:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// toFloat64 converts numeric metric data to a float64 value
+func toFloat64(data interface{}) (float64, bool) {
+	switch v := data.(type) {
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// setAbsNamespaces will set the namespaces whose values are sent as their
+// absolute value
+func (s *SignalFx) setAbsNamespaces(cfg plugin.Config) {
+	value, err := s.getString(cfg, "abs_namespaces")
+	if err != nil {
+		// No abs_namespaces defined, moving on
+		return
+	}
+	s.absNamespaces = splitList(value)
+
+	s.logf("Sending absolute values of %v", s.absNamespaces)
+}
+
+// Largest int, as math has no constant for it
+const maxInt = int(^uint(0) >> 1)
+
+// absValue returns the absolute value of signed numeric data, keeping its
+// type; other data is returned as is. The smallest integers, which have no
+// positive counterpart, become the largest.
+func absValue(data interface{}) interface{} {
+	switch v := data.(type) {
+	case int:
+		if v == -maxInt-1 {
+			return maxInt
+		}
+		if v < 0 {
+			return -v
+		}
+	case int32:
+		if v == math.MinInt32 {
+			return int32(math.MaxInt32)
+		}
+		if v < 0 {
+			return -v
+		}
+	case int64:
+		if v == math.MinInt64 {
+			return int64(math.MaxInt64)
+		}
+		if v < 0 {
+			return -v
+		}
+	case float32:
+		return float32(math.Abs(float64(v)))
+	case float64:
+		return math.Abs(v)
+	}
+	return data
+}
+
+// Value sent in place of nil data for namespaces starting with prefix
+type nilDefault struct {
+	prefix string
+	value  interface{}
+}
+
+// setNilDefaults will set the values sent in place of nil data from the
+// nil_default setting, which is "prefix=value,..."
+func (s *SignalFx) setNilDefaults(cfg plugin.Config) {
+	value, err := s.getString(cfg, "nil_default")
+	if err != nil {
+		// No nil_default defined, moving on
+		return
+	}
+
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			s.logf("Ignoring nil_default %q, expected prefix=value", entry)
+			continue
+		}
+
+		rule := nilDefault{prefix: parts[0]}
+		if n, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err == nil {
+			rule.value = n
+		} else if f, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+			rule.value = f
+		} else {
+			s.logf("Ignoring nil_default %q: %v", entry, err)
+			continue
+		}
+
+		s.logf("Sending nil %s values as %v", rule.prefix, rule.value)
+		s.nilDefaults = append(s.nilDefaults, rule)
+	}
+}
+
+// nilDefaultFor returns the value sent in place of nil data for the
+// namespace, if any
+func (s *SignalFx) nilDefaultFor(namespace string) (interface{}, bool) {
+	for _, rule := range s.nilDefaults {
+		if strings.HasPrefix(namespace, rule.prefix) {
+			return rule.value, true
+		}
+	}
+	return nil, false
+}
+
+// Numeric coercion preferences
+const (
+	preferInt   = "int"   // Integral values, even in scientific notation, as ints
+	preferFloat = "float" // Every value as a float
+)
+
+// setNumericCoercion will set how numeric strings are coerced from the
+// numeric_coercion_prefer setting; by default integers are sent as ints
+// and anything else, including scientific notation, as floats
+func (s *SignalFx) setNumericCoercion(cfg plugin.Config) {
+	prefer, err := s.getString(cfg, "numeric_coercion_prefer")
+	if err != nil {
+		// No numeric_coercion_prefer defined, moving on
+		return
+	}
+
+	switch prefer {
+	case preferInt, preferFloat:
+		s.numericPrefer = prefer
+		s.logf("Preferring %s when coercing numeric strings", prefer)
+	default:
+		s.logf("Unknown numeric_coercion_prefer %q, ignoring", prefer)
+	}
+}
+
+// parseNumeric coerces a numeric string to an int64 or float64 per the
+// numeric_coercion_prefer setting
+func (s *SignalFx) parseNumeric(value string) (interface{}, bool) {
+	n, intErr := strconv.ParseInt(value, 10, 64)
+	f, floatErr := strconv.ParseFloat(value, 64)
+
+	switch {
+	case floatErr != nil:
+		return nil, false
+	case s.numericPrefer == preferFloat:
+		return f, true
+	case intErr == nil:
+		return n, true
+	case s.numericPrefer == preferInt && f == math.Trunc(f) && math.Abs(f) < math.MaxInt64:
+		return int64(f), true
+	}
+	return f, true
+}
+
+// Rounding modes
+var roundingModes = map[string]func(float64) float64{
+	"nearest":  roundNearest,
+	"floor":    math.Floor,
+	"ceil":     math.Ceil,
+	"truncate": math.Trunc,
+}
+
+// setFloatPrecision will round float values to the decimal places of the
+// float_precision setting, using the rounding_mode (nearest by default)
+func (s *SignalFx) setFloatPrecision(cfg plugin.Config) {
+	places, err := s.getInt(cfg, "float_precision")
+	if err != nil || places < 0 {
+		// No float_precision defined, moving on
+		return
+	}
+
+	mode := "nearest"
+	if name, err := s.getString(cfg, "rounding_mode"); err == nil {
+		if _, ok := roundingModes[name]; ok {
+			mode = name
+		} else {
+			s.logf("Unknown rounding_mode %q, using %s", name, mode)
+		}
+	}
+
+	s.precision = math.Pow(10, float64(places))
+	s.round = roundingModes[mode]
+
+	s.logf("Rounding floats to %d decimal places, rounding %s", places, mode)
+}
+
+// roundFloat rounds the value to the float_precision, if set
+func (s *SignalFx) roundFloat(value float64) float64 {
+	if s.round == nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+	return s.round(value*s.precision) / s.precision
+}
+
+// setMinAbsFloat will snap float values closer to zero than the
+// min_abs_float setting to zero, or drop them if min_abs_float_action is
+// drop
+func (s *SignalFx) setMinAbsFloat(cfg plugin.Config) {
+	min, err := s.getFloat(cfg, "min_abs_float")
+	if err != nil || min <= 0 {
+		// No min_abs_float defined, moving on
+		return
+	}
+	s.minAbsFloat = min
+
+	action := "zero"
+	if name, err := s.getString(cfg, "min_abs_float_action"); err == nil {
+		switch name {
+		case "zero":
+		case "drop":
+			action = name
+			s.dropTiny = true
+		default:
+			s.logf("Unknown min_abs_float_action %q, using %s", name, action)
+		}
+	}
+
+	s.logf("Floats below %v are handled by %s", min, action)
+}
+
+// snapTinyFloat returns the value, snapped to zero if below the
+// min_abs_float, and false if it should be dropped instead
+func (s *SignalFx) snapTinyFloat(value float64) (float64, bool) {
+	if value == 0 || math.Abs(value) >= s.minAbsFloat {
+		return value, true
+	}
+	if s.dropTiny {
+		return value, false
+	}
+	return 0, true
+}
+
+// roundNearest rounds half away from zero; math.Round needs Go 1.10
+func roundNearest(x float64) float64 {
+	if x < 0 {
+		return -math.Floor(-x + 0.5)
+	}
+	return math.Floor(x + 0.5)
+}
@@ -0,0 +1,423 @@
+//go:build small
+// +build small
+
+/*
+ * http://www.apache.org/licenses/LICENSE-2.0.txt
+ *
+ * Copyright 2017 OpsVision Solutions
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 	http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signalfx
+
+// Imports
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestBoolMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		up       int64
+		down     int64
+	}{
+		{"default", nil, 1, 0},
+		{"inverted", plugin.Config{"bool_mapping": "inverted"}, 0, 1},
+		{"custom", plugin.Config{"bool_mapping": "true=100,false=-1"}, 100, -1},
+		{"partial", plugin.Config{"bool_mapping": "false=2"}, 1, 2},
+		{"malformed", plugin.Config{"bool_mapping": "true=yes,false=0"}, 1, 0},
+		{"unknown key", plugin.Config{"bool_mapping": "on=5"}, 1, 0},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings,
+			newMetric(true, "intel", "service", "up"),
+			newMetric(false, "intel", "service", "down"),
+		)
+
+		for _, want := range []struct {
+			metric string
+			value  int64
+		}{
+			{"snap.intel.service.up", tt.up},
+			{"snap.intel.service.down", tt.down},
+		} {
+			dp, ok := dps[want.metric]
+			if !ok {
+				t.Errorf("%s: %s was not sent", tt.name, want.metric)
+				continue
+			}
+			if dp.Value != want.value {
+				t.Errorf("%s: %s = %v, want %d", tt.name, want.metric, dp.Value, want.value)
+			}
+		}
+	}
+}
+
+func TestIntegerValues(t *testing.T) {
+	dps := publish(t, plugin.Config{
+		"aggregate_namespaces": "/intel/sum:sum,/intel/avg:avg,/intel/mixed:max",
+	},
+		newMetric(int64(1), "intel", "int64"),
+		newMetric(int32(2), "intel", "int32"),
+		newMetric(3, "intel", "int"),
+		newMetric(uint32(4), "intel", "uint32"),
+		newMetric(uint64(5), "intel", "uint64"),
+		newMetric(float32(1.5), "intel", "float32"),
+		newMetric(2.5, "intel", "float64"),
+		newMetric(int64(1), "intel", "sum"),
+		newMetric(int64(2), "intel", "sum"),
+		newMetric(int64(1), "intel", "avg"),
+		newMetric(int64(2), "intel", "avg"),
+		newMetric(int64(1), "intel", "mixed"),
+		newMetric(0.5, "intel", "mixed"),
+	)
+
+	tests := []struct {
+		metric  string
+		integer bool
+		value   string
+	}{
+		{"snap.intel.int64", true, "1"},
+		{"snap.intel.int32", true, "2"},
+		{"snap.intel.int", true, "3"},
+		{"snap.intel.uint32", true, "4"},
+		{"snap.intel.uint64", true, "5"},
+		{"snap.intel.float32", false, "1.5"},
+		{"snap.intel.float64", false, "2.5"},
+		{"snap.intel.sum", true, "3"},
+		{"snap.intel.avg", false, "1.5"},
+		{"snap.intel.mixed", false, "1"},
+	}
+	for _, tt := range tests {
+		dp, ok := dps[tt.metric]
+		if !ok {
+			t.Errorf("%s was not sent", tt.metric)
+			continue
+		}
+		// The ingest server receives the protobuf IntValue as an int64
+		if _, integer := dp.Value.(int64); integer != tt.integer {
+			t.Errorf("%s sent as %T, want an integer %v", tt.metric, dp.Value, tt.integer)
+		}
+		if fmt.Sprint(dp.Value) != tt.value {
+			t.Errorf("%s = %v, want %s", tt.metric, dp.Value, tt.value)
+		}
+	}
+}
+
+func TestAbsValue(t *testing.T) {
+	tests := []struct {
+		data interface{}
+		want interface{}
+	}{
+		{int(-3), int(3)},
+		{int32(-3), int32(3)},
+		{int64(-3), int64(3)},
+		{int64(3), int64(3)},
+		{int(-maxInt - 1), int(maxInt)},
+		{int32(math.MinInt32), int32(math.MaxInt32)},
+		{int64(math.MinInt64), int64(math.MaxInt64)},
+		{float32(-1.5), float32(1.5)},
+		{-2.5, 2.5},
+		{uint64(7), uint64(7)},
+		{"-1", "-1"},
+	}
+	for _, tt := range tests {
+		if got := absValue(tt.data); got != tt.want {
+			t.Errorf("absValue(%T %v) = %T %v, want %T %v", tt.data, tt.data, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestAbsNamespaces(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		data     interface{}
+		integer  bool
+		value    string
+	}{
+		{"negative int", plugin.Config{"abs_namespaces": "/intel/temp"}, int64(-4), true, "4"},
+		{"negative float", plugin.Config{"abs_namespaces": "/intel/temp"}, -4.5, false, "4.5"},
+		{"smallest int", plugin.Config{"abs_namespaces": "/intel/temp"}, int64(math.MinInt64), true, "9223372036854775807"},
+		{"positive", plugin.Config{"abs_namespaces": "/intel/temp"}, 4.5, false, "4.5"},
+		{"other namespace", plugin.Config{"abs_namespaces": "/intel/cpu"}, int64(-4), true, "-4"},
+		{"disabled", nil, -4.5, false, "-4.5"},
+	}
+	for _, tt := range tests {
+		dp, ok := publish(t, tt.settings, newMetric(tt.data, "intel", "temp", "delta"))["snap.intel.temp.delta"]
+		if !ok {
+			t.Errorf("%s: nothing was sent", tt.name)
+			continue
+		}
+		// The ingest server receives the protobuf IntValue as an int64
+		if _, integer := dp.Value.(int64); integer != tt.integer {
+			t.Errorf("%s: sent as %T, want an integer %v", tt.name, dp.Value, tt.integer)
+		}
+		if fmt.Sprint(dp.Value) != tt.value {
+			t.Errorf("%s: sent %v, want %s", tt.name, dp.Value, tt.value)
+		}
+	}
+}
+
+func TestNilDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		integer  bool
+		value    string
+		sent     bool
+	}{
+		{"int default", plugin.Config{"nil_default": "/intel/disk=0"}, true, "0", true},
+		{"float default", plugin.Config{"nil_default": "/intel/disk=-1.5"}, false, "-1.5", true},
+		{"several rules", plugin.Config{"nil_default": "/intel/cpu=1,/intel/disk/errors=7"}, true, "7", true},
+		{"other namespace", plugin.Config{"nil_default": "/intel/cpu=0"}, false, "", false},
+		{"malformed", plugin.Config{"nil_default": "/intel/disk=none"}, false, "", false},
+		{"absent", nil, false, "", false},
+	}
+	for _, tt := range tests {
+		dp, ok := publish(t, tt.settings, newMetric(nil, "intel", "disk", "errors"))["snap.intel.disk.errors"]
+		if ok != tt.sent {
+			t.Errorf("%s: sent = %v, want %v", tt.name, ok, tt.sent)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// The ingest server receives the protobuf IntValue as an int64
+		if _, integer := dp.Value.(int64); integer != tt.integer {
+			t.Errorf("%s: sent as %T, want an integer %v", tt.name, dp.Value, tt.integer)
+		}
+		if fmt.Sprint(dp.Value) != tt.value {
+			t.Errorf("%s: sent %v, want %s", tt.name, dp.Value, tt.value)
+		}
+	}
+}
+
+func TestNumericCoercion(t *testing.T) {
+	tests := []struct {
+		prefer  interface{}
+		value   string
+		integer bool
+		want    string
+	}{
+		{nil, "1e10", false, "1e+10"},
+		{nil, "100", true, "100"},
+		{nil, "3.14", false, "3.14"},
+		{preferInt, "1e10", true, "10000000000"},
+		{preferInt, "100", true, "100"},
+		{preferInt, "3.14", false, "3.14"},
+		{preferInt, "1.5e1", true, "15"},
+		{preferInt, "1e30", false, "1e+30"},
+		{preferFloat, "1e10", false, "1e+10"},
+		{preferFloat, "100", false, "100"},
+		{preferFloat, "3.14", false, "3.14"},
+		{"unknown", "1e10", false, "1e+10"},
+	}
+	for _, tt := range tests {
+		cfg := plugin.Config{}
+		if tt.prefer != nil {
+			cfg["numeric_coercion_prefer"] = tt.prefer
+		}
+		s := newTestPlugin()
+		s.setNumericCoercion(cfg)
+
+		got, ok := s.parseNumeric(tt.value)
+		if !ok {
+			t.Errorf("%v: %s was not parsed", tt.prefer, tt.value)
+			continue
+		}
+		if _, integer := got.(int64); integer != tt.integer {
+			t.Errorf("%v: %s parsed as %T, want an integer %v", tt.prefer, tt.value, got, tt.integer)
+		}
+		if str := fmt.Sprint(got); str != tt.want {
+			t.Errorf("%v: %s parsed as %s, want %s", tt.prefer, tt.value, str, tt.want)
+		}
+	}
+}
+
+func TestNumericCoercionInvalid(t *testing.T) {
+	s := newTestPlugin()
+	for _, value := range []string{"", "ten", "1e", "0x10"} {
+		if got, ok := s.parseNumeric(value); ok {
+			t.Errorf("%q parsed as %v", value, got)
+		}
+	}
+}
+
+func TestRoundingMode(t *testing.T) {
+	tests := []struct {
+		mode     interface{}
+		positive string
+		negative string
+	}{
+		{nil, "1.24", "-1.24"},
+		{"nearest", "1.24", "-1.24"},
+		{"floor", "1.23", "-1.24"},
+		{"ceil", "1.24", "-1.23"},
+		{"truncate", "1.23", "-1.23"},
+		{"bankers", "1.24", "-1.24"},
+	}
+	for _, tt := range tests {
+		settings := plugin.Config{"float_precision": int64(2)}
+		if tt.mode != nil {
+			settings["rounding_mode"] = tt.mode
+		}
+		dps := publish(t, settings,
+			newMetric(1.236, "intel", "load", "positive"),
+			newMetric(-1.236, "intel", "load", "negative"),
+			newMetric(int64(7), "intel", "load", "integer"),
+		)
+
+		for _, want := range []struct{ metric, value string }{
+			{"snap.intel.load.positive", tt.positive},
+			{"snap.intel.load.negative", tt.negative},
+			{"snap.intel.load.integer", "7"},
+		} {
+			dp, ok := dps[want.metric]
+			if !ok {
+				t.Errorf("%v: %s was not sent", tt.mode, want.metric)
+				continue
+			}
+			if fmt.Sprint(dp.Value) != want.value {
+				t.Errorf("%v: %s = %v, want %s", tt.mode, want.metric, dp.Value, want.value)
+			}
+		}
+	}
+}
+
+func TestRoundNearest(t *testing.T) {
+	tests := []struct {
+		x, want float64
+	}{
+		{0.5, 1},
+		{1.5, 2},
+		{2.5, 3},
+		{-0.5, -1},
+		{-2.5, -3},
+		{2.4, 2},
+		{-2.4, -2},
+	}
+	for _, tt := range tests {
+		if got := roundNearest(tt.x); got != tt.want {
+			t.Errorf("roundNearest(%v) = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestMinAbsFloat(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings plugin.Config
+		tiny     string // Sent for 1e-300, "" when dropped
+		negative string // Sent for -1e-12, "" when dropped
+	}{
+		{"snapped", plugin.Config{"min_abs_float": 1e-9}, "0", "0"},
+		{"snapped explicitly", plugin.Config{"min_abs_float": 1e-9, "min_abs_float_action": "zero"}, "0", "0"},
+		{"dropped", plugin.Config{"min_abs_float": 1e-9, "min_abs_float_action": "drop"}, "", ""},
+		{"unknown action", plugin.Config{"min_abs_float": 1e-9, "min_abs_float_action": "round"}, "0", "0"},
+		{"above the minimum", plugin.Config{"min_abs_float": 1e-310}, "1e-300", "-1e-12"},
+		{"absent", nil, "1e-300", "-1e-12"},
+	}
+	for _, tt := range tests {
+		dps := publish(t, tt.settings,
+			newMetric(1e-300, "intel", "load", "tiny"),
+			newMetric(-1e-12, "intel", "load", "negative"),
+			newMetric(0.5, "intel", "load", "normal"),
+			newMetric(0.0, "intel", "load", "zero"),
+			newMetric(int64(0), "intel", "load", "integer"),
+		)
+
+		for _, want := range []struct{ metric, value string }{
+			{"snap.intel.load.tiny", tt.tiny},
+			{"snap.intel.load.negative", tt.negative},
+			{"snap.intel.load.normal", "0.5"},
+			{"snap.intel.load.zero", "0"},
+			{"snap.intel.load.integer", "0"},
+		} {
+			var got string
+			if dp, ok := dps[want.metric]; ok {
+				got = fmt.Sprint(dp.Value)
+			}
+			if got != want.value {
+				t.Errorf("%s: %s sent %q, want %q", tt.name, want.metric, got, want.value)
+			}
+		}
+	}
+}
+
+func TestBoolAndStringValues(t *testing.T) {
+	dps := publish(t, nil,
+		newMetric(true, "intel", "service", "up"),
+		newMetric(false, "intel", "service", "down"),
+		newMetric("degraded", "intel", "service", "state"),
+		newMetric(" 42 ", "intel", "service", "workers"),
+	)
+
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"snap.intel.service.up", int64(1)},
+		{"snap.intel.service.down", int64(0)},
+		{"snap.intel.service.state", "degraded"},
+		{"snap.intel.service.workers", int64(42)},
+	}
+	for _, tt := range tests {
+		dp, ok := dps[tt.name]
+		if !ok {
+			t.Errorf("%s was not sent", tt.name)
+			continue
+		}
+		if dp.Type != "gauge" {
+			t.Errorf("%s sent as %s, want a gauge", tt.name, dp.Type)
+		}
+		if dp.Value != tt.value {
+			t.Errorf("%s = %T %v, want %T %v", tt.name, dp.Value, dp.Value, tt.value, tt.value)
+		}
+	}
+}
+
+func TestUnknownValueType(t *testing.T) {
+	is := newIngestServer()
+	defer is.Close()
+
+	var logged bytes.Buffer
+	s := newTestPlugin()
+	s.SetLogger(log.New(&logged, "", 0))
+
+	err := s.Publish([]plugin.Metric{
+		newMetric([]int{1, 2}, "intel", "cpu", "list"),
+		newMetric(int64(1), "intel", "cpu", "idle"),
+	}, testConfig(is.URL, nil))
+	if err != nil {
+		t.Fatalf("Publish returned %v", err)
+	}
+
+	// Logged rather than sent
+	if !strings.Contains(logged.String(), "Ignoring []int") {
+		t.Errorf("The unknown type was not logged: %q", logged.String())
+	}
+	dps := is.received()
+	if _, ok := dps["snap.intel.cpu.idle"]; len(dps) != 1 || !ok {
+		t.Errorf("Sent %v, want only the idle datapoint", dps)
+	}
+}